@@ -0,0 +1,269 @@
+// Действия над найденными дубликатами: выбор оставляемого файла, удаление, перемещение в корзину и замена жесткой ссылкой
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+// Поддерживаемые значения Config.Action
+const (
+	ActionNone     = ""         // Только отчет, файлы не трогаем
+	ActionDelete   = "delete"   // Безвозвратное удаление
+	ActionTrash    = "trash"    // Перемещение в системную корзину (можно восстановить)
+	ActionHardlink = "hardlink" // Замена дубликата жесткой ссылкой на оставляемый файл (место освобождается, путь остается)
+)
+
+// KeepStrategy определяет, какие файлы группы остаются на месте
+type KeepStrategy string
+
+const (
+	KeepFirst  KeepStrategy = "first"   // Остается один файл на всю группу (первый)
+	KeepPerDir KeepStrategy = "per_dir" // Остается по первому файлу в каждой директории
+)
+
+// ActionError описывает файл, над которым не удалось выполнить действие
+type ActionError struct {
+	Path string
+	Err  error
+}
+
+// ActionReport - итог выполнения действия над дубликатами
+type ActionReport struct {
+	Done   []string      // Успешно обработанные пути
+	Failed []ActionError // Пути, которые обработать не удалось, и причина
+}
+
+// validateAction проверяет значения Config.Action и Config.Keep до начала сканирования
+func validateAction(action string, keep KeepStrategy) error {
+	switch keep {
+	case "", KeepFirst, KeepPerDir: // Пустое значение равносильно first
+	default:
+		return fmt.Errorf("неизвестная стратегия %q (допустимо: %s, %s)", keep, KeepFirst, KeepPerDir)
+	}
+	switch action {
+	case ActionNone, ActionDelete, ActionTrash, ActionHardlink:
+		return nil
+	}
+	return fmt.Errorf("неизвестное действие %q (допустимо: %s, %s, %s)", action, ActionDelete, ActionTrash, ActionHardlink)
+}
+
+// checkActionMode проверяет, что в группах режима mode одинаковые по содержимому файлы и над ними
+// можно выполнять действия. Пустой режим (результаты записаны без него) - тоже отказ: режим неизвестен.
+func checkActionMode(mode scan.Mode) error {
+	switch mode {
+	case scan.ModeNameOnly, scan.ModeSizeOnly, scan.ModeNameCount, scan.ModeImage, scan.ModeChunks:
+		return fmt.Errorf("в режиме %s в группе могут быть разные файлы, действия с ним не применяются", mode)
+	case "":
+		return errors.New("в результатах не записан режим сканирования, действия не применяются: повторите scan с -jsonl или -sqlite")
+	}
+	return nil
+}
+
+// PlannedOp - одна запланированная операция над файлом
+type PlannedOp struct {
+	Op   string `json:"op"`   // DELETE, TRASH или HARDLINK
+	Path string `json:"path"` // Файл-дубликат, над которым выполняется операция
+	Kept string `json:"kept"` // Файл той же группы, который остается на месте
+	Size int64  `json:"size"`
+	Hash string `json:"hash,omitempty"`
+
+	ModTime  time.Time `json:"mtime"`     // Время изменения при сканировании: перед действием сверяется с диском
+	KeptSize int64     `json:"kept_size"` // Размер оставляемого файла (при ContentOnly может отличаться от Size)
+}
+
+// String возвращает операцию в стабильном формате для вывода и разбора: "DELETE <path>",
+// а для ссылки - и файл, на который она будет вести: "HARDLINK <dup> -> <kept>"
+func (op PlannedOp) String() string {
+	if op.Op == "HARDLINK" {
+		return op.Op + " " + op.Path + " -> " + op.Kept
+	}
+	return op.Op + " " + op.Path
+}
+
+// opName возвращает имя операции для действия из Config.Action
+func opName(action string) string {
+	switch action {
+	case ActionDelete:
+		return "DELETE"
+	case ActionTrash:
+		return "TRASH"
+	case ActionHardlink:
+		return "HARDLINK"
+	}
+	return ""
+}
+
+// newPlannedOp создает операцию над файлом f, при которой остается файл kept
+func newPlannedOp(op string, f, kept scan.FileInfo) PlannedOp {
+	return PlannedOp{Op: op, Path: f.Path, Kept: kept.Path, Size: f.Size, Hash: f.Hash, ModTime: f.ModTime, KeptSize: kept.Size}
+}
+
+// errChangedSinceScan - файл на диске уже не тот, что нашло сканирование
+var errChangedSinceScan = errors.New("файл изменился после сканирования, не тронут")
+
+// checkUnchanged перед действием сверяет файл с тем, что видело сканирование: размер и время
+// изменения (с точностью до секунды - столько хранит -sqlite), а у оставляемой копии -
+// что она на месте и прежнего размера. Иначе удаление могло бы унести единственную копию.
+func checkUnchanged(op PlannedOp) error {
+	info, err := os.Lstat(scan.LongPath(op.Path))
+	if err != nil {
+		return err
+	}
+	if info.Size() != op.Size ||
+		(!op.ModTime.IsZero() && !info.ModTime().Truncate(time.Second).Equal(op.ModTime.Truncate(time.Second))) {
+		return errChangedSinceScan
+	}
+	kept, err := os.Stat(scan.LongPath(op.Kept))
+	if err != nil || kept.Size() != op.KeptSize {
+		return fmt.Errorf("оставляемая копия %s изменилась или пропала, файл не тронут", op.Kept)
+	}
+	return nil
+}
+
+// planGroup выбирает в группе файлы на обработку и для каждого - оставляемый файл.
+// Записи архивов (ReadOnly) не трогаются и не считаются оставленной копией:
+// копия на диске остается всегда, даже если то же самое лежит в архиве.
+func planGroup(group []scan.FileInfo, keep KeepStrategy, op string) []PlannedOp {
+	var result []PlannedOp
+	group = slices.DeleteFunc(slices.Clone(group), func(f scan.FileInfo) bool { return f.ReadOnly })
+	if len(group) == 0 {
+		return nil
+	}
+	if keep != KeepPerDir {
+		for _, f := range group[1:] {
+			result = append(result, newPlannedOp(op, f, group[0]))
+		}
+		return result
+	}
+	// Первое вхождение в каждой директории остается, остальные - на обработку
+	keptInDir := make(map[string]scan.FileInfo)
+	for _, f := range group {
+		dir := filepath.Dir(f.Path)
+		if kept, ok := keptInDir[dir]; ok {
+			result = append(result, newPlannedOp(op, f, kept))
+			continue
+		}
+		keptInDir[dir] = f
+	}
+	return result
+}
+
+// PlanActions строит список операций над дубликатами, ничего не меняя на диске.
+// Какие файлы группы остаются на месте, решает cfg.Keep.
+func PlanActions(cfg Config, groups [][]scan.FileInfo) ([]PlannedOp, error) {
+	if err := validateAction(cfg.Action, cfg.Keep); err != nil {
+		return nil, err
+	}
+	if cfg.Action == ActionNone {
+		return nil, nil
+	}
+	var plan []PlannedOp
+	for _, group := range groups {
+		plan = append(plan, planGroup(group, cfg.Keep, opName(cfg.Action))...)
+	}
+	return plan, nil
+}
+
+// ExecutePlan выполняет запланированные операции.
+// Ошибки по отдельным файлам не прерывают работу и собираются в отчет:
+// если файл не удалось отправить в корзину, он НЕ удаляется безвозвратно.
+// Файлы, изменившиеся после сканирования (см. checkUnchanged), пропускаются с ошибкой в отчете.
+// Если задан журнал, до изменения на диске в него пишется намерение, а после - итог (каждая
+// запись сброшена на диск): после сбоя посреди операции журнал покажет, какой файл трогали.
+// Если журнал записать не удалось, выполнение останавливается.
+func ExecutePlan(plan []PlannedOp, journal *Journal) (ActionReport, error) {
+	var report ActionReport
+	for _, op := range plan {
+		var target string
+		err := checkUnchanged(op)
+		entry := JournalEntry{Op: op.Op, Path: op.Path, Kept: op.Kept, Size: op.Size, Hash: op.Hash}
+		if err == nil && journal != nil {
+			entry.State = stateIntent
+			if err := journal.Record(entry); err != nil {
+				return report, fmt.Errorf("запись в журнал: %w", err)
+			}
+		}
+		if err == nil {
+			switch op.Op {
+			case "DELETE":
+				err = os.Remove(scan.LongPath(op.Path))
+			case "TRASH":
+				target, err = moveToTrash(op.Path)
+			case "HARDLINK":
+				err = replaceWithLink(op.Path, op.Kept)
+			default:
+				err = fmt.Errorf("неизвестная операция %q", op.Op)
+			}
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, ActionError{Path: op.Path, Err: err})
+		} else {
+			report.Done = append(report.Done, op.Path)
+		}
+
+		if journal != nil && entry.State == stateIntent {
+			entry.State, entry.Target = stateDone, target
+			if err != nil {
+				entry.State = stateFailed
+			}
+			if err := journal.Record(entry); err != nil {
+				return report, fmt.Errorf("запись в журнал: %w", err)
+			}
+		}
+	}
+	if journal != nil {
+		if err := journal.Commit(); err != nil {
+			return report, fmt.Errorf("запись в журнал: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// replaceWithLink заменяет файл path жесткой ссылкой на kept. Ссылка сначала создается рядом
+// под временным именем и потом переименовывается на место path: при сбое на диске остается
+// либо прежний файл, либо ссылка, но не пустое место. Между разными ФС ссылки не бывает -
+// тогда ошибка, а файл не тронут.
+func replaceWithLink(path, kept string) error {
+	tmp := path + ".duplifinder-link"
+	if err := os.Link(scan.LongPath(kept), scan.LongPath(tmp)); err != nil {
+		return err
+	}
+	if err := os.Rename(scan.LongPath(tmp), scan.LongPath(path)); err != nil {
+		os.Remove(scan.LongPath(tmp))
+		return err
+	}
+	return nil
+}
+
+// planFile - формат JSON-экспорта плана для внешних инструментов проверки
+type planFile struct {
+	Action     string       `json:"action"`
+	Keep       KeepStrategy `json:"keep"`
+	DryRun     bool         `json:"dry_run"`
+	Operations []PlannedOp  `json:"operations"`
+}
+
+// WritePlanJSON сохраняет план операций в JSON-файл
+func WritePlanJSON(path string, cfg Config, plan []PlannedOp) error {
+	if plan == nil {
+		plan = []PlannedOp{} // В JSON пустой план - [], а не null
+	}
+	data, err := json.MarshalIndent(planFile{
+		Action:     cfg.Action,
+		Keep:       cfg.Keep,
+		DryRun:     cfg.DryRun,
+		Operations: plan,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+func TestPlannedOpString(t *testing.T) {
+	group := []scan.FileInfo{{Path: "/a/kept"}, {Path: "/b/dup"}}
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{ActionDelete, "DELETE /b/dup"},
+		{ActionTrash, "TRASH /b/dup"},
+		{ActionHardlink, "HARDLINK /b/dup -> /a/kept"},
+	}
+	for _, tt := range tests {
+		plan, err := PlanActions(Config{Action: tt.action}, [][]scan.FileInfo{group})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan) != 1 || plan[0].String() != tt.want {
+			t.Errorf("%s: план %v, want [%s]", tt.action, plan, tt.want)
+		}
+	}
+}
+
+func TestExecutePlanHardlink(t *testing.T) {
+	dir := t.TempDir()
+	kept, dup := filepath.Join(dir, "kept"), filepath.Join(dir, "dup")
+	for _, path := range []string{kept, dup} {
+		if err := os.WriteFile(path, []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := ExecutePlan([]PlannedOp{{Op: "HARDLINK", Path: dup, Kept: kept, Size: 4, KeptSize: 4}}, nil)
+	if err != nil || len(report.Failed) > 0 {
+		t.Fatalf("ExecutePlan: %v, %+v", err, report.Failed)
+	}
+	a, err := os.Stat(kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(dup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(a, b) {
+		t.Error("дубликат не заменен ссылкой на оставляемый файл")
+	}
+}
+
+func TestPlanActionsKeepPerDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"album1/a.jpg", "album1/b.jpg", "album2/a.jpg", "album2/b.jpg"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("photo"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scanner, err := scan.NewScanner(scan.DefaultConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 {
+		t.Fatalf("групп %d, want 1", len(result.Groups))
+	}
+
+	plan, err := PlanActions(Config{Action: ActionDelete, Keep: KeepPerDir}, result.Groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// В каждой директории остается по файлу и удаляется по одному
+	perDir := make(map[string]int)
+	for _, op := range plan {
+		if filepath.Dir(op.Kept) != filepath.Dir(op.Path) {
+			t.Errorf("%s: оставляемый файл %s из другой директории", op.Path, op.Kept)
+		}
+		perDir[filepath.Base(filepath.Dir(op.Path))]++
+	}
+	if len(plan) != 2 || perDir["album1"] != 1 || perDir["album2"] != 1 {
+		t.Errorf("операций по директориям %v, want по одной в album1 и album2", perDir)
+	}
+}
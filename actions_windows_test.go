@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+func TestExecutePlanLongPaths(t *testing.T) {
+	dir := t.TempDir()
+	deep := dir
+	for len(deep) < 300 {
+		deep = filepath.Join(deep, strings.Repeat("d", 50))
+	}
+	if err := os.MkdirAll(scan.LongPath(deep), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(scan.LongPath(filepath.Join(deep, name)), []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups := scanDir(t, dir, scan.ModeHash)
+
+	if len(groups) != 1 {
+		t.Fatalf("групп %d, want 1", len(groups))
+	}
+
+	plan, err := PlanActions(Config{Action: ActionDelete}, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := ExecutePlan(plan, nil)
+	if err != nil || len(report.Failed) > 0 {
+		t.Fatalf("ExecutePlan: %v, %+v", err, report.Failed)
+	}
+	entries, err := os.ReadDir(scan.LongPath(deep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("осталось файлов %d, want 1", len(entries))
+	}
+}
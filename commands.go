@@ -0,0 +1,366 @@
+// Подкоманды report, clean и cache: работа с сохраненными результатами без нового сканирования
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+// subcommands - подкоманды, кроме scan. Если первый аргумент не подкоманда, это scan:
+// "duplifinder -path ~/photos" и "duplifinder ~/photos" работают как раньше
+// (папку с именем подкоманды задайте как ./report).
+var subcommands = map[string]func(args []string){
+	"report": runReport,
+	"clean":  runClean,
+	"cache":  runCache,
+}
+
+// Форматы вывода подкоманды report
+const (
+	FormatText   = "text"
+	FormatJSONL  = "jsonl"
+	FormatSQLite = "sqlite"
+)
+
+// sqliteMagic - начало файла базы SQLite: по нему файл результатов отличается от JSON Lines
+const sqliteMagic = "SQLite format 3\x00"
+
+// newSubcommandFlags создает набор флагов подкоманды; args - как в справке после имени подкоманды
+func newSubcommandFlags(name, args, about string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Использование: %s %s [флаги] %s\n\n%s\n\nФлаги:\n", os.Args[0], name, args, about)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseSubcommand разбирает флаги подкоманды; при ошибке или -help завершает программу
+func parseSubcommand(flags *flag.FlagSet, args []string) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitNoDuplicates)
+		}
+		os.Exit(exitFatal)
+	}
+}
+
+// loadResults загружает группы и режим сканирования из файла результатов scan:
+// JSON Lines (-jsonl, "-" - stdin) или базы SQLite (-sqlite)
+func loadResults(path string) ([][]scan.FileInfo, scan.Mode, error) {
+	if path == "-" {
+		return scan.ReadJSONL(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	head := make([]byte, len(sqliteMagic))
+	n, _ := io.ReadFull(f, head)
+	if string(head[:n]) == sqliteMagic {
+		return ReadSQLite(path)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	return scan.ReadJSONL(f)
+}
+
+// readPlanFile загружает план, сохраненный -plan-json. nil без ошибки - это не файл плана
+// (например, файл результатов).
+func readPlanFile(path string) (*planFile, error) {
+	if path == "-" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan planFile
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) || json.Unmarshal(data, &plan) != nil || plan.Operations == nil {
+		return nil, nil
+	}
+	return &plan, nil
+}
+
+// runReport выводит сохраненные результаты в нужном формате, не сканируя заново
+func runReport(args []string) {
+	flags := newSubcommandFlags("report", "РЕЗУЛЬТАТЫ",
+		"Показать результаты, сохраненные scan -jsonl или -sqlite (- - JSON Lines из stdin), или перевести их в другой формат.")
+	format := flags.String("format", FormatText, "Формат вывода: text, jsonl, sqlite")
+	output := flags.String("o", "", "Куда записать результат (для text и jsonl по умолчанию - stdout, для sqlite обязателен)")
+	top := flags.Int("top", 0, "Показать только N групп, занимающих больше всего лишнего места (для text)")
+	byExt := flags.Bool("by-ext", false, "Показать лишнее место по расширениям файлов (для text)")
+	parseSubcommand(flags, args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(exitFatal)
+	}
+
+	groups, mode, err := loadResults(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Не удалось прочитать результаты %s: %v\n", flags.Arg(0), err)
+		os.Exit(exitFatal)
+	}
+	switch *format {
+	case FormatText:
+		err = writeTo(*output, func(w io.Writer) error {
+			printReport(w, groups, *top, *byExt)
+			return nil
+		})
+	case FormatJSONL:
+		path := *output
+		if path == "" {
+			path = "-"
+		}
+		err = writeJSONL(path, mode, groups)
+	case FormatSQLite:
+		if *output == "" {
+			fmt.Fprintln(os.Stderr, "❌ Для -format sqlite укажите файл базы через -o")
+			os.Exit(exitFatal)
+		}
+		err = WriteSQLite(*output, mode, groups)
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Неизвестный формат %q (допустимо: %s, %s, %s)\n", *format, FormatText, FormatJSONL, FormatSQLite)
+		os.Exit(exitFatal)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Не удалось записать отчет: %v\n", err)
+		os.Exit(exitFatal)
+	}
+	if len(groups) > 0 {
+		os.Exit(exitDuplicates)
+	}
+}
+
+// writeTo вызывает write для файла path ("" - stdout)
+func writeTo(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// printReport выводит сохраненные группы так же, как их показывает scan
+func printReport(w io.Writer, groups [][]scan.FileInfo, top int, byExt bool) {
+	roots := make(map[string]bool)
+	for _, group := range groups {
+		for _, f := range group {
+			roots[f.Root] = true
+		}
+	}
+	fmt.Fprintln(w, "📊 Результаты поиска:")
+	switch {
+	case len(groups) == 0:
+		fmt.Fprintln(w, "Дубликаты не найдены")
+	case top > 0:
+		printTop(w, groups, top)
+	default:
+		printGroups(w, groups, len(roots) > 1, false)
+	}
+	if byExt && len(groups) > 0 {
+		printByExtension(w, groups)
+	}
+}
+
+// runClean выполняет действие над дубликатами из файла результатов или плана (-plan-json):
+// сканирование ночью на сервере, решение - утром
+func runClean(args []string) {
+	flags := newSubcommandFlags("clean", "РЕЗУЛЬТАТЫ|ПЛАН",
+		"Выполнить действие над дубликатами из результатов scan -jsonl или -sqlite либо над операциями плана -plan-json.\n"+
+			"Файлы, изменившиеся после сканирования, не трогаются.")
+	action := flags.String("action", "", "Действие: delete (удалить), trash (в корзину), hardlink (жесткая ссылка); для плана - из плана")
+	keep := flags.String("keep", string(KeepFirst), "Какие файлы оставить: first (один на группу), per_dir (по одному в каждой директории)")
+	dryRun := flags.Bool("dry-run", false, "Показать план действий без изменений на диске")
+	planOut := flags.String("plan-json", "", "Сохранить план действий в JSON-файл")
+	interactive := flags.Bool("interactive", false, "Спрашивать по каждой группе, какой файл оставить")
+	tui := flags.Bool("tui", false, "Выбрать файлы на весь экран терминала")
+	script := flags.String("script", "", "Не выполнять действия, а записать их в POSIX sh-скрипт")
+	scriptPS := flags.String("script-ps", "", "Не выполнять действия, а записать их в PowerShell-скрипт")
+	journal := flags.String("journal", "", "Куда писать журнал выполненных действий (по умолчанию - в кэш пользователя)")
+	parseSubcommand(flags, args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(exitFatal)
+	}
+	cfg := Config{
+		Action:      *action,
+		Keep:        KeepStrategy(*keep),
+		DryRun:      *dryRun,
+		PlanOut:     *planOut,
+		Interactive: *interactive,
+		TUI:         *tui,
+		ScriptOut:   *script,
+		ScriptPSOut: *scriptPS,
+		JournalPath: *journal,
+	}
+	path := flags.Arg(0)
+
+	saved, err := readPlanFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Не удалось прочитать %s: %v\n", path, err)
+		os.Exit(exitFatal)
+	}
+	var plan []PlannedOp
+	if saved != nil {
+		if cfg.Action != ActionNone && cfg.Action != saved.Action {
+			fmt.Fprintf(os.Stderr, "❌ План %s записан для действия %s, а не %s\n", path, saved.Action, cfg.Action)
+			os.Exit(exitFatal)
+		}
+		if cfg.Interactive || cfg.TUI {
+			fmt.Fprintln(os.Stderr, "❌ В плане файлы уже выбраны: -interactive и -tui работают с файлом результатов")
+			os.Exit(exitFatal)
+		}
+		cfg.Action, cfg.Keep, plan = saved.Action, saved.Keep, saved.Operations
+	}
+	if err := validateAction(cfg.Action, cfg.Keep); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+	if cfg.Action == ActionNone {
+		fmt.Fprintln(os.Stderr, "❌ Выберите действие через -action")
+		os.Exit(exitFatal)
+	}
+
+	if saved == nil {
+		if (cfg.Interactive || cfg.TUI) && path == "-" {
+			fmt.Fprintln(os.Stderr, "❌ Результаты уже читаются из stdin, -interactive и -tui не смогут спросить выбор")
+			os.Exit(exitFatal)
+		}
+		if plan, err = planResults(cfg, path); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitFatal)
+		}
+	}
+	applyPlan(os.Stdout, cfg, plan)
+	if code := actionCode(exitDuplicates, cfg, len(plan)); code != exitNoDuplicates {
+		os.Exit(code)
+	}
+}
+
+// planResults загружает файл результатов и планирует по нему действие cfg.Action. Результаты
+// режимов, в которых группа - не обязательно копии (size_only и т.п.), отклоняются до планирования.
+func planResults(cfg Config, path string) ([]PlannedOp, error) {
+	groups, mode, err := loadResults(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать результаты %s: %w", path, err)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	if err := checkActionMode(mode); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch {
+	case cfg.TUI:
+		return RunTUI(cfg, groups, os.Stdin, os.Stdout)
+	case cfg.Interactive:
+		if err := checkInteractiveInput(os.Stdin); err != nil {
+			return nil, err
+		}
+		return ResolveInteractive(cfg, groups, os.Stdin, os.Stdout)
+	}
+	return PlanActions(cfg, groups)
+}
+
+// runCache показывает или очищает то, что scan хранит между запусками: снимок -snapshot
+// и сохраненные состояния хэшей -checkpoint-dir
+func runCache(args []string) {
+	flags := newSubcommandFlags("cache", "",
+		"Показать или очистить снимок (-snapshot) и состояния недочитанных хэшей (-checkpoint-dir).")
+	snapshot := flags.String("snapshot", "", "Файл снимка scan -snapshot")
+	checkpointDir := flags.String("checkpoint-dir", "", "Папка состояний scan -checkpoint-dir")
+	wipe := flags.Bool("clear", false, "Удалить снимок и состояния: следующий запуск прочитает все файлы заново")
+	parseSubcommand(flags, args)
+	if *snapshot == "" && *checkpointDir == "" || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(exitFatal)
+	}
+
+	code := exitNoDuplicates
+	if *snapshot != "" {
+		if err := cacheSnapshot(*snapshot, *wipe); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Снимок %s: %v\n", *snapshot, err)
+			code = exitFatal
+		}
+	}
+	if *checkpointDir != "" {
+		if err := cacheCheckpoints(*checkpointDir, *wipe); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Состояния хэшей %s: %v\n", *checkpointDir, err)
+			code = exitFatal
+		}
+	}
+	os.Exit(code)
+}
+
+// cacheSnapshot показывает или удаляет снимок
+func cacheSnapshot(path string, wipe bool) error {
+	if wipe {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		fmt.Printf("🧊 Снимок %s удален\n", path)
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	snap, err := scan.LoadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range snap.Entries {
+		total += e.Size
+	}
+	fmt.Printf("🧊 Снимок %s: %d bytes, изменен %s\n", path, info.Size(), info.ModTime().Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Корни: %s\n  Файлов с хэшем: %d (%d bytes)\n", strings.Join(snap.Roots, ", "), len(snap.Entries), total)
+	return nil
+}
+
+// cacheCheckpoints показывает или удаляет состояния хэшей
+func cacheCheckpoints(dir string, wipe bool) error {
+	checkpoints, err := scan.ListCheckpoints(dir)
+	if err != nil {
+		return err
+	}
+	if wipe {
+		removed := 0
+		for _, c := range checkpoints {
+			if err := os.Remove(c.File); err != nil {
+				return err
+			}
+			removed++
+		}
+		fmt.Printf("⏸  Удалено состояний хэшей: %d\n", removed)
+		return nil
+	}
+	fmt.Printf("⏸  Состояний недочитанных хэшей в %s: %d\n", dir, len(checkpoints))
+	for _, c := range checkpoints {
+		if c.Path == "" {
+			fmt.Printf("  ❗ %s: не читается\n", c.File)
+			continue
+		}
+		fmt.Printf("  📄 %s: прочитано %d из %d bytes\n", c.Path, c.Offset, c.Size)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+// scanDir сканирует dir в режиме mode и возвращает группы
+func scanDir(t *testing.T, dir string, mode scan.Mode) [][]scan.FileInfo {
+	t.Helper()
+	cfg := scan.DefaultConfig(dir)
+	cfg.Mode = mode
+	scanner, err := scan.NewScanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Groups
+}
+
+// saveResults сохраняет группы в файл результатов формата format (FormatJSONL или FormatSQLite)
+func saveResults(t *testing.T, format string, mode scan.Mode, groups [][]scan.FileInfo) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "results."+format)
+	var err error
+	if format == FormatSQLite {
+		err = WriteSQLite(path, mode, groups)
+	} else {
+		err = writeJSONL(path, mode, groups)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// stdinFrom подменяет os.Stdin файлом path до конца теста
+func stdinFrom(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = saved
+		f.Close()
+	})
+}
+
+// cleanSource сохраняет группы для clean: в файл формата source или, при source == "stdin",
+// в JSON Lines, подставленный вместо os.Stdin. Возвращает аргумент clean.
+func cleanSource(t *testing.T, source string, mode scan.Mode, groups [][]scan.FileInfo) string {
+	t.Helper()
+	if source != "stdin" {
+		return saveResults(t, source, mode, groups)
+	}
+	stdinFrom(t, saveResults(t, FormatJSONL, mode, groups))
+	return "-"
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		source string // FormatJSONL, FormatSQLite или stdin
+		dryRun bool
+	}{
+		{FormatJSONL, true},
+		{FormatJSONL, false},
+		{FormatSQLite, true},
+		{FormatSQLite, false},
+		{"stdin", true},
+		{"stdin", false},
+	}
+	for _, tt := range tests {
+		name := tt.source + "/execute"
+		if tt.dryRun {
+			name = tt.source + "/dry-run"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{"a.txt": "копия", "b.txt": "копия", "c.txt": "другое"}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path := cleanSource(t, tt.source, scan.ModeHash, scanDir(t, dir, scan.ModeHash))
+
+			cfg := Config{Action: ActionDelete, Keep: KeepFirst, DryRun: tt.dryRun, JournalPath: filepath.Join(t.TempDir(), "journal.jsonl")}
+			plan, err := planResults(cfg, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan) != 1 {
+				t.Fatalf("операций %d, want 1: %v", len(plan), plan)
+			}
+			var out bytes.Buffer
+			applyPlan(&out, cfg, plan)
+
+			var left []string
+			for name, content := range files {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err == nil {
+					if string(data) != content {
+						t.Errorf("%s: %q, want %q", name, data, content)
+					}
+					left = append(left, name)
+				}
+			}
+			want := 2 // Из пары копий одна удаляется
+			if tt.dryRun {
+				want = 3
+				if !strings.Contains(out.String(), "dry-run") {
+					t.Errorf("вывод dry-run:\n%s", out.String())
+				}
+			}
+			if len(left) != want {
+				t.Errorf("осталось файлов %v, want %d", left, want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "c.txt")); err != nil {
+				t.Errorf("c.txt без копий: %v", err)
+			}
+		})
+	}
+}
+
+func TestCleanRejectsSizeOnlyResults(t *testing.T) {
+	for _, format := range []string{FormatJSONL, FormatSQLite, "stdin"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			// Одинаковый размер, разное содержимое: для size_only это группа, но не копии
+			files := map[string]string{"a.txt": "aaa", "b.txt": "bbb"}
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			groups := scanDir(t, dir, scan.ModeSizeOnly)
+			if len(groups) != 1 {
+				t.Fatalf("групп %d, want 1", len(groups))
+			}
+			path := cleanSource(t, format, scan.ModeSizeOnly, groups)
+
+			cfg := Config{Action: ActionDelete}
+			plan, err := planResults(cfg, path)
+			if err == nil {
+				t.Fatalf("planResults принял результаты size_only, план %v", plan)
+			}
+			for name, content := range files {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil || string(data) != content {
+					t.Errorf("%s: %q, %v; файл не должен меняться", name, data, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Файл настроек: те же флаги, но в TOML, и профили - именованные наборы флагов; флаги командной строки важнее всего
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultConfigFile - файл настроек, который читается сам, если -config не задан
+const defaultConfigFile = "duplifinder.toml"
+
+// profilesKey - таблица профилей в файле настроек: [profiles.photos], [profiles.code]...
+const profilesKey = "profiles"
+
+// builtinProfiles - профили, которые есть без файла настроек. Профиль с тем же именем
+// в файле заменяет встроенный целиком.
+var builtinProfiles = map[string]map[string]any{
+	// Фотографии: без миниатюр, системного мусора и файлов меньше 10 КБ (иконки, превью)
+	"photos": {
+		"mode":           "hash",
+		"exclude-preset": []any{"system", "trash", "cache"},
+		"min-size":       int64(10 << 10),
+		"ignore-empty":   true,
+	},
+	// Рабочие копии репозиториев: без .git, зависимостей и результатов сборки
+	"code": {
+		"mode":           "hash",
+		"exclude-preset": []any{"vcs", "deps", "build", "cache", "system"},
+		"ignore-empty":   true,
+	},
+	// Загрузки: без недокачанных файлов; копии вида "file (1).zip" - те же файлы
+	"downloads": {
+		"mode":           "hash",
+		"exclude-preset": []any{"partial", "system", "trash"},
+		"ignore-empty":   true,
+	},
+}
+
+// loadConfigFile применяет настройки из TOML-файла: ключи - имена флагов без дефиса, значения -
+// как в командной строке ("mode = "hash"", "workers = 8", "verify = true", "tick = "200ms"");
+// флаги, которые можно повторять (-path), задаются массивом. Пустой path - ./duplifinder.toml,
+// если он есть. Неизвестный ключ - ошибка.
+//
+// Затем применяется профиль из -profile (или ключа profile в файле): таблица [profiles.имя]
+// файла или встроенный (builtinProfiles) с теми же ключами. Порядок важности: значения
+// по умолчанию, затем файл, затем профиль, затем флаги командной строки - их не трогает ни файл,
+// ни профиль. Повторяемые флаги (-exclude, -exclude-preset) не заменяются, а дополняются.
+func loadConfigFile(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile
+	}
+	var values map[string]any
+	if _, err := toml.DecodeFile(path, &values); err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("файл настроек %s: %w", path, err)
+		}
+		values = nil // Файла нет: остаются встроенные профили
+	}
+
+	profiles := make(map[string]map[string]any, len(builtinProfiles))
+	for name, profile := range builtinProfiles {
+		profiles[name] = profile
+	}
+	if table, ok := values[profilesKey]; ok {
+		fileProfiles, ok := table.(map[string]any)
+		if !ok {
+			return fmt.Errorf("файл настроек %s: %s должен быть таблицей [%s.имя]", path, profilesKey, profilesKey)
+		}
+		for name, table := range fileProfiles {
+			profile, ok := table.(map[string]any)
+			if !ok {
+				return fmt.Errorf("файл настроек %s: профиль %s должен быть таблицей [%s.%s]", path, name, profilesKey, name)
+			}
+			profiles[name] = profile
+		}
+		delete(values, profilesKey)
+	}
+
+	onCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+	if err := applyValues(values, onCommandLine); err != nil {
+		return fmt.Errorf("файл настроек %s: %w", path, err)
+	}
+
+	name := flag.Lookup("profile").Value.String()
+	if name == "" {
+		return nil
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("нет профиля %q (есть: %s)", name, strings.Join(names, ", "))
+	}
+	if _, ok := profile["profile"]; ok {
+		return fmt.Errorf("профиль %s: профиль не может выбирать другой профиль", name)
+	}
+	if err := applyValues(profile, onCommandLine); err != nil {
+		return fmt.Errorf("профиль %s: %w", name, err)
+	}
+	return nil
+}
+
+// applyValues задает флагам значения из values, кроме заданных в командной строке
+func applyValues(values map[string]any, onCommandLine map[string]bool) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f := flag.Lookup(key)
+		if f == nil || key == "config" || key == "print-config" {
+			return fmt.Errorf("неизвестный ключ %q (ключи - имена флагов, см. -help)", key)
+		}
+		if onCommandLine[key] {
+			continue
+		}
+		if err := setFlag(f, values[key]); err != nil {
+			return fmt.Errorf("ключ %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// setFlag задает флагу значение из TOML
+func setFlag(f *flag.Flag, value any) error {
+	list, ok := value.([]any)
+	if !ok {
+		text, err := flagText(value)
+		if err != nil {
+			return err
+		}
+		return f.Value.Set(text)
+	}
+	if _, repeatable := f.Value.(*pathList); !repeatable {
+		return errors.New("флаг задается один раз, массив не подходит")
+	}
+	for _, item := range list {
+		text, err := flagText(item)
+		if err != nil {
+			return err
+		}
+		if err := f.Value.Set(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagText переводит значение TOML в текст, как его написали бы в командной строке
+func flagText(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("неподходящее значение %v (нужна строка, число или true/false)", value)
+}
+
+// printConfig печатает итоговые настройки (значения по умолчанию, файл, профиль и флаги вместе)
+// в том же TOML, что читает loadConfigFile. Сам профиль не печатается: его значения уже в выводе.
+func printConfig(w io.Writer) error {
+	values := make(map[string]any)
+	flag.VisitAll(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok || f.Name == "config" || f.Name == "print-config" || f.Name == "profile" {
+			return
+		}
+		value := getter.Get()
+		switch v := value.(type) {
+		case time.Duration:
+			value = v.String()
+		case []string:
+			if v == nil {
+				value = []string{}
+			}
+		}
+		if f.Name == "serve-token" && value != "" { // Секрет не должен попасть в вывод для отладки
+			value = "***"
+		}
+		values[f.Name] = value
+	})
+	return toml.NewEncoder(w).Encode(values)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// setupFlags подменяет флаги программы небольшим набором и разбирает args, как main.
+// Прежний flag.CommandLine возвращается в конце теста.
+func setupFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	saved := flag.CommandLine
+	t.Cleanup(func() { flag.CommandLine = saved })
+	flags := flag.NewFlagSet("duplifinder", flag.ContinueOnError)
+	flags.String("mode", "hash", "")
+	flags.Int("workers", 0, "")
+	flags.Bool("verify", false, "")
+	flags.Bool("ignore-empty", false, "")
+	flags.Int64("min-size", 0, "")
+	flags.Var(new(pathList), "exclude", "")
+	flags.Var(new(pathList), "exclude-preset", "")
+	flags.String("profile", "", "")
+	flags.String("config", "", "")
+	flags.Bool("print-config", false, "")
+	flag.CommandLine = flags
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+// writeConfig пишет файл настроек и возвращает путь к нему
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "duplifinder.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	const file = `
+mode = "name_size"
+workers = 4
+verify = true
+ignore-empty = true
+exclude = ["*.tmp"]
+`
+	tests := []struct {
+		name string
+		file string // Пусто - без файла
+		args []string
+		want map[string]string // Флаг -> итоговое значение (String)
+	}{
+		{"по умолчанию", "", nil,
+			map[string]string{"mode": "hash", "workers": "0", "verify": "false", "ignore-empty": "false"}},
+		{"файл", file, nil,
+			map[string]string{"mode": "name_size", "workers": "4", "verify": "true", "ignore-empty": "true", "exclude": "*.tmp"}},
+		{"флаги важнее файла", file, []string{"-mode", "hash", "-workers", "2"},
+			map[string]string{"mode": "hash", "workers": "2", "verify": "true"}},
+		// Флаг, явно выключенный в командной строке, не включается файлом
+		{"-verify=false важнее verify = true", file, []string{"-verify=false"},
+			map[string]string{"verify": "false", "ignore-empty": "true"}},
+		{"флаг со значением по умолчанию важнее файла", file, []string{"-ignore-empty=false", "-workers=0"},
+			map[string]string{"ignore-empty": "false", "workers": "0", "verify": "true"}},
+		{"-verify без значения", "verify = false\n", []string{"-verify"},
+			map[string]string{"verify": "true"}},
+		// Повторяемый флаг из командной строки заменяет массив файла
+		{"-exclude в командной строке", file, []string{"-exclude", "*.log"},
+			map[string]string{"exclude": "*.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			if tt.file != "" {
+				path = writeConfig(t, tt.file)
+			} else {
+				t.Chdir(t.TempDir()) // Чтобы не подхватился ./duplifinder.toml
+			}
+			flags := setupFlags(t, tt.args...)
+			if err := loadConfigFile(path); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := flags.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFileProfile(t *testing.T) {
+	path := writeConfig(t, `
+profile = "big"
+workers = 4
+exclude-preset = ["vcs"]
+
+[profiles.big]
+workers = 8
+min-size = 1048576
+exclude-preset = ["cache"]
+`)
+	flags := setupFlags(t, "-min-size", "10")
+	if err := loadConfigFile(path); err != nil {
+		t.Fatal(err)
+	}
+	// Профиль важнее файла, флаги - профиля; повторяемые флаги дополняются
+	want := map[string]string{"workers": "8", "min-size": "10", "profile": "big", "exclude-preset": "vcs, cache"}
+	for name, want := range want {
+		if got := flags.Lookup(name).Value.String(); got != want {
+			t.Errorf("-%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		wantErr string
+	}{
+		{"неизвестный ключ", "wrokers = 4\n", nil, `неизвестный ключ "wrokers"`},
+		{"config в файле", `config = "other.toml"` + "\n", nil, `неизвестный ключ "config"`},
+		{"массив у обычного флага", `mode = ["hash"]` + "\n", nil, "задается один раз"},
+		{"неверное значение", `workers = "много"` + "\n", nil, "ключ workers"},
+		{"нет профиля", "", []string{"-profile", "nope"}, `нет профиля "nope"`},
+		{"профиль выбирает профиль", "[profiles.a]\nprofile = \"b\"\n", []string{"-profile", "a"}, "не может выбирать"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file)
+			setupFlags(t, tt.args...)
+			err := loadConfigFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ошибка %v, want с %q", err, tt.wantErr)
+			}
+		})
+	}
+	setupFlags(t)
+	if err := loadConfigFile(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("явно заданного файла нет, а ошибки нет")
+	}
+}
+
+func TestConfigFileAutoload(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, defaultConfigFile), []byte("workers = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	flags := setupFlags(t)
+	if err := loadConfigFile(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := flags.Lookup("workers").Value.String(); got != "3" {
+		t.Errorf("-workers = %q, want 3 из ./%s", got, defaultConfigFile)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	setupFlags(t, "-workers", "6", "-exclude", "*.tmp")
+	var out strings.Builder
+	if err := printConfig(&out); err != nil {
+		t.Fatal(err)
+	}
+	// Вывод снова читается как файл настроек и дает те же значения
+	path := writeConfig(t, out.String())
+	flags := setupFlags(t)
+	if err := loadConfigFile(path); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if got := flags.Lookup("workers").Value.String(); got != "6" {
+		t.Errorf("-workers = %q, want 6", got)
+	}
+	if got := flags.Lookup("exclude").Value.(*pathList); !slices.Equal(*got, []string{"*.tmp"}) {
+		t.Errorf("-exclude = %v, want [*.tmp]", *got)
+	}
+	for _, key := range []string{"config =", "print-config =", "profile ="} {
+		if strings.Contains(out.String(), key) {
+			t.Errorf("в выводе есть %q:\n%s", key, out.String())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Коды выхода: по ним cron и CI решают, что делать дальше
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+const (
+	exitNoDuplicates = 0   // Дубликатов нет
+	exitDuplicates   = 1   // Найдены дубликаты
+	exitReadErrors   = 2   // Работа завершена, но часть файлов прочитать не удалось
+	exitFatal        = 3   // Критическая ошибка: неверные флаги, сбой обхода, записи отчета и т.п.
+	exitPlannedOps   = 4   // -dry-run или -script: в плане есть операции, которые были бы выполнены
+	exitInterrupted  = 130 // Прервано Ctrl-C, показан неполный результат (128 + SIGINT, как у shell)
+)
+
+// exitCodeDocs - описание кодов для -help (порядок важен: от 0 и выше)
+var exitCodeDocs = []struct {
+	code int
+	doc  string
+}{
+	{exitNoDuplicates, "дубликатов нет"},
+	{exitDuplicates, "найдены дубликаты (с -action - только при -fail-on-duplicates)"},
+	{exitReadErrors, "работа завершена, но часть файлов прочитать не удалось"},
+	{exitFatal, "критическая ошибка"},
+	{exitPlannedOps, "-dry-run или -script: в плане есть операции (пустой план - 0)"},
+	{exitInterrupted, "прервано Ctrl-C, результат неполный"},
+}
+
+// usage печатает справку по флагам и кодам выхода
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Использование: %s [scan] [флаги] [папка...]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s report|clean|cache [флаги] ... (справка: %s report -help)\n\n", os.Args[0], os.Args[0])
+	fmt.Fprintln(out, "Подкоманды:")
+	fmt.Fprintln(out, "  scan    найти дубликаты (по умолчанию); результаты сохраняются через -jsonl или -sqlite")
+	fmt.Fprintln(out, "  report  показать сохраненные результаты или перевести их в другой формат")
+	fmt.Fprintln(out, "  clean   выполнить действие над дубликатами из сохраненных результатов или плана -plan-json")
+	fmt.Fprintln(out, "  cache   показать или очистить снимок -snapshot и состояния -checkpoint-dir")
+	fmt.Fprintln(out, "\nФлаги scan:")
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\nКоды выхода:")
+	for _, c := range exitCodeDocs {
+		fmt.Fprintf(out, "  %3d  %s\n", c.code, c.doc)
+	}
+}
+
+// resultCode выбирает код выхода по итогам сканирования.
+// Ошибки чтения важнее найденных дубликатов: результат мог оказаться неполным.
+func resultCode(stats scan.Stats, groups int, interrupted bool) int {
+	switch {
+	case interrupted:
+		return exitInterrupted
+	case stats.Errors > 0:
+		return exitReadErrors
+	case groups > 0:
+		return exitDuplicates
+	}
+	return exitNoDuplicates
+}
+
+// actionCode уточняет код выхода после -action: дубликаты уже обработаны, и код говорит об итоге
+// действия - exitPlannedOps, если -dry-run или -script оставили операции невыполненными, иначе 0.
+// С -fail-on-duplicates найденные дубликаты остаются exitDuplicates (ночная проверка архива,
+// который должен быть без копий). Ошибки чтения и прерывание (уже в code) важнее.
+func actionCode(code int, cfg Config, planned int) int {
+	if code != exitDuplicates || cfg.FailOnDuplicates {
+		return code
+	}
+	if cfg.planOnly() && planned > 0 {
+		return exitPlannedOps
+	}
+	return exitNoDuplicates
+}
@@ -0,0 +1,79 @@
+// Фильтры файлов из командной строки: шаблоны исключений, готовые наборы служебных папок, границы размера
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// junkPresets - готовые наборы служебных файлов и папок для -exclude-preset: шаблоны имен,
+// как у -exclude
+var junkPresets = map[string][]string{
+	"vcs":     {".git", ".hg", ".svn", ".bzr"},
+	"deps":    {"node_modules", "bower_components", "vendor", ".venv", "venv", "site-packages", ".gradle", ".m2"},
+	"build":   {"build", "dist", "target", "out", "bin", "obj", "__pycache__", "*.pyc", "*.o", "*.class"},
+	"cache":   {".cache", ".npm", ".yarn", ".pytest_cache", ".mypy_cache", ".tox", ".thumbnails", ".idea", ".vscode"},
+	"trash":   {".Trash", ".Trash-*", "$RECYCLE.BIN", ".recycle"},
+	"system":  {".DS_Store", "Thumbs.db", "desktop.ini", "System Volume Information", ".Spotlight-V100", ".fseventsd", "lost+found"},
+	"partial": {"*.part", "*.crdownload", "*.download", "*.partial", "*.tmp", "~$*"},
+}
+
+// presetNames возвращает имена наборов по алфавиту (для сообщений об ошибке и -help)
+func presetNames() []string {
+	names := make([]string, 0, len(junkPresets))
+	for name := range junkPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileFilter собирает фильтр для scan.Config.FileFilter: файл пропускается, если его имя или имя
+// любой папки на пути к нему от корня из roots подходит под шаблон из excludes или из наборов
+// presets, либо размер вне [minSize, maxSize] (0 - без границы). Папки выше корня не проверяются:
+// -exclude-preset build не отбрасывает все, если сам корень лежит в build. nil - фильтровать нечего.
+func fileFilter(roots, excludes, presets []string, minSize, maxSize int64) (func(path string, info fs.FileInfo) bool, error) {
+	patterns := slices.Clone(excludes)
+	for _, name := range presets {
+		preset, ok := junkPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("неизвестный набор -exclude-preset %q (есть: %s)", name, strings.Join(presetNames(), ", "))
+		}
+		patterns = append(patterns, preset...)
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("неверный шаблон -exclude %q: %w", pattern, err)
+		}
+	}
+	if minSize < 0 || maxSize < 0 || (maxSize > 0 && minSize > maxSize) {
+		return nil, fmt.Errorf("неверные границы размера: -min-size %d, -max-size %d", minSize, maxSize)
+	}
+	if len(patterns) == 0 && minSize == 0 && maxSize == 0 {
+		return nil, nil
+	}
+
+	return func(path string, info fs.FileInfo) bool {
+		if info.Size() < minSize || (maxSize > 0 && info.Size() > maxSize) {
+			return false
+		}
+		rel := path
+		for _, root := range roots {
+			if r, err := filepath.Rel(root, path); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) && len(r) < len(rel) {
+				rel = r
+			}
+		}
+		for _, part := range strings.FieldsFunc(filepath.ToSlash(rel), func(r rune) bool { return r == '/' }) {
+			for _, pattern := range patterns {
+				if ok, _ := filepath.Match(pattern, part); ok {
+					return false
+				}
+			}
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,105 @@
+// Интерактивный выбор файла, который остается в каждой группе дубликатов
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BatrazG/duplifinder/scan"
+	"golang.org/x/term"
+)
+
+// ErrNotInteractive - стандартный ввод не является терминалом
+var ErrNotInteractive = errors.New("интерактивный режим требует терминал на стандартном вводе")
+
+// checkInteractiveInput проверяет до начала сканирования, что ответы можно читать с терминала.
+// Иначе (pipe, /dev/null) вопросы ушли бы в пустоту.
+func checkInteractiveInput(f *os.File) error {
+	if !term.IsTerminal(int(f.Fd())) {
+		return ErrNotInteractive
+	}
+	return nil
+}
+
+// Ответы пользователя, кроме номера файла
+const (
+	answerSkip     = "s" // Пропустить группу
+	answerApplyAll = "a" // Применить стратегию по умолчанию к этой и оставшимся группам
+	answerQuit     = "q" // Закончить: выбранное ранее будет выполнено
+)
+
+// ResolveInteractive спрашивает по каждой группе, какой файл оставить, и строит план
+// для того же исполнителя, что и автоматический режим (ExecutePlan).
+// Группы идут от самых "дорогих" (больше всего освобождаемого места) к дешевым.
+// Если ввод закончился (EOF), возвращается ошибка и ничего не выполняется.
+func ResolveInteractive(cfg Config, groups [][]scan.FileInfo, in io.Reader, out io.Writer) ([]PlannedOp, error) {
+	ordered := make([][]scan.FileInfo, len(groups))
+	copy(ordered, groups)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return scan.Reclaimable(ordered[i]) > scan.Reclaimable(ordered[j])
+	})
+
+	op := opName(cfg.Action)
+	reader := bufio.NewReader(in)
+	var plan []PlannedOp
+
+	for gi, group := range ordered {
+		fmt.Fprintf(out, "\nГруппа %d из %d (можно освободить %d bytes)\n", gi+1, len(ordered), scan.Reclaimable(group))
+		for i, f := range group {
+			fmt.Fprintf(out, "  [%d] %d bytes  %s  %s\n", i+1, f.Size, f.ModTime.Format("2006-01-02 15:04"), f.Path)
+		}
+
+		answer, keepIndex, err := askGroup(reader, out, len(group))
+		if err != nil {
+			return nil, err
+		}
+		switch answer {
+		case answerSkip:
+			continue
+		case answerQuit:
+			return plan, nil
+		case answerApplyAll:
+			for _, rest := range ordered[gi:] {
+				plan = append(plan, planGroup(rest, cfg.Keep, op)...)
+			}
+			return plan, nil
+		}
+
+		kept := group[keepIndex]
+		for i, f := range group {
+			if i != keepIndex && !f.ReadOnly { // Запись архива удалить нельзя
+				plan = append(plan, newPlannedOp(op, f, kept))
+			}
+		}
+	}
+	return plan, nil
+}
+
+// askGroup повторяет вопрос, пока не получит корректный ответ.
+// Возвращает либо одну из команд (s/a/q), либо индекс файла, который нужно оставить.
+func askGroup(reader *bufio.Reader, out io.Writer, size int) (string, int, error) {
+	for {
+		fmt.Fprintf(out, "Оставить [1-%d], %s - пропустить, %s - по умолчанию для остальных, %s - закончить: ",
+			size, answerSkip, answerApplyAll, answerQuit)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", 0, fmt.Errorf("ввод прерван: %w", err)
+		}
+
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch answer {
+		case answerSkip, answerApplyAll, answerQuit:
+			return answer, 0, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= size {
+			return "", n - 1, nil
+		}
+		fmt.Fprintln(out, "Непонятный ответ, попробуйте еще раз")
+	}
+}
@@ -0,0 +1,233 @@
+// Журнал разрушающих операций (JSON Lines) и отмена по нему
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// opCommit - последняя запись журнала: запуск завершился штатно
+const opCommit = "COMMIT"
+
+// Состояния операции в журнале: намерение пишется до изменения на диске, итог - после.
+// Запись без состояния (журналы прошлых версий) - выполненная операция.
+const (
+	stateIntent = "intent"
+	stateDone   = "done"
+	stateFailed = "failed"
+)
+
+// JournalEntry - одна строка журнала
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`               // DELETE, TRASH, HARDLINK или COMMIT
+	State  string    `json:"state,omitempty"`  // intent, done или failed (см. stateIntent)
+	Path   string    `json:"path,omitempty"`   // Исходный путь файла
+	Target string    `json:"target,omitempty"` // Куда перемещен файл (путь в корзине)
+	Kept   string    `json:"kept,omitempty"`   // Оставленная копия
+	Size   int64     `json:"size,omitempty"`
+	Hash   string    `json:"hash,omitempty"`
+}
+
+// Journal дописывает записи в файл без буферизации: каждая запись сразу
+// сбрасывается на диск, чтобы после сбоя посреди запуска журнал оставался точным
+type Journal struct {
+	file *os.File
+}
+
+// OpenJournal открывает журнал на дозапись (файл создается при необходимости)
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: f}, nil
+}
+
+// defaultJournalPath возвращает путь журнала по умолчанию в пользовательском кэше
+func defaultJournalPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	name := "journal-" + time.Now().Format("20060102-150405") + ".jsonl"
+	return filepath.Join(dir, "duplifinder", name), nil
+}
+
+// Record записывает операцию и дожидается сброса на диск
+func (j *Journal) Record(entry JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Commit пишет финальную запись о штатном завершении и закрывает журнал
+func (j *Journal) Commit() error {
+	if err := j.Record(JournalEntry{Op: opCommit}); err != nil {
+		j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
+
+// UndoReport - итог отмены по журналу
+type UndoReport struct {
+	Restored      []string      // Файлы, возвращенные на место
+	Failed        []ActionError // Файлы, которые вернуть не удалось
+	Irreversible  []string      // Безвозвратно удаленные файлы
+	Unfinished    []string      // Операции, начатые без записанного итога: сбой посреди операции, файл мог остаться, а мог и пропасть
+	Uncommitted   bool          // В журнале нет записи COMMIT: запуск был прерван
+	EntriesParsed int           // Сколько операций прочитано из журнала
+}
+
+// Undo отменяет операции из журнала в обратном порядке.
+// Перемещенные в корзину файлы возвращаются на место (если путь свободен),
+// удаления отменить нельзя - они перечисляются в отчете.
+func Undo(journalPath string) (UndoReport, error) {
+	f, err := os.Open(journalPath)
+	if err != nil {
+		return UndoReport{}, err
+	}
+	defer f.Close()
+
+	var report UndoReport
+	var entries, intents []JournalEntry
+	committed := false
+	badLine := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		// Поврежденной может быть только последняя строка (оборвалась при сбое) -
+		// это не повод отказываться от отмены. Испорченная середина - повод.
+		if badLine != 0 {
+			return report, fmt.Errorf("строка %d журнала повреждена", badLine)
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			badLine = line
+			continue
+		}
+		switch {
+		case e.Op == opCommit:
+			committed = true
+		case e.State == stateIntent:
+			intents = append(intents, e)
+		default:
+			// Итог операции закрывает ее намерение; отменять есть что только после done
+			intents = slices.DeleteFunc(intents, func(i JournalEntry) bool { return i.Path == e.Path })
+			if e.State != stateFailed {
+				entries = append(entries, e)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return report, err
+	}
+	for _, e := range intents {
+		report.Unfinished = append(report.Unfinished, e.Path)
+	}
+	report.EntriesParsed = len(entries)
+	report.Uncommitted = !committed
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		switch e.Op {
+		case "DELETE":
+			report.Irreversible = append(report.Irreversible, e.Path)
+		case "TRASH":
+			if err := undoTrash(e); err != nil {
+				report.Failed = append(report.Failed, ActionError{Path: e.Path, Err: err})
+				continue
+			}
+			report.Restored = append(report.Restored, e.Path)
+		case "HARDLINK":
+			if err := undoLink(e); err != nil {
+				report.Failed = append(report.Failed, ActionError{Path: e.Path, Err: err})
+				continue
+			}
+			report.Restored = append(report.Restored, e.Path)
+		default:
+			report.Failed = append(report.Failed, ActionError{Path: e.Path, Err: fmt.Errorf("неизвестная операция %q", e.Op)})
+		}
+	}
+	return report, nil
+}
+
+// undoLink снова делает путь отдельным файлом: содержимое то же, что у оставленной копии,
+// так что ссылка заменяется копией (время изменения и права - как у оставленного файла)
+func undoLink(e JournalEntry) error {
+	info, err := os.Lstat(e.Path)
+	if err != nil {
+		return err
+	}
+	kept, err := os.Stat(e.Kept)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, kept) {
+		return fmt.Errorf("путь уже не ссылка на %s", e.Kept)
+	}
+	src, err := os.Open(e.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := e.Path + ".duplifinder-undo"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, e.Path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// undoTrash возвращает файл из корзины, не перезаписывая то, что появилось на его месте
+func undoTrash(e JournalEntry) error {
+	if e.Target == "" {
+		// Путь в корзине неизвестен (Windows): restoreFromTrash объяснит, что делать
+		return restoreFromTrash(e.Target, e.Path)
+	}
+	if _, err := os.Lstat(e.Path); err == nil {
+		return fmt.Errorf("исходный путь уже занят")
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
+		return err
+	}
+	return restoreFromTrash(e.Target, e.Path)
+}
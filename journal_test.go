@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// readJournal возвращает записи журнала по порядку
+func readJournal(t *testing.T, path string) []JournalEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []JournalEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestExecutePlanJournalsIntentFirst(t *testing.T) {
+	dir := t.TempDir()
+	kept, dup := filepath.Join(dir, "kept.txt"), filepath.Join(dir, "dup.txt")
+	for _, path := range []string{kept, dup} {
+		if err := os.WriteFile(path, []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	journalPath := filepath.Join(dir, "journal.jsonl")
+	journal, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := []PlannedOp{
+		{Op: "DELETE", Path: dup, Kept: kept, Size: 4, KeptSize: 4},
+		{Op: "DELETE", Path: filepath.Join(dir, "missing.txt"), Kept: kept, Size: 4, KeptSize: 4},
+	}
+	report, err := ExecutePlan(plan, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Done) != 1 || len(report.Failed) != 1 {
+		t.Fatalf("report = %+v, want 1 done, 1 failed", report)
+	}
+
+	var got []string
+	for _, e := range readJournal(t, journalPath) {
+		got = append(got, e.Op+" "+e.State)
+	}
+	// Файл, не прошедший проверку перед действием, не трогался: о нем в журнале ничего нет
+	want := []string{"DELETE intent", "DELETE done", "COMMIT "}
+	if !slices.Equal(got, want) {
+		t.Errorf("журнал = %q, want %q", got, want)
+	}
+}
+
+func TestUndoReportsUnfinished(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "journal.jsonl")
+	journal, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Сбой посреди второй операции: намерение записано, итог - нет
+	for _, e := range []JournalEntry{
+		{Op: "DELETE", State: stateIntent, Path: "/data/a"},
+		{Op: "DELETE", State: stateDone, Path: "/data/a"},
+		{Op: "DELETE", State: stateIntent, Path: "/data/b"},
+		{Op: "DELETE", State: stateFailed, Path: "/data/b"},
+		{Op: "TRASH", State: stateIntent, Path: "/data/c"},
+	} {
+		if err := journal.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	journal.file.Close()
+
+	report, err := Undo(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Uncommitted {
+		t.Error("запуск без COMMIT не помечен прерванным")
+	}
+	if !slices.Equal(report.Irreversible, []string{"/data/a"}) {
+		t.Errorf("Irreversible = %q, want [/data/a]", report.Irreversible)
+	}
+	if !slices.Equal(report.Unfinished, []string{"/data/c"}) {
+		t.Errorf("Unfinished = %q, want [/data/c]", report.Unfinished)
+	}
+}
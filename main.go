@@ -2,88 +2,928 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/BatrazG/duplifinder/scan"
+	"golang.org/x/term"
 )
 
-// Config хранит настройки, полученные из флагов командной строки
+// Config хранит настройки, полученные из флагов командной строки:
+// настройки сканера и то, что делать с найденным
 type Config struct {
-	DirPath string        // Путь для сканирования
-	Mode    string        // Режим: name_size, hash, combined
-	Workers int           // Количество горутин
-	Tick    time.Duration // Интервал обновления процесса
+	scan.Config
+
+	Tick  time.Duration // Интервал обновления процесса
+	Quiet bool          // Печатать только ошибки; итог - в коде выхода
+
+	FailOnDuplicates bool // Код exitDuplicates при найденных дубликатах и с -action (см. actionCode)
+	Top              int  // Вывести только N групп с наибольшим объемом лишних копий (0 - все)
+	ByExt            bool // Показать, сколько лишнего места приходится на каждое расширение
+
+	// Действия над дубликатами
+	Action      string       // Действие над дубликатами: "" (только отчет), delete, trash, hardlink
+	Keep        KeepStrategy // Какие файлы группы оставить: first, per_dir
+	DryRun      bool         // Только показать план действий, ничего не меняя на диске
+	PlanOut     string       // Путь для JSON-экспорта плана действий (пусто - не сохранять)
+	Interactive bool         // Спрашивать по каждой группе, какой файл оставить
+	TUI         bool         // Полноэкранный выбор файлов для действия
+	ScriptOut   string       // Вместо выполнения записать план в POSIX-скрипт
+	ScriptPSOut string       // Вместо выполнения записать план в PowerShell-скрипт
+	JournalPath string       // Журнал выполненных операций (пусто - файл в кэше пользователя)
+
+	// Экспорт результатов
+	SQLiteOut   string // Сохранить результаты в базу SQLite
+	JSONLOut    string // Сохранить группы в JSON Lines ("-" - stdout)
+	ChecksumsIn string // Файл sha256sum для проверки целостности файлов найденных групп
+	ResultsIn   string // Вместо сканирования загрузить группы из базы SQLite (для -tui-from)
+	ManifestOut string // Записать манифест всех файлов (с полными хэшами) вместо поиска дубликатов
+	Snapshot    string // Снимок для инкрементального поиска: хэши берутся из него, новый записывается поверх
+	MetricsOut  string // Файл метрик Prometheus для textfile collector (пусто - не писать)
 }
 
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if run, ok := subcommands[args[0]]; ok {
+			run(args[1:])
+			return
+		}
+		if args[0] == "scan" {
+			args = args[1:]
+		}
+	}
+
 	// 1. Парсинг флагов (настройка CLI)
-	pathPtr := flag.String("path", ".", "Путь к директории для сканирования")
-	modePtr := flag.String("mode", "hash", "Режим поиска: name_size (имя+размер), hash (содержимое), combined (имя+размер+хэш)")
-	workersPtr := flag.Int("workers", 8, "Количество конкурентных воркеров для чтения файлов")
+	var paths pathList
+	flag.Var(&paths, "path", "Путь к директории для сканирования, s3://bucket/prefix или sftp://user@host/path (можно указать несколько раз; по умолчанию .)")
+	skipMissingPtr := flag.Bool("skip-missing", false, "Не останавливаться, если пути -path нет или это не папка (например, диск не подключен): он считается ошибкой чтения")
+	crossPtr := flag.Bool("cross", false, "Только дубликаты между разными путями -path (внутри одного пути не показываются)")
+	redundantPtr := flag.String("redundant-in", "", "Показать файлы из этого пути -path, которые уже есть в других путях (их можно удалять)")
+	var manifests pathList
+	flag.Var(&manifests, "compare-manifest", "Сравнить с манифестом: его записи ищутся как копии наравне с файлами (можно указать несколько раз; без -path сравниваются только манифесты)")
+	filesFromPtr := flag.String("files-from", "", "Не обходить папки, а взять список файлов из этого файла (- - из stdin): пути через перевод строки или NUL, как у find -print0")
+	uniquePtr := flag.Bool("unique", false, "Показать файлы, у которых нет ни одной копии (с учетом -mode), вместо дубликатов")
+	dupDirsPtr := flag.Bool("dup-dirs", false, "Показать папки-копии: все файлы папки есть в одной другой папке")
+	dupTreesPtr := flag.Bool("dup-trees", false, "Показать одинаковые деревья папок (с подпапками) вместо тысяч групп их файлов")
+	partialPtr := flag.Bool("partial", false, "Показать недокачанные копии: файлы от 4 КБ, содержимое которых - начало другого, большего файла")
+	dirFilterPtr := flag.String("dirs", "", "Фильтр по директориям: within (копии в одной папке), across (в разных папках), across_top (в разных папках верхнего уровня)")
+	minCopiesPtr := flag.Int("min-copies", 2, "Показать только группы, где файлов не меньше N (например 3 - файл и хотя бы две копии)")
+	topPtr := flag.Int("top", 0, "Показать только N групп, занимающих больше всего лишнего места (0 - все группы)")
+	byExtPtr := flag.Bool("by-ext", false, "Показать лишнее место по расширениям файлов (.jpg, .txt...)")
+	sortPtr := flag.String("sort", scan.SortReclaimable, "Порядок групп: reclaimable (освобождаемое место), size (размер файла), count (число копий), path (путь)")
+	sortReversePtr := flag.Bool("sort-reverse", false, "Обратный порядок групп")
+	modePtr := flag.String("mode", string(scan.ModeHash), "Режим поиска: name_only (только имя, содержимое не сравнивается), name_size (имя+размер), size_only (только размер: быстрая оценка, есть ли смысл хэшировать), name_count (имена, повторяющиеся не меньше -name-count раз, где бы ни лежали), hash (содержимое), fast_hash (размер+первые и последние 4КБ, возможны ложные совпадения), combined (имя+хэш), name_size_hash (имя+размер+хэш), name_hash_dir (имя+хэш+имя родительской папки), image (похожие изображения JPEG/PNG/GIF/WebP: пересохраненные, уменьшенные, пережатые), compare (содержимое побайтово, блоками: разные файлы одного размера отсеиваются по первым байтам, без хэша), chunks (не группы, а оценка, сколько места освободила бы дедупликация блоками ~1 МБ, например на ZFS или Btrfs)")
+	imageDistancePtr := flag.Int("image-distance", scan.DefaultImageDistance, "В режиме image: насколько могут различаться похожие картинки (расстояние Хэмминга между 64-битными хэшами, 0-64)")
+	nameCountPtr := flag.Int("name-count", 0, "В режиме name_count: показать имена, встречающиеся не меньше N раз (0 - от двух)")
+	contentOnlyPtr := flag.Bool("content-only", false, "Сравнивать JPEG, PNG, MP3, FLAC и PDF без метаданных: фото с разным EXIF и песни с разными тегами - копии (режимы с полным хэшем)")
+	verifyPtr := flag.Bool("verify", false, "В режиме fast_hash перепроверить найденные группы полным хэшем")
+	normalizeNamesPtr := flag.Bool("normalize-names", true, "Сравнивать имена файлов после нормализации Unicode (NFC): \"é\" из macOS и Linux - одно имя")
+	fuzzyNamesPtr := flag.Bool("fuzzy-names", false, "Сравнивать имена без примет копии: photo (1).jpg, photo - Copy.jpg, Copy of photo.jpg - это photo.jpg")
+	sameNamePtr := flag.Bool("same-name", false, "В режимах hash, fast_hash, size_only и compare копии - только файлы с одинаковым именем (как name_size_hash)")
+	ignoreExtPtr := flag.Bool("ignore-extension", false, "Сравнивать имена без расширения: song.mp3 и song.flac - одно имя (в режимах с именем, например combined)")
+	ignoreCasePtr := flag.Bool("ignore-case", scan.DefaultConfig("").CaseInsensitiveNames, "Не различать регистр в именах файлов (режимы с именем); по умолчанию включено на Windows и macOS")
+	ignoreHardlinksPtr := flag.Bool("ignore-hardlinks", true, "Считать жесткие ссылки на один файл одним файлом, а не дубликатами (удаление ссылки не освобождает места)")
+	showHardlinksPtr := flag.Bool("show-hardlinks", false, "Показать отдельным списком пути, которые ведут к одному файлу (жесткие ссылки)")
+	annotateHardlinksPtr := flag.Bool("annotate-hardlinks", false, "Вместо -ignore-hardlinks: не показывать группы из одних жестких ссылок на один файл, а в остальных показать ссылки с пометкой")
+	archivesPtr := flag.Bool("archives", false, "Искать и внутри .zip, .tar, .tar.gz: записи показываются как архив.zip!/путь и никогда не удаляются")
+	minePtr := flag.Bool("mine", false, "Искать только среди своих файлов (владелец - текущий пользователь; только unix)")
+	ignoreEmptyPtr := flag.Bool("ignore-empty", false, "Не искать дубликаты среди пустых (0 байт) файлов")
+	var excludes, excludePresets pathList
+	flag.Var(&excludes, "exclude", "Не брать файлы и папки, имя которых подходит под шаблон: *.tmp, node_modules (можно несколько раз)")
+	flag.Var(&excludePresets, "exclude-preset", "Не брать служебные файлы и папки из готового набора: "+strings.Join(presetNames(), ", ")+" (можно несколько раз)")
+	minSizePtr := flag.Int64("min-size", 0, "Не брать файлы меньше N байт")
+	maxSizePtr := flag.Int64("max-size", 0, "Не брать файлы больше N байт (0 - без ограничения)")
+	workersPtr := flag.Int("workers", 0, "Количество конкурентных воркеров для чтения файлов (0 - подобрать по накопителю: 1 на HDD, по ядру на SSD, больше для S3 и SFTP)")
+	retriesPtr := flag.Int("read-retries", 2, "Сколько раз повторить чтение файла при временной ошибке (EIO, таймаут сетевого диска)")
+	checkpointDirPtr := flag.String("checkpoint-dir", "", "Сохранять в эту папку состояние хэша больших файлов: после обрыва или Ctrl-C чтение продолжится с того же места")
+	fileTimeoutPtr := flag.Duration("file-timeout", 0, "Пропускать файл, если его не удалось прочитать за это время (например 2m; 0 - ждать сколько угодно): зависший сетевой диск не остановит поиск")
+	maxRatePtr := flag.Int64("max-read-rate", 0, "Предел скорости чтения всеми воркерами вместе, МБ/с (0 - без ограничения): чтобы не загружать общий NAS")
+	diskPtr := flag.String("disk", scan.DiskAuto, "Тип накопителя: ssd, hdd (файлы читаются по одному, без метаний головки), auto (определить)")
 	tickPtr := flag.Duration("tick", 500*time.Millisecond, "Интервал обновления прогресса (например 500ms)")
+	actionPtr := flag.String("action", "", "Действие над дубликатами: delete (удалить), trash (в корзину), hardlink (заменить жесткой ссылкой на оставляемый файл)")
+	dryRunPtr := flag.Bool("dry-run", false, "Показать план действий без изменений на диске")
+	planOutPtr := flag.String("plan-json", "", "Сохранить план действий в JSON-файл")
+	interactivePtr := flag.Bool("interactive", false, "Спрашивать по каждой группе, какой файл оставить (нужны -action и терминал)")
+	tuiPtr := flag.Bool("tui", false, "Просмотреть группы на весь экран терминала, отметить файлы и выполнить -action над ними")
+	tuiFromPtr := flag.String("tui-from", "", "Открыть в режиме -tui результаты из базы -sqlite прошлого запуска, без сканирования")
+	scriptPtr := flag.String("script", "", "Не выполнять действия, а записать их в POSIX sh-скрипт для проверки")
+	scriptPSPtr := flag.String("script-ps", "", "Не выполнять действия, а записать их в PowerShell-скрипт для проверки")
+	snapshotPtr := flag.String("snapshot", "", "Инкрементальный поиск для регулярных запусков: не читать файлы, не изменившиеся со снимка в этом файле, и сохранить в него новый снимок")
+	manifestOutPtr := flag.String("write-manifest", "", "Записать манифест (путь, размер, время изменения, хэш) всех файлов и выйти")
+	checksumsPtr := flag.String("verify-checksums", "", "Сверить файлы найденных групп с файлом контрольных сумм sha256sum и показать несовпадения")
+	metricsOutPtr := flag.String("metrics-file", "", "После сканирования записать метрики Prometheus в этот файл (для textfile collector node_exporter)")
+	jsonlPtr := flag.String("jsonl", "", "Сохранить найденные группы в JSON Lines: по объекту на строку (- для stdout, обычно вместе с -quiet)")
+	sqlitePtr := flag.String("sqlite", "", "Сохранить найденные группы в базу SQLite (таблицы groups и files)")
+	journalPtr := flag.String("journal", "", "Куда писать журнал выполненных действий (по умолчанию - в кэш пользователя)")
+	undoPtr := flag.String("undo", "", "Отменить действия по журналу и выйти (файлы из корзины возвращаются на место)")
+	servePtr := flag.String("serve", "", "Не сканировать, а запустить JSON HTTP API на этом адресе (например :8080): POST /scans, GET /scans/{id}, GET /scans/{id}/groups, DELETE /scans/{id}")
+	serveTokenPtr := flag.String("serve-token", "", "Bearer-токен для -serve (по умолчанию - из DUPLIFINDER_TOKEN)")
+	serveMaxPtr := flag.Int("serve-max-scans", 2, "Сколько сканирований -serve идет одновременно")
+	serveTTLPtr := flag.Duration("serve-ttl", time.Hour, "Сколько -serve хранит итог завершенного сканирования")
+	keepPtr := flag.String("keep", string(KeepFirst), "Какие файлы оставить: first (один на группу), per_dir (по одному в каждой директории)")
+
+	quietPtr := flag.Bool("quiet", false, "Ничего не печатать, кроме ошибок: результат - в коде выхода (см. ниже)")
+	failOnDupsPtr := flag.Bool("fail-on-duplicates", false, "Завершаться с кодом 1 при найденных дубликатах и с -action, -dry-run (без -action код 1 и так)")
+	logLevelPtr := flag.String("log-level", "", "Журнал работы в stderr: error, warn (ошибки чтения), info (фазы), debug (пропущенные пути); по умолчанию выключен")
+	logFormatPtr := flag.String("log-format", scan.LogFormatText, "Формат журнала: text, json")
+	configPtr := flag.String("config", "", "Файл настроек TOML: ключи - имена флагов (по умолчанию читается ./"+defaultConfigFile+", если он есть); флаги командной строки важнее файла")
+	flag.String("profile", "", "Готовый набор флагов: photos, code, downloads или [profiles.имя] из файла настроек; флаги командной строки важнее профиля")
+	printConfigPtr := flag.Bool("print-config", false, "Напечатать итоговые настройки (по умолчанию + файл + флаги) в TOML и выйти")
 
 	//Читаем аргументы
-	flag.Parse()
+	// Ошибка в флагах - это код exitFatal, а не 2 по умолчанию у пакета flag (2 занят ошибками чтения)
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.Usage = usage
+	if err := flag.CommandLine.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(exitFatal)
+	}
+	// Папки после флагов - то же, что -path: duplifinder ~/photos ~/backup
+	for _, dir := range flag.Args() {
+		flag.Set("path", dir)
+	}
+	if err := loadConfigFile(*configPtr); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+
+	if len(paths) == 0 && len(manifests) == 0 && *filesFromPtr == "" {
+		paths = pathList{"."}
+	}
+	if *printConfigPtr {
+		if err := printConfig(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitFatal)
+		}
+		return
+	}
+	if *undoPtr != "" {
+		runUndo(*undoPtr)
+		return
+	}
+	if *servePtr != "" {
+		token := *serveTokenPtr
+		if token == "" { // Не значение флага по умолчанию: оно попало бы в -help
+			token = os.Getenv("DUPLIFINDER_TOKEN")
+		}
+		runServe(ServeConfig{Addr: *servePtr, Token: token, MaxScans: *serveMaxPtr, TTL: *serveTTLPtr})
+		return
+	}
+	mode, err := scan.ParseMode(*modePtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+	filesFrom, err := openFilesFrom(*filesFromPtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+	filter, err := fileFilter(paths, excludes, excludePresets, *minSizePtr, *maxSizePtr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
 
 	cfg := Config{
-		DirPath: *pathPtr,
-		Mode:    *modePtr,
-		Workers: *workersPtr,
-		Tick:    *tickPtr,
+		Config: scan.Config{
+			Roots:            paths,
+			SkipMissingRoots: *skipMissingPtr,
+			Manifests:        manifests,
+
+			CrossRoot:             *crossPtr,
+			RedundantIn:           *redundantPtr,
+			ReportUnique:          *uniquePtr,
+			DirFilter:             *dirFilterPtr,
+			DirDuplicates:         *dupDirsPtr,
+			DirTrees:              *dupTreesPtr,
+			FindPrefixes:          *partialPtr,
+			SortBy:                *sortPtr,
+			SortReverse:           *sortReversePtr,
+			Mode:                  mode,
+			Verify:                *verifyPtr,
+			ContentOnly:           *contentOnlyPtr,
+			NameCountThreshold:    *nameCountPtr,
+			ImageDistance:         *imageDistancePtr,
+			MinGroupSize:          *minCopiesPtr,
+			IgnoreEmptyFiles:      *ignoreEmptyPtr,
+			OnlyCurrentUser:       *minePtr,
+			NormalizeNames:        *normalizeNamesPtr,
+			IgnoreHardlinks:       *ignoreHardlinksPtr && !*annotateHardlinksPtr,
+			AnnotateHardlinks:     *annotateHardlinksPtr,
+			ScanArchives:          *archivesPtr,
+			FuzzyNames:            *fuzzyNamesPtr,
+			IgnoreExtensionInName: *ignoreExtPtr,
+			RequireSameName:       *sameNamePtr,
+			CaseInsensitiveNames:  *ignoreCasePtr,
+			Workers:               *workersPtr,
+			DiskType:              *diskPtr,
+			FileFilter:            filter,
+			FilesFrom:             filesFrom,
+			ReadRetries:           *retriesPtr,
+			FileTimeout:           *fileTimeoutPtr,
+			CheckpointDir:         *checkpointDirPtr,
+			MaxBytesPerSecond:     *maxRatePtr << 20,
+			LogLevel:              *logLevelPtr,
+			LogFormat:             *logFormatPtr,
+		},
+		Tick:  *tickPtr,
+		Quiet: *quietPtr,
+
+		FailOnDuplicates: *failOnDupsPtr,
+		Top:              *topPtr,
+		ByExt:            *byExtPtr,
+		Action:           *actionPtr,
+		Keep:             KeepStrategy(*keepPtr),
+		DryRun:           *dryRunPtr,
+		PlanOut:          *planOutPtr,
+
+		Interactive: *interactivePtr,
+		TUI:         *tuiPtr || *tuiFromPtr != "",
+		ResultsIn:   *tuiFromPtr,
+		ScriptOut:   *scriptPtr,
+		ScriptPSOut: *scriptPSPtr,
+		JournalPath: *journalPtr,
+		SQLiteOut:   *sqlitePtr,
+		JSONLOut:    *jsonlPtr,
+		ChecksumsIn: *checksumsPtr,
+		ManifestOut: *manifestOutPtr,
+		Snapshot:    *snapshotPtr,
+		MetricsOut:  *metricsOutPtr,
 	}
 
-	fmt.Printf("🚀 Запуск DupliFinder\n📂 Папка: %s\n⚙ Режим: %s\n👷‍♂️👷‍♀️ Воркеров: %d\n\n", cfg.DirPath, cfg.Mode, cfg.Workers)
+	// В тихом режиме печатаются только ошибки (в stderr), итог - в коде выхода
+	var out io.Writer = os.Stdout
+	if cfg.Quiet {
+		out = io.Discard
+	} else {
+		// Прогресс рисуется из колбэка сканера: в терминале - одной перерисовываемой строкой
+		cfg.OnProgress = newProgressPrinter(cfg.Tick).update
+	}
+	// NewScanner заодно проверяет настройки сканирования
+	scanner, err := scan.NewScanner(cfg.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+	if err := validateAction(cfg.Action, cfg.Keep); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+	if len(cfg.Manifests) > 0 && cfg.Action != ActionNone {
+		fmt.Fprintln(os.Stderr, "❌ Записи манифеста - не локальные файлы, действия с -compare-manifest не применяются")
+		os.Exit(exitFatal)
+	}
+	if cfg.Action != ActionNone && slices.ContainsFunc(cfg.Roots, func(root string) bool { return strings.HasPrefix(root, "s3://") }) {
+		fmt.Fprintln(os.Stderr, "❌ Объекты S3 только читаются, действия с корнями s3:// не применяются")
+		os.Exit(exitFatal)
+	}
+	if cfg.ManifestOut != "" && cfg.Action != ActionNone {
+		fmt.Fprintln(os.Stderr, "❌ -write-manifest только записывает манифест, действия с ним не применяются")
+		os.Exit(exitFatal)
+	}
+	if err := checkActionMode(cfg.Mode); err != nil && cfg.Action != ActionNone {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+	if cfg.ReportUnique && cfg.Action != ActionNone {
+		fmt.Fprintln(os.Stderr, "❌ -unique показывает файлы без копий, действия с ним не применяются")
+		os.Exit(exitFatal)
+	}
+	if (cfg.TUI || cfg.Interactive) && (cfg.FilesFrom == os.Stdin || slices.Contains(cfg.RootList(), scan.FilesFromStdin)) {
+		fmt.Fprintln(os.Stderr, "❌ Список файлов уже читается из stdin, -interactive и -tui не смогут спросить выбор")
+		os.Exit(exitFatal)
+	}
+	if cfg.TUI {
+		// Проверяем заранее, чтобы не сканировать впустую
+		if cfg.Action == ActionNone || cfg.Interactive {
+			fmt.Fprintln(os.Stderr, "❌ Для -tui выберите действие через -action (и не используйте -interactive)")
+			os.Exit(exitFatal)
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", ErrNoTerminal)
+			os.Exit(exitFatal)
+		}
+	}
+	if cfg.Interactive {
+		// Проверяем заранее, чтобы не сканировать впустую
+		if cfg.Action == ActionNone {
+			fmt.Fprintln(os.Stderr, "❌ Для интерактивного режима выберите действие через -action")
+			os.Exit(exitFatal)
+		}
+		if err := checkInteractiveInput(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	// 2. Запуск
+	roots := scanner.Roots()
+
+	sources := append(append([]string{}, roots...), manifests...)
+	fmt.Fprintf(out, "🚀 Запуск DupliFinder\n📂 Папка: %s\n⚙ Режим: %s\n👷‍♂️👷‍♀️ Воркеров: %d\n\n", strings.Join(sources, ", "), cfg.Mode, scanner.Workers())
+	if len(roots) < len(cfg.RootList()) {
+		fmt.Fprintln(out, "ℹ Повторяющиеся и вложенные друг в друга пути просканированы один раз")
+	}
 	startTime := time.Now() // Засекаем время старта
 
-	// 2. Инициализация сканера
-	scanner := NewScanner(cfg)
+	// Первый Ctrl-C (или SIGTERM) останавливает сканирование, сохраняя уже проверенные группы,
+	// второй завершает программу сразу
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Fprintln(out, "\n⏹  Остановка: дочитываем начатые файлы (повторный Ctrl-C - выход сразу)")
+		scanner.Interrupt()
+		<-signals
+		os.Exit(exitInterrupted)
+	}()
+
+	// Состояние по запросу: kill -USR1 <pid> или Enter в терминале
+	stopStatus := startStatusDump(scanner, !cfg.Interactive && !cfg.TUI)
+
+	// 3. Основная работа (Блокирующая операция)
+	var duplicates [][]scan.FileInfo
+	var dirDuplicates []scan.DirDuplicate
+	var treeDuplicates []scan.TreeDuplicate
+	var prefixMatches []scan.PrefixMatch
+	var chunkReport *scan.ChunkReport
+	var manifestFiles []scan.FileInfo
+	var snapshot *scan.Snapshot
+	if cfg.ManifestOut != "" {
+		manifestFiles, err = scanner.ManifestFiles(context.Background())
+	} else if cfg.ResultsIn != "" {
+		var mode scan.Mode
+		if duplicates, mode, err = ReadSQLite(cfg.ResultsIn); err == nil && cfg.Action != ActionNone && len(duplicates) > 0 {
+			if modeErr := checkActionMode(mode); modeErr != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s: %v\n", cfg.ResultsIn, modeErr)
+				os.Exit(exitFatal)
+			}
+		}
+	} else {
+		var result *scan.Result
+		if cfg.Snapshot != "" {
+			result, snapshot, err = deltaRun(scanner, cfg.Snapshot)
+		} else {
+			result, err = scanner.Run(context.Background())
+		}
+		if result != nil {
+			duplicates, dirDuplicates, treeDuplicates = result.Groups, result.DirDuplicates, result.TreeDuplicates
+			prefixMatches, chunkReport = result.PrefixMatches, result.ChunkReport
+		}
+	}
+
+	stopStatus()
+	fmt.Fprintln(out) // Перенос строки после прогресс-бара
+
+	interrupted := errors.Is(err, scan.ErrInterrupted)
+	if err != nil && !interrupted {
+		fmt.Fprintf(os.Stderr, "❌ Критическая ошибка: %v,\n", err)
+		os.Exit(exitFatal)
+	}
+	if interrupted {
+		fmt.Fprintln(out, "⚠ Сканирование прервано: результат неполный, показаны только полностью проверенные группы")
+	}
+	// Группы внутри одинаковых деревьев не показываются, но остаются дубликатами: для кода выхода и действий
+	allGroups := duplicates
+	for _, tree := range treeDuplicates {
+		allGroups = append(allGroups, tree.Groups...)
+	}
+	code := resultCode(scanner.GetStats(), len(allGroups), interrupted)
+	if snapshot != nil {
+		if err := scan.SaveSnapshot(cfg.Snapshot, snapshot); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Не удалось сохранить снимок %s: %v\n", cfg.Snapshot, err)
+		} else {
+			fmt.Fprintf(out, "🧊 Снимок сохранен в %s, файлов: %d\n", cfg.Snapshot, len(snapshot.Entries))
+		}
+	}
+
+	if cfg.MetricsOut != "" {
+		if err := writeMetricsFile(cfg.MetricsOut, scanner); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Не удалось записать метрики %s: %v\n", cfg.MetricsOut, err)
+		}
+	}
+
+	// 4. Вывод пезультатов
+	if cfg.ManifestOut != "" {
+		written, err := scan.WriteManifest(cfg.ManifestOut, roots, manifestFiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось записать манифест: %v\n", err)
+			os.Exit(exitFatal)
+		}
+		fmt.Fprintf(out, "🧾 Манифест сохранен в %s, записей: %d (не прочитано: %d)\n",
+			cfg.ManifestOut, written, len(manifestFiles)-written)
+		finish(out, startTime, code)
+		return
+	}
+	if cfg.ReportUnique {
+		unique := scanner.Unique()
+		fmt.Fprintf(out, "\n🔹 Файлы без копий: %d\n", len(unique))
+		for _, file := range unique {
+			fmt.Fprintf(out, "  📄 %s (%d bytes)\n", file.Path, file.Size)
+		}
+		finish(out, startTime, code)
+		return
+	}
+
+	fmt.Fprintln(out, "\n📊 Результаты поиска:")
+	if len(allGroups) == 0 {
+		fmt.Fprintln(out, "Дубликаты не найдены")
+	} else if cfg.Top > 0 {
+		printTop(out, duplicates, cfg.Top)
+	} else {
+		printGroups(out, duplicates, len(sources) > 1, cfg.FuzzyNames && (cfg.Mode.ComparesNames() || cfg.RequireSameName))
+	}
+	if cfg.ByExt && len(allGroups) > 0 {
+		printByExtension(out, allGroups)
+	}
+	if cfg.DirDuplicates {
+		fmt.Fprintf(out, "📁 Папки-копии: %d\n", len(dirDuplicates))
+		for _, d := range dirDuplicates {
+			if d.Mutual {
+				fmt.Fprintf(out, "  %s = %s (файлов %d)\n", d.A, d.B, d.Files)
+			} else {
+				fmt.Fprintf(out, "  %s → все файлы есть в %s (файлов %d)\n", d.A, d.B, d.Files)
+			}
+		}
+	}
+	if cfg.DirTrees {
+		fmt.Fprintf(out, "🌳 Одинаковые деревья папок: %d\n", len(treeDuplicates))
+		for _, tree := range treeDuplicates {
+			fmt.Fprintf(out, "  %s (файлов %d, %d bytes в каждой, групп файлов внутри: %d)\n",
+				strings.Join(tree.Dirs, " = "), tree.Files, tree.Size, len(tree.Groups))
+		}
+	}
+	if chunkReport != nil {
+		printChunkReport(out, chunkReport)
+	}
+	if cfg.FindPrefixes {
+		fmt.Fprintf(out, "✂ Недокачанные копии: %d\n", len(prefixMatches))
+		for _, m := range prefixMatches {
+			fmt.Fprintf(out, "  %s (%d bytes) - начало %s (%d bytes)\n", m.Partial.Path, m.Partial.Size, m.Complete.Path, m.Complete.Size)
+		}
+	}
+	if len(roots) > 1 {
+		perRoot := scanner.GetStats().FilesPerRoot
+		fmt.Fprintln(out, "📂 Файлов по корням:")
+		for _, root := range roots {
+			fmt.Fprintf(out, "  %s: %d\n", root, perRoot[root])
+		}
+	}
+
+	if hardlinks := scanner.Hardlinks(); *showHardlinksPtr {
+		fmt.Fprintf(out, "🔗 Жесткие ссылки (несколько путей к одному файлу): %d\n", len(hardlinks))
+		for _, cluster := range hardlinks {
+			fmt.Fprintf(out, "  %d bytes:\n", cluster[0].Size)
+			for _, file := range cluster {
+				fmt.Fprintf(out, "    📄 %s\n", file.Path)
+			}
+		}
+	} else if len(hardlinks) > 0 && cfg.IgnoreHardlinks {
+		skipped := 0
+		for _, cluster := range hardlinks {
+			skipped += len(cluster) - 1
+		}
+		fmt.Fprintf(out, "🔗 Жесткие ссылки не считаются дубликатами, пропущено путей: %d (список - -show-hardlinks)\n", skipped)
+	}
+	t := scanner.Timings()
+	fmt.Fprintf(out, "⏱  Этапы: обход %s, группировка %s, хэширование %s\n",
+		t.WalkDuration.Round(time.Microsecond), t.GroupDuration.Round(time.Microsecond), t.HashDuration.Round(time.Microsecond))
+	if n := scanner.GetStats().ChangedFiles; n > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ Изменились во время сканирования и пропущены: %d (запустите поиск еще раз)\n", n)
+	}
+	if n := scanner.GetStats().OtherOwnerFiles; n > 0 {
+		fmt.Fprintf(out, "👤 Пропущено чужих файлов: %d\n", n)
+	}
+	if stats := scanner.GetStats(); stats.ReadRetries > 0 {
+		fmt.Fprintf(os.Stderr, "🔁 Повторов чтения после временных ошибок: %d; не прочитаны и после повторов: %d\n", stats.ReadRetries, stats.RetryFailures)
+	}
+	if n := scanner.GetStats().TimedOutFiles; n > 0 {
+		fmt.Fprintf(os.Stderr, "⏳ Не прочитаны за -file-timeout %s и пропущены: %d (диск не отвечает?)\n", cfg.FileTimeout, n)
+	}
+	printScanErrors(os.Stderr, scanner.Errors(), scanner.GetStats().Errors)
+
+	if cfg.ChecksumsIn != "" {
+		var files []scan.FileInfo
+		for _, group := range duplicates {
+			files = append(files, group...)
+		}
+		mismatches, err := scanner.VerifyManifest(cfg.ChecksumsIn, files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось прочитать контрольные суммы: %v\n", err)
+			os.Exit(exitFatal)
+		}
+		fmt.Fprintf(out, "🔐 Несовпадений с %s: %d\n", cfg.ChecksumsIn, len(mismatches))
+		for _, m := range mismatches {
+			if m.Err != nil {
+				fmt.Fprintf(os.Stderr, "  ❗ %s: %v\n", m.Path, m.Err)
+			} else {
+				fmt.Fprintf(os.Stderr, "  ❗ %s: ожидался %s, сейчас %s\n", m.Path, m.Expected, m.Actual)
+			}
+		}
+	}
+
+	if cfg.JSONLOut != "" {
+		if err := writeJSONL(cfg.JSONLOut, cfg.Mode, duplicates); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось сохранить JSON Lines: %v\n", err)
+			os.Exit(exitFatal)
+		}
+	}
 
-	// Запускаем тикер для отображения прогресса в отдельной горутине
-	// time.NewTicker создает канал, в который приходят сообщения каждые Х времени
-	done := make(chan bool)
-	ticker := time.NewTicker(cfg.Tick)
+	if cfg.SQLiteOut != "" {
+		if err := WriteSQLite(cfg.SQLiteOut, cfg.Mode, duplicates); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось сохранить базу SQLite: %v\n", err)
+			os.Exit(exitFatal)
+		}
+		fmt.Fprintf(out, "🗄  Результаты сохранены в %s\n", cfg.SQLiteOut)
+	}
+
+	// 5. Действие над дубликатами (если выбрано)
+	if cfg.Action != ActionNone && interrupted {
+		fmt.Fprintln(out, "⚠ Действия не выполнялись: сканирование прервано")
+	} else if cfg.Action != ActionNone {
+		var plan []PlannedOp
+		switch {
+		case cfg.TUI:
+			plan, err = RunTUI(cfg, allGroups, os.Stdin, os.Stdout)
+		case cfg.Interactive:
+			plan, err = ResolveInteractive(cfg, allGroups, os.Stdin, os.Stdout)
+		default:
+			plan, err = PlanActions(cfg, allGroups)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Критическая ошибка: %v\n", err)
+			os.Exit(exitFatal)
+		}
+		applyPlan(out, cfg, plan)
+		code = actionCode(code, cfg, len(plan))
+	}
+
+	finish(out, startTime, code)
+}
+
+// finish печатает время выполнения и завершает программу с кодом code
+func finish(out io.Writer, startTime time.Time, code int) {
+	fmt.Fprintf(out, "\n⏱  Время выполнения: %s\n", time.Since(startTime))
+	if code != exitNoDuplicates {
+		os.Exit(code)
+	}
+}
+
+// applyPlan сохраняет план (-plan-json) и выполняет его: записывает в скрипты, печатает
+// при -dry-run или выполняет с журналом. При критической ошибке завершает программу.
+func applyPlan(out io.Writer, cfg Config, plan []PlannedOp) {
+	if cfg.PlanOut != "" {
+		if err := WritePlanJSON(cfg.PlanOut, cfg, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось сохранить план: %v\n", err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if cfg.ScriptOut != "" || cfg.ScriptPSOut != "" {
+		if err := writeScripts(cfg, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось записать скрипт: %v\n", err)
+			os.Exit(exitFatal)
+		}
+		fmt.Fprintf(out, "📜 Операций записано в скрипт: %d (файлы не изменены)\n", len(plan))
+		return
+	}
+
+	if cfg.DryRun {
+		// Одна строка на операцию, без украшений - вывод удобно разбирать скриптами
+		fmt.Fprintf(out, "📝 План действий (dry-run), операций: %d\n", len(plan))
+		for _, op := range plan {
+			fmt.Fprintln(out, op)
+		}
+		return
+	}
+
+	// Журнал заводим, только если есть что выполнять
+	var journal *Journal
+	var journalPath string
+	if len(plan) > 0 {
+		var err error
+		journal, journalPath, err = openRunJournal(cfg.JournalPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Не удалось открыть журнал (без него действия не выполняются): %v\n", err)
+			os.Exit(exitFatal)
+		}
+	}
+	report, err := ExecutePlan(plan, journal)
+	printActionReport(out, cfg.Action, report)
+	if journal != nil {
+		fmt.Fprintf(out, "📒 Журнал: %s (отмена: -undo %s)\n", journalPath, journalPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Критическая ошибка: %v\n", err)
+		os.Exit(exitFatal)
+	}
+}
+
+// planOnly сообщает, что план только показывается или записывается, а не выполняется
+func (cfg Config) planOnly() bool {
+	return cfg.DryRun || cfg.ScriptOut != "" || cfg.ScriptPSOut != ""
+}
+
+// writeScripts сохраняет план в запрошенные скрипты
+func writeScripts(cfg Config, plan []PlannedOp) error {
+	if cfg.ScriptOut != "" {
+		if err := writeScriptFile(cfg.ScriptOut, WriteShellScript, cfg, plan, 0o755); err != nil {
+			return err
+		}
+	}
+	if cfg.ScriptPSOut != "" {
+		if err := writeScriptFile(cfg.ScriptPSOut, WritePowerShellScript, cfg, plan, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSONL сохраняет группы, найденные в режиме mode, в файл JSON Lines ("-" - в stdout)
+func writeJSONL(path string, mode scan.Mode, groups [][]scan.FileInfo) error {
+	ch := make(chan []scan.FileInfo)
+	done := make(chan struct{}) // При ошибке записи отправитель не должен остаться висеть
+	defer close(done)
 	go func() {
-		for {
+		defer close(ch)
+		for _, group := range groups {
 			select {
-			case <-done: // Если получили сигнал завершения - выходим
+			case ch <- group:
+			case <-done:
 				return
-			case <-ticker.C: // Каждое срабатывание тикера
-				stats := scanner.GetStats()
-				// \r возвращает каретку в начало строки, позволяя перезаписывать текст (эффект анимации)
-				fmt.Printf("\r🔎 Просмотрено файлов: %d| Найдено групп дупликатов: %d | Ошибок: %d",
-					stats.TotalFiles, stats.DuplicateGroups, stats.Errors)
 			}
 		}
 	}()
+	if path == "-" {
+		return scan.WriteJSONL(os.Stdout, mode, ch)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := scan.WriteJSONL(f, mode, ch); err != nil {
+		return err
+	}
+	return f.Close()
+}
 
-	// 3. Основная работа (Блокирующая операция)
-	duplicates, err := scanner.Run()
+// writeMetricsFile записывает метрики во временный файл рядом и переименовывает его:
+// textfile collector не должен прочитать файл наполовину записанным
+func writeMetricsFile(path string, scanner *scan.Scanner) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".duplifinder-metrics-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // После переименования уже ничего не удаляет
+	if err := scan.WriteMetrics(tmp, scanner); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	// Останавливаем тикер и прогресс
-	ticker.Stop()
-	done <- true
-	fmt.Println() // Перенос строки после прогресс-бара
+// openRunJournal открывает журнал по заданному пути или по пути по умолчанию
+func openRunJournal(path string) (*Journal, string, error) {
+	if path == "" {
+		var err error
+		if path, err = defaultJournalPath(); err != nil {
+			return nil, "", err
+		}
+	}
+	journal, err := OpenJournal(path)
+	return journal, path, err
+}
 
+// runUndo отменяет действия по журналу и печатает итог
+func runUndo(path string) {
+	report, err := Undo(path)
 	if err != nil {
-		fmt.Printf("❌ Критическая ошибка: %v,\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "❌ Не удалось прочитать журнал: %v\n", err)
+		os.Exit(exitFatal)
+	}
+	if report.Uncommitted {
+		fmt.Println("⚠ Запуск, записанный в журнал, был прерван: отменяется то, что успело выполниться")
+	}
+	fmt.Printf("↩ Восстановлено файлов: %d из %d\n", len(report.Restored), report.EntriesParsed)
+	for _, fail := range report.Failed {
+		fmt.Printf("  ❗ %s: %v\n", fail.Path, fail.Err)
+	}
+	if len(report.Irreversible) > 0 {
+		fmt.Printf("⚠ Удалены безвозвратно, восстановить нельзя: %d\n", len(report.Irreversible))
+		for _, path := range report.Irreversible {
+			fmt.Printf("  🗑  %s\n", path)
+		}
+	}
+	if len(report.Unfinished) > 0 {
+		fmt.Printf("⚠ Прерваны посреди операции, проверьте вручную: %d\n", len(report.Unfinished))
+		for _, path := range report.Unfinished {
+			fmt.Printf("  ❓ %s\n", path)
+		}
+	}
+	if len(report.Failed) > 0 {
+		os.Exit(exitReadErrors)
 	}
+}
 
-	// 4. Вывод пезультатов
-	fmt.Println("\n📊 Результаты поиска:")
-	if len(duplicates) == 0 {
-		fmt.Println("Дубликаты не найдены")
-	} else {
-		for i, group := range duplicates {
-			fmt.Printf("Группа #%d (Файлов %d)\n", i+1, len(group))
-			for _, file := range group {
-				fmt.Printf("  📄 %s (%d bytes)\n", file.Path, file.Size)
+// errorSamples - сколько путей показывать для каждого вида ошибок
+const errorSamples = 5
+
+// printScanErrors выводит ошибки сканирования, сгруппированные по операции и причине.
+// total - число всех ошибок (scan.Stats.Errors), записей в errs может быть меньше.
+func printScanErrors(w io.Writer, errs []scan.ScanError, total int64) {
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "⚠ Ошибок при сканировании: %d (эти файлы не проверены, результат может быть неполным)\n", total)
+	for _, group := range scan.GroupErrors(errs) {
+		fmt.Fprintf(w, "  %s (%s): %d\n", group.Reason, group.Op, len(group.Paths))
+		for _, path := range group.Paths[:min(len(group.Paths), errorSamples)] {
+			fmt.Fprintf(w, "    ❗ %s\n", path)
+		}
+		if more := len(group.Paths) - errorSamples; more > 0 {
+			fmt.Fprintf(w, "    … и еще %d\n", more)
+		}
+	}
+	if dropped := total - int64(len(errs)); dropped > 0 {
+		fmt.Fprintf(w, "  … и еще %d без подробностей\n", dropped)
+	}
+}
+
+// printGroups выводит группы дубликатов: showRoots - с корнем каждого файла (при нескольких
+// корнях сразу видно, откуда каждая копия), fuzzy - с именем без примет копии, по которому
+// совпали имена (-fuzzy-names)
+func printGroups(out io.Writer, groups [][]scan.FileInfo, showRoots, fuzzy bool) {
+	for i, group := range groups {
+		if scan.IsEmptyGroup(group) {
+			fmt.Fprintf(out, "Группа #%d (Файлов %d) — пустые файлы\n", i+1, len(group))
+		} else {
+			fmt.Fprintf(out, "Группа #%d (Файлов %d)\n", i+1, len(group))
+		}
+		if format := scan.ContentOnlyFormat(group[0].Hash); format != "" {
+			fmt.Fprintf(out, "  🎞 Совпадает содержимое %s без метаданных (теги, EXIF могут различаться)\n", format)
+		}
+		if fuzzy {
+			fmt.Fprintf(out, "  🔤 Имя без примет копии: %s\n", scan.CanonicalName(group[0].Name))
+		}
+		for _, file := range group {
+			link := ""
+			if file.HardlinkOf != "" {
+				link = " 🔗 = " + file.HardlinkOf // Жесткая ссылка: места не освободит
 			}
-			fmt.Println()
+			if showRoots {
+				fmt.Fprintf(out, "  📄 %s (%d bytes)%s [📂 %s]\n", file.Path, file.Size, link, file.Root)
+			} else {
+				fmt.Fprintf(out, "  📄 %s (%d bytes)%s\n", file.Path, file.Size, link)
+			}
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// printTop выводит n групп, занимающих больше всего лишнего места (-top)
+func printTop(out io.Writer, groups [][]scan.FileInfo, n int) {
+	fmt.Fprintf(out, "Групп всего: %d, самые крупные по лишнему месту:\n", len(groups))
+	for i, summary := range scan.TopWasters(groups, n) {
+		fmt.Fprintf(out, "#%d: %d bytes лишних (файлов %d по %d bytes)\n", i+1, summary.Wasted, summary.Count, summary.Size)
+		for _, path := range summary.Samples {
+			fmt.Fprintf(out, "  📄 %s\n", path)
+		}
+		if more := summary.Count - len(summary.Samples); more > 0 {
+			fmt.Fprintf(out, "  … и еще %d\n", more)
+		}
+	}
+}
+
+// chunkDirSamples - сколько папок с наибольшей экономией показывает printChunkReport
+const chunkDirSamples = 20
+
+// printChunkReport выводит оценку режима chunks: всего и по папкам
+func printChunkReport(out io.Writer, report *scan.ChunkReport) {
+	fmt.Fprintf(out, "🧩 Оценка дедупликации блоками (~1 МБ): файлов %d, %d bytes, блоков %d\n", report.Files, report.Bytes, report.Chunks)
+	percent := 0.0
+	if report.Bytes > 0 {
+		percent = float64(report.EstimatedSavings) / float64(report.Bytes) * 100
+	}
+	fmt.Fprintf(out, "  Освободилось бы примерно %d bytes (%.1f%%) - это оценка, файлы не изменены\n", report.EstimatedSavings, percent)
+	if report.SampleRate > 1 {
+		fmt.Fprintf(out, "  Индекс не вместил все блоки: оценка по выборке 1 из %d блоков\n", report.SampleRate)
+	}
+	for _, dir := range report.Dirs[:min(len(report.Dirs), chunkDirSamples)] {
+		fmt.Fprintf(out, "  📁 %s: ~%d из %d bytes\n", dir.Dir, dir.EstimatedSavings, dir.Bytes)
+	}
+	if more := len(report.Dirs) - chunkDirSamples; more > 0 {
+		fmt.Fprintf(out, "  … и еще папок: %d\n", more)
+	}
+}
+
+// printByExtension выводит лишнее место по расширениям, от большего к меньшему, с долей от всего лишнего
+func printByExtension(w io.Writer, groups [][]scan.FileInfo) {
+	byExt := scan.SummaryByExtension(groups)
+	exts := make([]string, 0, len(byExt))
+	var total int64
+	for ext, stats := range byExt {
+		exts = append(exts, ext)
+		total += stats.Wasted
+	}
+	slices.SortFunc(exts, func(a, b string) int {
+		if d := cmp.Compare(byExt[b].Wasted, byExt[a].Wasted); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+	fmt.Fprintln(w, "🧮 Лишнее место по расширениям:")
+	for _, ext := range exts {
+		stats := byExt[ext]
+		share := 0.0
+		if total > 0 {
+			share = float64(stats.Wasted) * 100 / float64(total)
+		}
+		name := ext
+		if name == "" {
+			name = "(без расширения)"
 		}
+		fmt.Fprintf(w, "  %s: %d bytes (%.1f%%), групп %d, файлов %d\n", name, stats.Wasted, share, stats.Groups, stats.Files)
 	}
+}
 
-	fmt.Printf("\n⏱  Время выполнения: %s\n", time.Since(startTime))
+// printActionReport выводит итог действия и список файлов, которые обработать не удалось
+func printActionReport(out io.Writer, action string, report ActionReport) {
+	verb := "Удалено"
+	switch action {
+	case ActionTrash:
+		verb = "Перемещено в корзину"
+	case ActionHardlink:
+		verb = "Заменено жесткими ссылками"
+	}
+	fmt.Fprintf(out, "🗑  %s файлов: %d\n", verb, len(report.Done))
+	if len(report.Failed) > 0 {
+		fmt.Fprintf(os.Stderr, "⚠ Не удалось обработать файлов: %d (они остались на месте)\n", len(report.Failed))
+		for _, fail := range report.Failed {
+			fmt.Fprintf(os.Stderr, "  ❗ %s: %v\n", fail.Path, fail.Err)
+		}
+	}
+}
+
+// pathList - флаг, который можно указать несколько раз: -path /mnt/a -path /mnt/b
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *pathList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
 
+// Get возвращает пути срезом (для -print-config)
+func (p *pathList) Get() any {
+	return []string(*p)
+}
+
+// openFilesFrom открывает список файлов для -files-from ("-" - stdin, "" - списка нет).
+// Файл остается открытым до конца работы программы.
+func openFilesFrom(path string) (io.Reader, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case scan.FilesFromStdin:
+		return os.Stdin, nil
+	}
+	return os.Open(path)
+}
+
+// deltaRun ищет дубликаты, беря хэши неизменившихся файлов из снимка path, и возвращает новый снимок.
+// Если снимка еще нет, это обычный полный поиск.
+func deltaRun(scanner *scan.Scanner, path string) (*scan.Result, *scan.Snapshot, error) {
+	prev, err := scan.LoadSnapshot(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		prev = nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("снимок %s: %w", path, err)
+	}
+	return scanner.DeltaRun(context.Background(), prev)
 }
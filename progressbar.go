@@ -0,0 +1,87 @@
+// Вывод прогресса в терминал: строка с полосой, скоростью и ETA поверх Config.OnProgress
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BatrazG/duplifinder/scan"
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth = 24
+	// plainProgressInterval - как часто печатается строка прогресса, если вывод не терминал
+	// (лог или пайп): там \r не перерисовывает строку, а копит мусор
+	plainProgressInterval = 5 * time.Second
+)
+
+// progressPrinter рисует прогресс из колбэка Config.OnProgress.
+// Колбэк вызывается из одной горутины, поэтому состояние не требует блокировок.
+type progressPrinter struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration // Минимальный интервал перерисовки
+
+	phase      scan.Phase
+	phaseStart time.Time
+	lastDraw   time.Time
+	lastWidth  int // Длина прошлой строки: короткую строку добиваем пробелами
+}
+
+// newProgressPrinter выводит прогресс в stdout; для не-терминала - редкими отдельными строками
+func newProgressPrinter(tick time.Duration) *progressPrinter {
+	p := &progressPrinter{out: os.Stdout, tty: term.IsTerminal(int(os.Stdout.Fd())), interval: tick}
+	if !p.tty {
+		p.interval = max(tick, plainProgressInterval)
+	}
+	return p
+}
+
+// update - обработчик для Config.OnProgress
+func (p *progressPrinter) update(pr scan.Progress) {
+	now := time.Now()
+	phaseChanged := pr.Phase != p.phase
+	if phaseChanged {
+		p.phase = pr.Phase
+		p.phaseStart = now
+	}
+	if pr.Phase == scan.PhaseDone || (!phaseChanged && now.Sub(p.lastDraw) < p.interval) {
+		return
+	}
+	p.lastDraw = now
+
+	line := p.render(pr, now)
+	if !p.tty {
+		fmt.Fprintln(p.out, line)
+		return
+	}
+	width := len([]rune(line))
+	fmt.Fprintf(p.out, "\r%s%s", line, strings.Repeat(" ", max(p.lastWidth-width, 0)))
+	p.lastWidth = width
+}
+
+// render собирает строку прогресса
+func (p *progressPrinter) render(pr scan.Progress, now time.Time) string {
+	if pr.Phase != scan.PhaseHashing && pr.Phase != scan.PhaseVerifying {
+		return fmt.Sprintf("🔎 %s | Просмотрено файлов: %d | Ошибок: %d", pr.Phase, pr.FilesSeen, pr.Errors)
+	}
+
+	fraction := pr.Percent / 100
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+
+	elapsed := now.Sub(p.phaseStart).Seconds()
+	speed, eta := "—", "—"
+	if elapsed > 0 && pr.BytesHashed > 0 {
+		rate := float64(pr.BytesHashed) / elapsed
+		speed = fmt.Sprintf("%.1f МБ/с", rate/(1<<20))
+		remaining := time.Duration(float64(pr.BytesTotal-pr.BytesHashed) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+	return fmt.Sprintf("🔎 %s [%s] %3.0f%% | %s | ETA %s | Файлов: %d | Ошибок: %d",
+		pr.Phase, bar, pr.Percent, speed, eta, pr.FilesHashed, pr.Errors)
+}
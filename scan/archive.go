@@ -0,0 +1,77 @@
+// Поиск дубликатов среди записей одного ZIP-архива без распаковки
+package scan
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// ScanArchive ищет одинаковые записи внутри ZIP-архива zipPath с настройками cfg.
+// FileInfo.Path в результате - путь записи внутри архива, FileInfo.Root - zipPath.
+//
+// В режимах с хэшем первый проход бесплатный: размер и CRC-32 каждой записи уже есть
+// в центральном каталоге архива. Распаковываются и хэшируются только записи, у которых
+// совпали размер и CRC-32 с другой записью. При Config.ReportUnique отсев по CRC
+// не делается: уникальность проверяется по хэшу, как обычно.
+//
+// Корни и Config.FS из cfg не используются (читается сам архив), Config.Manifests не поддерживаются.
+// Записи с путями, недопустимыми для fs.FS (абсолютные, с ".."), пропускаются.
+func ScanArchive(zipPath string, cfg Config) ([][]FileInfo, error) {
+	if len(cfg.Manifests) > 0 {
+		return nil, errors.New("ScanArchive: сравнение с манифестами не поддерживается")
+	}
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cfg.FS = &r.Reader
+	cfg.DirPath, cfg.Roots = ".", nil
+	s, err := NewScanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	type crcKey struct {
+		size uint64
+		crc  uint32
+	}
+	var files []FileInfo
+	var keys []crcKey
+	seen := make(map[crcKey]int)
+	for _, zf := range r.File {
+		info := zf.FileInfo()
+		if info.IsDir() || !fs.ValidPath(zf.Name) {
+			continue
+		}
+		if cfg.FileFilter != nil && !cfg.FileFilter(zf.Name, info) {
+			continue
+		}
+		files = append(files, FileInfo{
+			Path:    zf.Name,
+			Name:    path.Base(zf.Name),
+			Size:    int64(zf.UncompressedSize64),
+			ModTime: zf.Modified,
+			Mode:    zf.Mode(),
+			Root:    zipPath,
+		})
+		key := crcKey{size: zf.UncompressedSize64, crc: zf.CRC32}
+		keys = append(keys, key)
+		seen[key]++
+	}
+
+	// Записи с уникальной парой размер+CRC-32 не могут совпасть ни с одной другой
+	if cfg.Mode.hashesContent() && !cfg.ReportUnique {
+		candidates := files[:0]
+		for i, f := range files {
+			if seen[keys[i]] > 1 {
+				candidates = append(candidates, f)
+			}
+		}
+		files = candidates
+	}
+	return s.RunFiles(files)
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScanArchive(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "photos.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, entry := range []struct{ name, content string }{
+		{"a.txt", "одинаковые записи"},
+		{"dir/b.txt", "одинаковые записи"},
+		{"c.txt", "Одинаковые записи"}, // Тот же размер, другое содержимое
+		{"d.txt", "единственная"},
+	} {
+		ew, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ew.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := ScanArchive(zipPath, DefaultConfig(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("групп %d, want 1: %v", len(groups), groups)
+	}
+	var paths []string
+	for _, f := range groups[0] {
+		paths = append(paths, f.Path)
+		if f.Root != zipPath || f.Hash == "" {
+			t.Errorf("%s: корень %q, хэш %q", f.Path, f.Root, f.Hash)
+		}
+	}
+	slices.Sort(paths)
+	if want := []string{"a.txt", "dir/b.txt"}; !slices.Equal(paths, want) {
+		t.Errorf("группа %q, want %q", paths, want)
+	}
+}
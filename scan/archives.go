@@ -0,0 +1,270 @@
+// Записи архивов (.zip, .tar, .tar.gz) как виртуальные файлы при обходе (Config.ScanArchives)
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// archiveSep отделяет путь архива от пути записи в нем: /backups/old.zip!/docs/report.pdf.
+// Во вложенном архиве разделителей несколько: old.zip!/inner.tar!/file.
+const archiveSep = "!/"
+
+// Ограничения по умолчанию (Config.ArchiveMaxEntrySize, Config.ArchiveMaxDepth)
+const (
+	DefaultArchiveMaxEntrySize = 1 << 30 // 1 ГБ
+	DefaultArchiveMaxDepth     = 2       // Архив на диске и архивы внутри него
+)
+
+// maxNestedArchiveSize - вложенный архив читается в память целиком (zip нужен произвольный доступ),
+// поэтому для него предел меньше, чем для обычной записи
+const maxNestedArchiveSize = 64 << 20
+
+// archiveKind - формат архива по расширению имени; "" - не архив
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tgz"
+	}
+	return ""
+}
+
+// archiveEntryFunc получает запись архива. open действителен только во время вызова
+// (у tar записи читаются по порядку); errStopEntries из fn прекращает перебор без ошибки.
+type archiveEntryFunc func(name string, info fs.FileInfo, open func() (io.Reader, error)) error
+
+// errStopEntries - нужная запись найдена, перебор можно остановить
+var errStopEntries = errors.New("перебор записей остановлен")
+
+// forEachEntry перебирает обычные файлы архива kind, лежащего в r (size байт).
+// Поток записи отдельно не закрывается: он читает из r и живет, пока открыт сам r.
+func forEachEntry(kind string, r io.ReaderAt, size int64, fn archiveEntryFunc) error {
+	if kind == "zip" {
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() || !zf.Mode().IsRegular() {
+				continue
+			}
+			open := func() (io.Reader, error) { return zf.Open() }
+			if err := fn(zf.Name, zf.FileInfo(), open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var stream io.Reader = io.NewSectionReader(r, 0, size)
+	if kind == "tgz" {
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			return err
+		}
+		stream = gz
+	}
+	tr := tar.NewReader(stream)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, header.FileInfo(), func() (io.Reader, error) { return tr, nil }); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveLimits возвращает предел размера записи и глубину вложенности из настроек
+func (s *Scanner) archiveLimits() (maxSize int64, maxDepth int) {
+	maxSize, maxDepth = s.config.ArchiveMaxEntrySize, s.config.ArchiveMaxDepth
+	if maxSize == 0 {
+		maxSize = DefaultArchiveMaxEntrySize
+	}
+	if maxDepth == 0 {
+		maxDepth = DefaultArchiveMaxDepth
+	}
+	return maxSize, maxDepth
+}
+
+// walkArchive дописывает в files записи архива archivePath (на диске) как виртуальные файлы
+func (s *Scanner) walkArchive(files []FileInfo, rootIndex int, root, archivePath string) []FileInfo {
+	f, err := os.Open(LongPath(archivePath))
+	if err != nil {
+		s.recordError(archivePath, OpOpen, err)
+		return files
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		s.recordError(archivePath, OpStat, err)
+		return files
+	}
+	return s.walkArchiveEntries(files, rootIndex, root, archivePath, f, info.Size(), 1)
+}
+
+// walkArchiveEntries перебирает записи архива (depth - его глубина вложенности, с 1)
+func (s *Scanner) walkArchiveEntries(files []FileInfo, rootIndex int, root, archivePath string, r io.ReaderAt, size int64, depth int) []FileInfo {
+	maxSize, maxDepth := s.archiveLimits()
+	err := forEachEntry(archiveKind(archivePath), r, size, func(name string, info fs.FileInfo, open func() (io.Reader, error)) error {
+		if s.Interrupted() {
+			return errStopEntries
+		}
+		entryPath := archivePath + archiveSep + name
+		if !fs.ValidPath(name) {
+			s.logger.Debug("путь пропущен", "path", entryPath, "reason", "недопустимый путь записи архива")
+			return nil
+		}
+		if info.Size() > maxSize {
+			s.logger.Debug("путь пропущен", "path", entryPath, "reason", "запись архива больше ArchiveMaxEntrySize")
+			return nil
+		}
+		if s.config.FileFilter != nil && !s.config.FileFilter(entryPath, info) {
+			s.logger.Debug("путь пропущен", "path", entryPath, "reason", "FileFilter")
+			return nil
+		}
+		files = append(files, FileInfo{
+			Path:     entryPath,
+			Name:     path.Base(name),
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Mode:     info.Mode(),
+			Root:     root,
+			ReadOnly: true,
+		})
+		atomic.AddInt64(&s.stats.TotalFiles, 1)
+		atomic.AddInt64(&s.rootFiles[rootIndex], 1)
+
+		if archiveKind(name) == "" || depth >= maxDepth {
+			return nil
+		}
+		if info.Size() > maxNestedArchiveSize {
+			s.logger.Debug("вложенный архив не раскрыт", "path", entryPath, "reason", "слишком большой для чтения в память")
+			return nil
+		}
+		data, err := readEntry(open, info.Size())
+		if err != nil {
+			s.recordError(entryPath, OpRead, err)
+			return nil
+		}
+		files = s.walkArchiveEntries(files, rootIndex, root, entryPath, bytes.NewReader(data), int64(len(data)), depth+1)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopEntries) {
+		s.recordError(archivePath, OpRead, err)
+		s.logger.Warn("не удалось прочитать архив", "path", archivePath, "err", err)
+	}
+	return files
+}
+
+// readEntry читает запись целиком, но не больше size байт: заявленный размер мог соврать
+func readEntry(open func() (io.Reader, error), size int64) ([]byte, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(r, size))
+}
+
+// splitArchivePath делит путь виртуального файла на архив на диске и пути записей по вложенности.
+// false - путь не указывает внутрь архива.
+func splitArchivePath(p string) (string, []string, bool) {
+	parts := strings.Split(p, archiveSep)
+	if len(parts) < 2 {
+		return "", nil, false
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if archiveKind(part) == "" {
+			return "", nil, false
+		}
+	}
+	return parts[0], parts[1:], true
+}
+
+// openArchiveEntry открывает запись архива (возможно, вложенного) по пути виртуального файла
+func openArchiveEntry(p string) (fs.File, error) {
+	archivePath, entries, ok := splitArchivePath(p)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	f, err := os.Open(LongPath(archivePath))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var r io.ReaderAt = f
+	size, kind := info.Size(), archiveKind(archivePath)
+	for i, name := range entries {
+		var found io.Reader
+		var foundInfo fs.FileInfo
+		err := forEachEntry(kind, r, size, func(entry string, info fs.FileInfo, open func() (io.Reader, error)) error {
+			if entry != name {
+				return nil
+			}
+			rd, err := open()
+			if err != nil {
+				return err
+			}
+			found, foundInfo = rd, info
+			return errStopEntries
+		})
+		if err != nil && !errors.Is(err, errStopEntries) {
+			f.Close()
+			return nil, err
+		}
+		if found == nil {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+		}
+		if i == len(entries)-1 {
+			return &archiveFile{r: found, info: foundInfo, closer: f}, nil
+		}
+		// Промежуточный архив читается в память: zip нужен произвольный доступ
+		data, err := io.ReadAll(io.LimitReader(found, maxNestedArchiveSize))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r, size, kind = bytes.NewReader(data), int64(len(data)), archiveKind(name)
+	}
+	f.Close()
+	return nil, fmt.Errorf("%s: пустой путь записи архива", p)
+}
+
+// archiveFile - запись архива, открытая для чтения: Stat - сведения о записи, Close закрывает архив
+type archiveFile struct {
+	r      io.Reader
+	info   fs.FileInfo
+	closer io.Closer
+}
+
+func (f *archiveFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *archiveFile) Close() error               { return f.closer.Close() }
@@ -0,0 +1,98 @@
+// Проверка целостности по внешнему файлу контрольных сумм в формате sha256sum
+package scan
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumMismatch - файл, текущий хэш которого не совпал с записанным
+type ChecksumMismatch struct {
+	Path     string
+	Expected string
+	Actual   string // Пусто, если файл не удалось прочитать (см. Err)
+	Err      error
+}
+
+// fullHashModes - режимы, в которых FileInfo.Hash уже содержит полный SHA-256
+var fullHashModes = map[Mode]bool{ModeHash: true, ModeCombined: true, ModeNameSizeHash: true, ModeNameHashDir: true}
+
+// VerifyManifest сверяет файлы с файлом контрольных сумм (вывод sha256sum: "<hex>  <путь>").
+// Проверяются только файлы из files, для которых в нем есть запись; относительные пути
+// в файле сумм, как и у sha256sum -c, считаются от текущей директории.
+// Уже посчитанный полный SHA-256 переиспользуется, остальные файлы читаются заново.
+func (s *Scanner) VerifyManifest(path string, files []FileInfo) ([]ChecksumMismatch, error) {
+	expected, err := readChecksums(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []ChecksumMismatch
+	for _, f := range files {
+		want, ok := expected[checksumKey(f.Path)]
+		if !ok || f.fromManifest {
+			continue
+		}
+		got := f.Hash
+		if !fullHashModes[s.config.Mode] || s.newHash != nil || s.config.ContentOnly || got == "" || f.Err != nil {
+			got, err = s.hashFile(f.Path, f.Size, computeHash)
+		}
+		if err != nil {
+			mismatches = append(mismatches, ChecksumMismatch{Path: f.Path, Expected: want, Err: err})
+			err = nil
+			continue
+		}
+		if got != want {
+			mismatches = append(mismatches, ChecksumMismatch{Path: f.Path, Expected: want, Actual: got})
+		}
+	}
+	return mismatches, nil
+}
+
+// readChecksums читает файл sha256sum: хэш и путь через пробел и " " (текст) или "*" (бинарный режим).
+// Строка, начинающаяся с "\", содержит путь с экранированными "\\" и "\n".
+func readChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(text, `\`)
+		if escaped {
+			text = text[1:]
+		}
+		hash, name, ok := strings.Cut(text, " ")
+		if !ok || len(name) < 2 || (name[0] != ' ' && name[0] != '*') {
+			return nil, fmt.Errorf("%s: строка %d не в формате sha256sum", path, line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+			return nil, fmt.Errorf("%s: строка %d: некорректный хэш SHA-256", path, line)
+		}
+		name = name[1:]
+		if escaped {
+			name = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+		}
+		result[checksumKey(name)] = strings.ToLower(hash)
+	}
+	return result, sc.Err()
+}
+
+// checksumKey приводит путь к виду для сравнения: абсолютный и очищенный
+func checksumKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
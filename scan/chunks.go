@@ -0,0 +1,266 @@
+// Режим chunks: оценка экономии от дедупликации блоками (FastCDC) без изменения файлов
+package scan
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Границы блока FastCDC: средний блок ~1 МБ, как у дедуплицирующих ФС и хранилищ резервных копий
+const (
+	chunkMin  = 256 << 10
+	chunkAvg  = 1 << 20
+	chunkMax  = 4 << 20
+	chunkBits = 20 // log2(chunkAvg)
+)
+
+// defaultChunkIndexMax - сколько блоков держит индекс режима chunks, если Config.ChunkIndexMax
+// не задан: около 200 МБ памяти, а без выборки - около 4 ТБ данных
+const defaultChunkIndexMax = 4 << 20
+
+// Маски нормализованного FastCDC: до среднего размера граница ставится реже (больше бит),
+// после - чаще, так что размеры блоков теснее к chunkAvg. Проверяются старшие биты отпечатка:
+// в них входят последние 64 байта окна.
+const (
+	chunkMaskSmall = (1<<(chunkBits+2) - 1) << (64 - chunkBits - 2)
+	chunkMaskLarge = (1<<(chunkBits-2) - 1) << (64 - chunkBits + 2)
+)
+
+// gearTable - случайные числа для скользящего отпечатка Gear (фиксированные: границы блоков
+// одного содержимого должны совпадать между запусками)
+var gearTable = func() (table [256]uint64) {
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = z ^ z>>31
+	}
+	return table
+}()
+
+// ChunkReport - итог режима chunks. Все цифры экономии - ОЦЕНКА: файлы не меняются, а при
+// большом объеме индекс хранит только выборку блоков (SampleRate) и экономия пересчитывается
+// из нее на весь объем.
+type ChunkReport struct {
+	Files            int64             // Прочитано файлов
+	Bytes            int64             // Прочитано байт
+	Chunks           int64             // Блоков во всех файлах
+	EstimatedSavings int64             // Оценка: сколько байт освободила бы дедупликация блоками (включая целые копии файлов)
+	SampleRate       int64             // В индекс попадал 1 блок из SampleRate (1 - все блоки, оценка точная до коллизий)
+	Dirs             []DirChunkSavings // По папкам, по убыванию оценки; папки без повторов не показываются
+}
+
+// DirChunkSavings - оценка экономии в одной папке (без подпапок). Повторный блок засчитывается
+// папке файла, в котором он встретился не первым, так что у папки с оригиналом экономии может не быть.
+type DirChunkSavings struct {
+	Dir              string
+	Bytes            int64
+	EstimatedSavings int64
+}
+
+// ChunkReport возвращает оценку последнего запуска в режиме chunks (nil в других режимах)
+func (s *Scanner) ChunkReport() *ChunkReport {
+	return s.chunkReport
+}
+
+// fileChunk - блок файла: 64 бита SHA-256 содержимого и длина
+type fileChunk struct {
+	hash uint64
+	size int64
+}
+
+// chunkStats - накопленные байты: все, попавшие в выборку и повторные из выборки
+type chunkStats struct {
+	bytes, sampled, duplicate int64
+}
+
+// estimate пересчитывает повторы выборки на весь объем
+func (c chunkStats) estimate() int64 {
+	if c.sampled == 0 {
+		return 0
+	}
+	return int64(float64(c.duplicate) / float64(c.sampled) * float64(c.bytes))
+}
+
+// chunkIndex - индекс блоков с ограничением памяти. Когда блоков больше limit, в выборке
+// остаются только блоки, у которых младшие биты хэша нулевые, - с каждым разом вдвое меньше.
+// Решение о блоке зависит только от его хэша, поэтому повтор блока из выборки тоже в нее попадет.
+type chunkIndex struct {
+	seen  map[uint64]struct{}
+	mask  uint64
+	limit int
+}
+
+// add учитывает блок; sampled - блок в выборке, duplicate - он уже встречался
+func (x *chunkIndex) add(hash uint64) (sampled, duplicate bool) {
+	if hash&x.mask != 0 {
+		return false, false
+	}
+	if _, ok := x.seen[hash]; ok {
+		return true, true
+	}
+	x.seen[hash] = struct{}{}
+	for len(x.seen) > x.limit {
+		x.mask = x.mask<<1 | 1
+		for h := range x.seen {
+			if h&x.mask != 0 {
+				delete(x.seen, h)
+			}
+		}
+	}
+	return true, false
+}
+
+// estimateChunks делит файлы на блоки, ищет повторы блоков во всех файлах и возвращает оценку.
+// Воркеры читают и режут файлы; индекс пополняет одна горутина (collect), по файлу целиком.
+func (s *Scanner) estimateChunks(files []FileInfo) *ChunkReport {
+	s.setPhase(PhaseHashing)
+	defer s.measure(&s.timings.hash, time.Now())
+
+	limit := s.config.ChunkIndexMax
+	if limit <= 0 {
+		limit = defaultChunkIndexMax
+	}
+	index := &chunkIndex{seen: make(map[uint64]struct{}), limit: limit}
+	// Блоки файла от воркера до collect. Под блокировкой: чтение, брошенное по Config.FileTimeout,
+	// может закончиться уже после того, как collect получил ошибку этого файла.
+	chunks := make([][]fileChunk, len(files))
+	var mu sync.Mutex
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	atomic.StoreInt64(&s.stats.TotalCandidateBytes, total)
+
+	report := &ChunkReport{}
+	var all chunkStats
+	dirs := make(map[string]*chunkStats)
+	s.hashFiles(total, func(send func(hashJob) bool) {
+		for i, f := range files {
+			if !send(hashJob{path: f.Path, size: f.Size, index: i}) {
+				return
+			}
+		}
+	}, func(job hashJob) (string, error) {
+		return s.hashFile(job.path, job.size, countingHash(func(ctx context.Context, file fs.File) (string, error) {
+			list, err := splitChunks(ctxReader{ctx: ctx, r: file})
+			if err == nil {
+				mu.Lock()
+				chunks[job.index] = list
+				mu.Unlock()
+			}
+			return "", err
+		}, job.progress))
+	}, func(r hashResult) {
+		if r.err != nil {
+			return
+		}
+		mu.Lock()
+		list := chunks[r.index]
+		chunks[r.index] = nil
+		mu.Unlock()
+		dir := filepath.Dir(r.path)
+		d := dirs[dir]
+		if d == nil {
+			d = &chunkStats{}
+			dirs[dir] = d
+		}
+		report.Files++
+		report.Chunks += int64(len(list))
+		for _, c := range list {
+			all.bytes += c.size
+			d.bytes += c.size
+			sampled, duplicate := index.add(c.hash)
+			if sampled {
+				all.sampled += c.size
+				d.sampled += c.size
+			}
+			if duplicate {
+				all.duplicate += c.size
+				d.duplicate += c.size
+			}
+		}
+	})
+
+	report.Bytes = all.bytes
+	report.EstimatedSavings = all.estimate()
+	report.SampleRate = int64(index.mask) + 1
+	for dir, d := range dirs {
+		if saved := d.estimate(); saved > 0 {
+			report.Dirs = append(report.Dirs, DirChunkSavings{Dir: dir, Bytes: d.bytes, EstimatedSavings: saved})
+		}
+	}
+	slices.SortFunc(report.Dirs, func(a, b DirChunkSavings) int {
+		if c := cmp.Compare(b.EstimatedSavings, a.EstimatedSavings); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Dir, b.Dir)
+	})
+	s.logger.Info("оценка дедупликации блоками готова", "files", report.Files, "chunks", report.Chunks,
+		"savings", report.EstimatedSavings, "sample_rate", report.SampleRate)
+	return report
+}
+
+// splitChunks режет содержимое r на блоки FastCDC и возвращает их хэши и длины
+func splitChunks(r io.Reader) ([]fileChunk, error) {
+	var chunks []fileChunk
+	buf := make([]byte, chunkMax)
+	n := 0
+	eof := false
+	for {
+		if !eof && n < len(buf) {
+			m, err := io.ReadFull(r, buf[n:])
+			n += m
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if n == 0 {
+			return chunks, nil
+		}
+		// До конца файла в буфере всегда chunkMax байт, так что блок не обрывается на границе чтения
+		cut := chunkCut(buf[:n])
+		sum := sha256.Sum256(buf[:cut])
+		chunks = append(chunks, fileChunk{hash: binary.LittleEndian.Uint64(sum[:8]), size: int64(cut)})
+		n = copy(buf, buf[cut:n])
+	}
+}
+
+// chunkCut возвращает длину первого блока в data (нормализованный FastCDC). Если граница
+// не нашлась, возвращается len(data): это конец файла или блок наибольшего размера.
+func chunkCut(data []byte) int {
+	if len(data) <= chunkMin {
+		return len(data)
+	}
+	end := min(len(data), chunkMax)
+	normal := min(end, chunkAvg)
+	var fp uint64
+	i := chunkMin
+	for ; i < normal; i++ {
+		fp = fp<<1 + gearTable[data[i]]
+		if fp&chunkMaskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < end; i++ {
+		fp = fp<<1 + gearTable[data[i]]
+		if fp&chunkMaskLarge == 0 {
+			return i + 1
+		}
+	}
+	return end
+}
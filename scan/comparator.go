@@ -0,0 +1,71 @@
+// Свое определение дубликата: ключ группировки от пользовательского Comparator
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Comparator задает, что считать дубликатами (Config.Comparator): файлы с равными ключами
+// попадают в одну группу, а Config.Mode не используется. Key вызывается из воркеров
+// конкурентно, по разу на файл, и может читать файл (path - путь ОС). Ошибка - файл не
+// прочитан: он выпадает из поиска и попадает в Errors, как при ошибке хэширования.
+type Comparator interface {
+	Key(path string, info FileInfo) (string, error)
+}
+
+// ComparatorFunc позволяет передать функцию как Comparator
+type ComparatorFunc func(path string, info FileInfo) (string, error)
+
+// Key вызывает f(path, info)
+func (f ComparatorFunc) Key(path string, info FileInfo) (string, error) {
+	return f(path, info)
+}
+
+// ModeComparator возвращает встроенный режим в виде Comparator - например, как основу
+// своего ключа. Имена сравниваются как есть (без NormalizeNames, FuzzyNames,
+// CaseInsensitiveNames и IgnoreExtensionInName), хэш - SHA-256 файла с диска ОС. Режимы name_count и image
+// не сводятся к равенству ключей и как Comparator недоступны.
+func ModeComparator(mode Mode) (Comparator, error) {
+	if err := validateMode(mode); err != nil {
+		return nil, err
+	}
+	size := func(f FileInfo) string { return strconv.FormatInt(f.Size, 10) }
+	switch mode {
+	case ModeNameOnly:
+		return ComparatorFunc(func(_ string, f FileInfo) (string, error) { return f.Name, nil }), nil
+	case ModeNameSize:
+		return ComparatorFunc(func(_ string, f FileInfo) (string, error) { return f.Name + "|" + size(f), nil }), nil
+	case ModeSizeOnly:
+		return ComparatorFunc(func(_ string, f FileInfo) (string, error) { return size(f), nil }), nil
+	case ModeHash:
+		return hashComparator(computeHash, func(f FileInfo, h string) string { return h }), nil
+	case ModeFastHash:
+		return hashComparator(computeFastHash, func(f FileInfo, h string) string { return h }), nil
+	case ModeCombined:
+		return hashComparator(computeHash, func(f FileInfo, h string) string { return f.Name + "|" + h }), nil
+	case ModeNameSizeHash:
+		return hashComparator(computeHash, func(f FileInfo, h string) string { return f.Name + "|" + size(f) + "|" + h }), nil
+	case ModeNameHashDir:
+		return hashComparator(computeHash, func(f FileInfo, h string) string { return f.Name + "|" + h + "|" + parentDirName(f) }), nil
+	}
+	return nil, fmt.Errorf("режим %s не сводится к ключу и как Comparator недоступен", mode)
+}
+
+// hashComparator читает файл, считает hashFn и строит из хэша ключ функцией key
+func hashComparator(hashFn hashFunc, key func(f FileInfo, hash string) string) Comparator {
+	return ComparatorFunc(func(path string, f FileInfo) (string, error) {
+		file, err := os.Open(LongPath(path))
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		hash, err := hashFn(context.Background(), file)
+		if err != nil {
+			return "", err
+		}
+		return key(f, hash), nil
+	})
+}
@@ -0,0 +1,214 @@
+// Настройки сканера и их проверка
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"time"
+)
+
+// Config хранит настройки сканирования. Проверяется в NewScanner.
+type Config struct {
+	DirPath           string        // Путь для сканирования
+	Roots             []string      // Несколько путей для сканирования (если задан, DirPath не используется)
+	SkipMissingRoots  bool          // Корень, которого нет или который не папка, - ошибка в Errors, а не остановка сканирования (RootError)
+	Manifests         []string      // Манифесты для сравнения: их записи группируются как файлы отдельных корней
+	Mode              Mode          // Режим поиска: ModeHash, ModeFastHash и т.д. (см. mode.go)
+	Workers           int           // Количество горутин (0 - подобрать по накопителю корней, см. Scanner.Workers)
+	JobBufferSize     int           // Глубина очереди задач хэширования (0 - по 4 на воркера)
+	DiskType          string        // Тип накопителя: ssd, hdd (чтение по одному файлу), auto
+	ReadRetries       int           // Повторы чтения файла с начала при временных ошибках (EIO, обрыв сети, таймауты); за запуск - не больше 1000
+	FileTimeout       time.Duration // Предел времени на открытие и чтение одного файла (0 - без предела): файл с зависшего сетевого диска пропускается с ErrReadTimeout
+	MaxBytesPerSecond int64         // Общий предел скорости чтения при хэшировании, байт/с (0 - без ограничения)
+	LogLevel          string        // Уровень журнала в stderr: error, warn, info, debug ("" - журнал выключен)
+	LogFormat         string        // Формат журнала: text (по умолчанию), json
+
+	// Logger - свой журнал (например, обработчик приложения); если задан, LogLevel и LogFormat
+	// не используются. nil - журнал по LogLevel, а без него сканер ничего не пишет.
+	// Опция WithLogger важнее этого поля.
+	Logger *slog.Logger
+
+	// Уточнение и фильтрация результата
+	Verify               bool   // Перепроверить результат fast_hash полным хэшем
+	IgnoreEmptyFiles     bool   // Не искать дубликаты среди пустых файлов
+	CrossRoot            bool   // Показывать только дубликаты между разными корнями
+	RedundantIn          string // Показывать только копии из этого корня, которые есть в других корнях
+	ReportUnique         bool   // Вместо дубликатов показать файлы, у которых нет копий
+	DirFilter            string // Фильтр по директориям: within, across, across_top
+	SortBy               string // Порядок групп: reclaimable (по умолчанию), size, count, path
+	SortReverse          bool   // Обратный порядок групп
+	MinGroupSize         int    // Вернуть только группы хотя бы из N файлов (0 - как 2, обычные дубликаты)
+	MaxGroups            int    // Вернуть не больше N групп (первые после сортировки), 0 - без ограничения
+	NameCountThreshold   int    // В режиме name_count: имя попадает в результат, если встречается не меньше N раз (0 - от двух)
+	NormalizeNames       bool   // Приводить имена к NFC перед сравнением (в DefaultConfig включено)
+	FuzzyNames           bool   // Сравнивать имена без примет копии: "photo (1).jpg" = "photo.jpg" (см. CanonicalName)
+	CaseInsensitiveNames bool   // Не различать регистр в именах файлов в режимах с именем (в DefaultConfig - на Windows и macOS)
+	// IgnoreExtensionInName - сравнивать имена без расширения: "song.mp3" и "song.flac" - одно имя.
+	// Влияет только на режимы с именем в ключе (name_only, name_size, name_count, combined,
+	// name_size_hash, name_hash_dir): в combined группируются файлы с одним именем и одним
+	// содержимым, но разными расширениями. В hash, fast_hash, size_only, image и compare имя
+	// в ключ не входит, там такие файлы группируются и без этой настройки.
+	IgnoreExtensionInName bool
+	// RequireSameName - в режимах hash, fast_hash, size_only и compare копиями считаются только
+	// файлы с одинаковым именем (с учетом NormalizeNames, CaseInsensitiveNames, IgnoreExtensionInName,
+	// FuzzyNames) и размером: hash с ним - то же, что name_size_hash. В режимах с именем в ключе
+	// имя и так должно совпасть - там настройка ничего не меняет. С image, chunks и Comparator
+	// несовместима: у них нет ключа из размера, к которому добавить имя.
+	RequireSameName bool
+	DirDuplicates   bool   // Искать папки-копии: все файлы директории есть в одной другой директории
+	DirTrees        bool   // Искать одинаковые деревья папок (Result.TreeDuplicates); их группы файлов убираются из Result.Groups
+	FindPrefixes    bool   // Искать недокачанные копии: файлы, содержимое которых - начало большего файла (Result.PrefixMatches); файлы меньше 4 КБ не проверяются
+	HashAll         bool   // Хэшировать все файлы, а не только кандидатов в дубликаты (Scanner.AllFiles): например, для индекса по содержимому
+	CheckpointDir   string // Куда сохранять состояние полного хэша больших файлов (ResumableHash): чтение, прерванное обрывом или остановкой, продолжится с того же места; пусто - не сохранять
+	ContentOnly     bool   // Хэшировать JPEG, PNG, MP3, FLAC и PDF без метаданных (EXIF, теги ID3...): файлы с разными тегами совпадут; хэш помечается форматом (ContentOnlyFormat)
+	ImageDistance   int    // В режиме image: наибольшее расстояние Хэмминга между похожими картинками, 0-64 (в DefaultConfig - 5; 0 - только равные хэши)
+	ChunkIndexMax   int    // В режиме chunks: сколько блоков держит индекс (0 - около 4 млн, ~200 МБ); при большем объеме оценка строится по выборке блоков
+	IgnoreHardlinks bool   // Жесткие ссылки на один файл - один файл, а не дубликаты (в DefaultConfig включено; см. Scanner.Hardlinks)
+	// AnnotateHardlinks - вместо IgnoreHardlinks (с ним несовместимо): группы, где все пути - жесткие
+	// ссылки на один файл, убираются целиком, а в группах из настоящих копий и ссылок все пути
+	// остаются, ссылки помечаются FileInfo.HardlinkOf. Reclaimable считает ссылки одним файлом.
+	AnnotateHardlinks bool
+
+	// Архивы (только файловая система ОС, не Config.FS). Записи .zip, .tar, .tar.gz (.tgz)
+	// становятся виртуальными файлами "архив!/путь/в/архиве" с ReadOnly: они группируются как
+	// обычные файлы и читаются без распаковки на диск. Запись больше ArchiveMaxEntrySize
+	// пропускается, вложенные архивы раскрываются до глубины ArchiveMaxDepth - так архив-бомба
+	// не займет сканер надолго.
+	ScanArchives        bool  // Заглядывать внутрь архивов
+	ArchiveMaxEntrySize int64 // Наибольший размер записи, байт (0 - DefaultArchiveMaxEntrySize)
+	ArchiveMaxDepth     int   // Глубина вложенности: 1 - только архивы на диске (0 - DefaultArchiveMaxDepth)
+
+	// Фильтр по владельцу (только unix; на других ОС не работает, о чем пишется в журнал).
+	// Чужие файлы пропускаются при обходе и считаются в Stats.OtherOwnerFiles.
+	OwnerUID        *int // Брать только файлы владельца с этим uid (nil - любого)
+	OnlyCurrentUser bool // Брать только файлы текущего пользователя (вместо OwnerUID)
+
+	// FileFilter - пользовательский фильтр, вызывается при обходе для каждого файла.
+	// false - файл пропускается. Встроенные фильтры применяются после него (см. walkRoot).
+	FileFilter func(path string, info fs.FileInfo) bool
+
+	// FilesFrom - список файлов вместо обхода корней: пути через перевод строки или NUL
+	// (find -print0). Каждый путь проверяется os.Stat; которого нет - ошибка в Stats.Errors.
+	// Корни при этом не задаются (или DirPath = FilesFromStdin - это то же, что os.Stdin);
+	// FileInfo.Root у файлов - FilesFromStdin. Список читается один раз: для повторного Run
+	// нужен новый Scanner.
+	FilesFrom io.Reader
+
+	// FS - файловая система для обхода и чтения (например, fstest.MapFS или zip.Reader).
+	// nil - обычная файловая система ОС. С FS корни задаются путями внутри нее ("." - весь FS),
+	// а действия над найденными файлами неприменимы. То же задает опция WithFS.
+	FS fs.FS
+
+	// S3 - доступ к бакету, когда корни заданы как "s3://bucket/prefix" (все в одном бакете):
+	// тогда обход и чтение идут через S3FS, а пути в результате - ключи объектов.
+	// nil - настройки из переменных окружения (S3ConfigFromEnv).
+	S3 *S3Config
+
+	// SSH - подключение к серверам удаленных корней "sftp://user@host/path" (см. SSHFS).
+	// Их можно смешивать с локальными корнями; удаленные файлы только читаются (ReadOnly).
+	// nil - системный ssh с настройками по умолчанию.
+	SSH *SSHConfig
+
+	// Comparator - свое определение дубликата вместо Mode: группы по равным ключам Comparator.Key.
+	// Группа кандидатов одна на все файлы, так что Stream отдаст группы только в конце.
+	Comparator Comparator
+
+	// OnProgress - колбэк прогресса: вызывается не чаще раза в 200 мс и при смене фазы,
+	// всегда из одной горутины. Воркеры его не ждут - медленный колбэк пропускает обновления.
+	OnProgress func(Progress)
+
+	// OnError вызывается для каждого файла, который не удалось прочитать при хэшировании.
+	// Вызывается прямо из воркеров, конкурентно: реализация должна быть потокобезопасной.
+	OnError func(path string, err error)
+}
+
+// validate проверяет настройки; roots - корни после устранения пересечений
+func (c Config) validate(roots []string) error {
+	if err := validateMode(c.Mode); err != nil {
+		return err
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("нужен хотя бы один воркер, задано %d", c.Workers)
+	}
+	if c.MinGroupSize < 0 || c.MinGroupSize == 1 {
+		return fmt.Errorf("в группе дубликатов хотя бы два файла, задано MinGroupSize %d", c.MinGroupSize)
+	}
+	if c.ImageDistance < 0 || c.ImageDistance > 64 {
+		return fmt.Errorf("расстояние между хэшами изображений - от 0 до 64, задано ImageDistance %d", c.ImageDistance)
+	}
+	if c.ContentOnly && !fullHashModes[c.Mode] {
+		return fmt.Errorf("ContentOnly работает только в режимах с полным хэшем содержимого, а не %s", c.Mode)
+	}
+	if c.DirTrees && !fullHashModes[c.Mode] && c.Comparator == nil {
+		return fmt.Errorf("одинаковые деревья папок ищутся только в режимах с полным хэшем содержимого, а не %s", c.Mode)
+	}
+	if c.HashAll && !fullHashModes[c.Mode] {
+		return fmt.Errorf("HashAll считает полный хэш содержимого: нужен режим с ним, а не %s", c.Mode)
+	}
+	if c.HashAll && c.Comparator != nil {
+		return errors.New("HashAll несовместим с Comparator: группы строятся по его ключам, а не по хэшу")
+	}
+	if c.RequireSameName && (c.Mode == ModeImage || c.Mode == ModeChunks || c.Comparator != nil) {
+		return fmt.Errorf("RequireSameName добавляет имя к ключу режимов hash, fast_hash, size_only и compare, а не %s", c.Mode)
+	}
+	if c.AnnotateHardlinks && c.IgnoreHardlinks {
+		return errors.New("AnnotateHardlinks показывает жесткие ссылки в группах, а IgnoreHardlinks их убирает: выберите одно")
+	}
+	if c.NameCountThreshold < 0 {
+		return fmt.Errorf("отрицательный порог повторов имени: %d", c.NameCountThreshold)
+	}
+	if c.OwnerUID != nil && c.OnlyCurrentUser {
+		return errors.New("OwnerUID и OnlyCurrentUser взаимоисключающие")
+	}
+	if c.OwnerUID != nil && *c.OwnerUID < 0 {
+		return fmt.Errorf("некорректный uid владельца: %d", *c.OwnerUID)
+	}
+	if c.ArchiveMaxEntrySize < 0 || c.ArchiveMaxDepth < 0 {
+		return fmt.Errorf("отрицательный предел для архивов: размер %d, глубина %d", c.ArchiveMaxEntrySize, c.ArchiveMaxDepth)
+	}
+	if c.JobBufferSize < 0 {
+		return fmt.Errorf("отрицательный размер очереди задач: %d", c.JobBufferSize)
+	}
+	if c.ChunkIndexMax < 0 {
+		return fmt.Errorf("отрицательный размер индекса блоков: %d", c.ChunkIndexMax)
+	}
+	if c.ReadRetries < 0 {
+		return fmt.Errorf("отрицательное число повторов чтения: %d", c.ReadRetries)
+	}
+	if c.FileTimeout < 0 {
+		return fmt.Errorf("отрицательный предел времени на файл: %s", c.FileTimeout)
+	}
+	if c.MaxBytesPerSecond < 0 {
+		return fmt.Errorf("отрицательный предел скорости чтения: %d", c.MaxBytesPerSecond)
+	}
+	if err := validateDiskType(c.DiskType); err != nil {
+		return err
+	}
+	if err := validateLogConfig(c.LogLevel, c.LogFormat); err != nil {
+		return err
+	}
+	if err := validateDirFilter(c.DirFilter); err != nil {
+		return err
+	}
+	if err := validateSortBy(c.SortBy); err != nil {
+		return err
+	}
+	if len(c.Manifests) > 0 {
+		if err := validateManifestMode(c.Mode); err != nil {
+			return err
+		}
+		if c.ContentOnly {
+			return errors.New("манифест хранит хэши файлов целиком, сравнение с ним несовместимо с ContentOnly")
+		}
+		if c.Comparator != nil {
+			return errors.New("записи манифеста - не файлы, Comparator их не прочитает")
+		}
+	}
+	if c.RedundantIn != "" && !isScanRoot(c.RedundantIn, roots) {
+		return fmt.Errorf("RedundantIn должен совпадать с одним из корней сканирования: %s", c.RedundantIn)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// Хэш содержимого медиафайлов без метаданных (Config.ContentOnly)
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// contentOnlySep отделяет формат от хэша: "jpeg:<hex>". По префиксу видно, что хэш
+// посчитан без метаданных, а хэши разных форматов и целых файлов не совпадают.
+const contentOnlySep = ":"
+
+// ContentOnlyFormat возвращает формат, без метаданных которого посчитан хэш (jpeg, png, mp3, flac, pdf),
+// или "", если хэш посчитан по всему файлу
+func ContentOnlyFormat(hash string) string {
+	format, _, ok := strings.Cut(hash, contentOnlySep)
+	if !ok {
+		return ""
+	}
+	return format
+}
+
+// mediaExts - расширения форматов ContentOnly. У таких файлов размер при группировке не учитывается:
+// копия с другими тегами - другого размера. Сам формат определяется по сигнатуре при чтении.
+var mediaExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".mp3": true, ".flac": true, ".pdf": true}
+
+// sizeKey - размер файла в ключе группировки; при ContentOnly у медиафайлов - общий ключ вместо размера
+func (s *Scanner) sizeKey(f FileInfo) string {
+	if s.config.ContentOnly && mediaExts[strings.ToLower(filepath.Ext(f.Name))] {
+		return "media"
+	}
+	return strconv.FormatInt(f.Size, 10)
+}
+
+// mediaFormat - распознаваемый формат: сигнатура в начале файла и обертка, пропускающая метаданные
+type mediaFormat struct {
+	name  string
+	match func(head []byte) bool
+	wrap  func(r *bufio.Reader) io.Reader
+}
+
+// mediaFormats - форматы, которые ContentOnly хэширует без метаданных; остальные файлы хэшируются целиком
+var mediaFormats = []mediaFormat{
+	{"jpeg", func(h []byte) bool { return bytes.HasPrefix(h, []byte{0xFF, 0xD8, 0xFF}) }, newJPEGReader},
+	{"png", func(h []byte) bool { return bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")) }, newPNGReader},
+	{"flac", func(h []byte) bool { return bytes.HasPrefix(h, []byte("fLaC")) }, newFLACReader},
+	{"pdf", func(h []byte) bool { return bytes.HasPrefix(h, []byte("%PDF-")) }, newPDFReader},
+	{"mp3", func(h []byte) bool {
+		// ID3v2 в начале или сразу заголовок кадра MPEG (11 единичных бит синхронизации)
+		return bytes.HasPrefix(h, []byte("ID3")) || len(h) >= 2 && h[0] == 0xFF && h[1]&0xE0 == 0xE0
+	}, newMP3Reader},
+}
+
+// contentOnlyHash хэширует поток содержимого распознанного формата без метаданных
+// и помечает хэш форматом; нераспознанный файл хэшируется целиком, как обычно
+func contentOnlyHash(hashFn hashFunc) hashFunc {
+	return func(ctx context.Context, file fs.File) (string, error) {
+		br := bufio.NewReader(ctxReader{ctx: ctx, r: file})
+		head, _ := br.Peek(8)
+		for _, format := range mediaFormats {
+			if format.match(head) {
+				hash, err := hashFn(ctx, readerFile{File: file, r: format.wrap(br)})
+				if err != nil {
+					return "", err
+				}
+				return format.name + contentOnlySep + hash, nil
+			}
+		}
+		return hashFn(ctx, readerFile{File: file, r: br})
+	}
+}
+
+// readerFile подменяет чтение файла другим потоком (Stat и Close - от самого файла)
+type readerFile struct {
+	fs.File
+	r io.Reader
+}
+
+func (f readerFile) Read(p []byte) (int, error) {
+	return f.r.Read(p)
+}
+
+// segmentReader - основа оберток: отдает pending, затем keep байт тела как есть
+// (-1 - все до конца файла), а когда и то и другое кончилось, next разбирает следующий сегмент:
+// решает, что пропустить, и заполняет pending и keep. io.EOF из next - поток закончился.
+// Оборванный файл не ошибка: хэшируется то, что в нем есть.
+type segmentReader struct {
+	r       *bufio.Reader
+	pending []byte
+	keep    int64
+	next    func(sr *segmentReader) error
+	err     error // Ошибка next: возвращается, когда pending отдан
+}
+
+func (sr *segmentReader) Read(p []byte) (int, error) {
+	for {
+		if len(sr.pending) > 0 {
+			n := copy(p, sr.pending)
+			sr.pending = sr.pending[n:]
+			return n, nil
+		}
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.keep < 0 {
+			return sr.r.Read(p)
+		}
+		if sr.keep > 0 {
+			if int64(len(p)) > sr.keep {
+				p = p[:sr.keep]
+			}
+			n, err := sr.r.Read(p)
+			sr.keep -= int64(n)
+			if err == io.EOF && sr.keep == 0 {
+				err = nil // Конец сегмента совпал с концом файла: следующий next вернет io.EOF
+			}
+			return n, err // Оборванный сегмент - просто конец потока
+		}
+		sr.err = sr.next(sr)
+	}
+}
+
+// readHeader читает заголовок сегмента; чистый конец файла на границе сегментов - io.EOF
+func (sr *segmentReader) readHeader(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := io.ReadFull(sr.r, buf)
+	if err == io.ErrUnexpectedEOF {
+		// Обрывок в конце файла отдаем как есть: это содержимое, а не метаданные
+		sr.pending, err = buf[:read], io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// skip пропускает n байт тела сегмента (если файл оборван, это конец потока)
+func (sr *segmentReader) skip(n int64) error {
+	_, err := io.CopyN(io.Discard, sr.r, n)
+	return err
+}
+
+// newJPEGReader пропускает сегменты APP0-APP15 (EXIF, XMP, JFIF, ICC) и комментарии (COM).
+// С начала изображения (SOS) файл отдается как есть.
+func newJPEGReader(r *bufio.Reader) io.Reader {
+	return &segmentReader{r: r, next: func(sr *segmentReader) error {
+		marker, err := sr.readHeader(2)
+		if err != nil {
+			return err
+		}
+		if marker[0] != 0xFF {
+			// Не маркер: структура непонятна, остаток файла хэшируется целиком
+			sr.pending, sr.keep = marker, -1
+			return nil
+		}
+		switch m := marker[1]; {
+		case m == 0xD8 || m == 0x01 || m >= 0xD0 && m <= 0xD7: // Маркеры без длины
+			sr.pending = marker
+		case m == 0xDA: // SOS: дальше сжатые данные изображения
+			sr.pending, sr.keep = marker, -1
+		default:
+			size, err := sr.readHeader(2)
+			if err != nil {
+				return err
+			}
+			length := int64(binary.BigEndian.Uint16(size)) - 2
+			if length < 0 {
+				return errors.New("jpeg: некорректная длина сегмента")
+			}
+			if m >= 0xE0 && m <= 0xEF || m == 0xFE {
+				return sr.skip(length)
+			}
+			sr.pending, sr.keep = append(marker, size...), length
+		}
+		return nil
+	}}
+}
+
+// pngMetaChunks - вспомогательные блоки PNG с метаданными: текст, время изменения, EXIF
+var pngMetaChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "tIME": true, "eXIf": true}
+
+// newPNGReader пропускает текстовые блоки, время изменения и EXIF; блоки изображения отдаются целиком
+func newPNGReader(r *bufio.Reader) io.Reader {
+	return &segmentReader{r: r, keep: 8, next: func(sr *segmentReader) error { // keep: сигнатура PNG
+		header, err := sr.readHeader(8)
+		if err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header)) + 4 // Данные и CRC
+		if pngMetaChunks[string(header[4:])] {
+			return sr.skip(length)
+		}
+		sr.pending, sr.keep = header, length
+		return nil
+	}}
+}
+
+// newFLACReader оставляет из блоков метаданных FLAC только STREAMINFO (параметры потока),
+// а теги, обложки, отступы и таблицы поиска пропускает; аудиокадры отдаются как есть
+func newFLACReader(r *bufio.Reader) io.Reader {
+	last := false
+	return &segmentReader{r: r, keep: 4, next: func(sr *segmentReader) error { // keep: "fLaC"
+		if last {
+			sr.keep = -1
+			return nil
+		}
+		header, err := sr.readHeader(4)
+		if err != nil {
+			return err
+		}
+		last = header[0]&0x80 != 0
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if header[0]&0x7F != 0 { // Не STREAMINFO
+			return sr.skip(length)
+		}
+		// Заголовок блока не отдаем: в нем флаг "последний блок", а он зависит от пропущенных блоков
+		sr.keep = length
+		return nil
+	}}
+}
+
+// id3v1Size - размер тега ID3v1 в конце MP3-файла
+const id3v1Size = 128
+
+// newMP3Reader пропускает тег ID3v2 в начале файла и ID3v1 в конце
+func newMP3Reader(r *bufio.Reader) io.Reader {
+	if head, err := r.Peek(10); err == nil && bytes.HasPrefix(head, []byte("ID3")) {
+		// Размер - 4 байта по 7 значащих бит (synchsafe), без 10 байт заголовка; флаг 0x10 - есть еще футер
+		size := int64(head[6]&0x7F)<<21 | int64(head[7]&0x7F)<<14 | int64(head[8]&0x7F)<<7 | int64(head[9]&0x7F) + 10
+		if head[5]&0x10 != 0 {
+			size += 10
+		}
+		if _, err := r.Discard(int(size)); err != nil {
+			return bytes.NewReader(nil) // Файл оборван внутри тега: звука в нем нет
+		}
+	}
+	return &trailerReader{r: r, size: id3v1Size, drop: func(tail []byte) bool { return bytes.HasPrefix(tail, []byte("TAG")) }}
+}
+
+// trailerReader придерживает последние size байт потока и в конце отбрасывает их, если drop(tail)
+type trailerReader struct {
+	r     io.Reader
+	size  int
+	drop  func(tail []byte) bool
+	buf   []byte // Прочитано, но еще не отдано (последние size байт могут оказаться тегом)
+	chunk []byte
+	eof   bool
+}
+
+func (t *trailerReader) Read(p []byte) (int, error) {
+	for !t.eof && len(t.buf) <= t.size {
+		if len(t.chunk) < len(p) {
+			t.chunk = make([]byte, max(len(p), t.size))
+		}
+		n, err := t.r.Read(t.chunk)
+		t.buf = append(t.buf, t.chunk[:n]...)
+		if err == io.EOF {
+			t.eof = true
+			if len(t.buf) >= t.size && t.drop(t.buf[len(t.buf)-t.size:]) {
+				t.buf = t.buf[:len(t.buf)-t.size]
+			}
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	avail := len(t.buf)
+	if !t.eof {
+		avail -= t.size // Хвост держим, пока не ясно, конец ли это
+	}
+	if avail == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, t.buf[:avail])
+	t.buf = t.buf[n:]
+	return n, nil
+}
+
+// pdfMetaValue - значения полей информационного словаря PDF, которые меняет любое пересохранение:
+// даты, программа-создатель и идентификатор файла
+var pdfMetaValue = regexp.MustCompile(`/(CreationDate|ModDate|Producer|Creator)\s*(\((\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)|/ID\s*\[[^\]]*\]`)
+
+// newPDFReader построчно убирает из PDF поля информационного словаря (pdfMetaValue),
+// XMP-пакеты и таблицы смещений (xref, startxref): после правки метаданных смещения объектов
+// сдвигаются. Это лучшее, что можно сделать без разбора PDF: метаданные в сжатых потоках
+// объектов (PDF 1.5+) остаются как есть, и такие файлы совпадут, только если совпадают целиком.
+func newPDFReader(r *bufio.Reader) io.Reader {
+	var inXref, inXMP, afterStartxref bool
+	return &segmentReader{r: r, next: func(sr *segmentReader) error {
+		line, err := sr.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Длинная строка - двоичные данные потока: отдаем как есть
+			sr.pending = append([]byte(nil), line...)
+			return nil
+		}
+		if len(line) == 0 && err != nil {
+			return err
+		}
+		trimmed := bytes.TrimSpace(line)
+		switch {
+		case afterStartxref: // Строка после startxref - смещение таблицы
+			afterStartxref = false
+		case inXref:
+			inXref = !bytes.HasPrefix(trimmed, []byte("trailer"))
+			if !inXref {
+				sr.pending = pdfMetaValue.ReplaceAll(line, nil)
+			}
+		case inXMP:
+			inXMP = !bytes.Contains(line, []byte("<?xpacket end"))
+		case bytes.Equal(trimmed, []byte("xref")):
+			inXref = true
+		case bytes.Equal(trimmed, []byte("startxref")):
+			afterStartxref = true
+		case bytes.Contains(line, []byte("<?xpacket begin")):
+			inXMP = !bytes.Contains(line, []byte("<?xpacket end"))
+		default:
+			sr.pending = pdfMetaValue.ReplaceAll(line, nil)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,110 @@
+// Сравнение деревьев: дубликаты между разными корнями и разбиение двух деревьев по содержимому
+package scan
+
+import (
+	"context"
+	"errors"
+)
+
+// filterCrossRoot оставляет только группы, в которых есть файлы минимум из двух корней.
+// Дубликаты внутри одного корня не интересны, когда вопрос "что из B уже есть в A".
+func filterCrossRoot(groups [][]FileInfo) [][]FileInfo {
+	var result [][]FileInfo
+	for _, group := range groups {
+		for _, f := range group[1:] {
+			if f.Root != group[0].Root {
+				result = append(result, group)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// redundantIn отвечает на вопрос "что в корне root можно удалить, потому что это есть в других корнях".
+// Из каждой группы, где есть копии и в root, и вне его, остается одна копия вне root (эталон,
+// идет первой) и все копии из root. Поэтому действие со стратегией first затронет только root.
+func redundantIn(groups [][]FileInfo, root string) [][]FileInfo {
+	target := resolvePath(root)
+	// Корней немного, а файлов много: EvalSymlinks считаем один раз на корень
+	isTarget := make(map[string]bool)
+	var result [][]FileInfo
+	for _, group := range groups {
+		var reference *FileInfo
+		var inside []FileInfo
+		for i, f := range group {
+			inTarget, ok := isTarget[f.Root]
+			if !ok {
+				inTarget = resolvePath(f.Root) == target
+				isTarget[f.Root] = inTarget
+			}
+			switch {
+			case inTarget:
+				inside = append(inside, f)
+			case reference == nil:
+				reference = &group[i]
+			}
+		}
+		if reference == nil || len(inside) == 0 {
+			continue
+		}
+		result = append(result, append([]FileInfo{*reference}, inside...))
+	}
+	return result
+}
+
+// CompareDirs сравнивает два дерева по содержимому и делит все их файлы на три части:
+// common - группы одинаковых файлов, которые есть в обоих деревьях,
+// onlyA и onlyB - группы (в том числе из одного файла), содержимого которых нет в другом дереве.
+// Из cfg берутся настройки чтения (Workers, DiskType, FileFilter...), режим всегда hash,
+// фильтры результата (в том числе MinGroupSize, MaxGroups, RequireSameName) и настройки
+// жестких ссылок не применяются. Нечитаемые файлы не попадают ни в одну часть.
+func CompareDirs(ctx context.Context, a, b string, cfg Config) (common, onlyA, onlyB [][]FileInfo, err error) {
+	cfg.DirPath = ""
+	cfg.Roots = []string{a, b}
+	cfg.Manifests = nil
+	cfg.Mode = ModeHash
+	cfg.ReportUnique = true // Файлы без копий тоже нужны: они и есть "только в A" и "только в B"
+	cfg.CrossRoot, cfg.RedundantIn, cfg.DirFilter = false, "", DirFilterNone
+	cfg.MinGroupSize, cfg.MaxGroups = 0, 0
+	cfg.RequireSameName, cfg.DirTrees = false, false // Убрали бы группы из Groups
+	// Жесткие ссылки - тоже файлы дерева: без IgnoreHardlinks они не схлопываются в один путь
+	cfg.IgnoreHardlinks, cfg.AnnotateHardlinks = false, false
+
+	scanner, err := NewScanner(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	roots := scanner.Roots()
+	if len(roots) < 2 {
+		return nil, nil, nil, errors.New("деревья совпадают или одно вложено в другое")
+	}
+	result, err := scanner.Run(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, group := range result.Groups {
+		inA, inB := false, false
+		for _, f := range group {
+			inA = inA || f.Root == roots[0]
+			inB = inB || f.Root == roots[1]
+		}
+		switch {
+		case inA && inB:
+			common = append(common, group)
+		case inA:
+			onlyA = append(onlyA, group)
+		default:
+			onlyB = append(onlyB, group)
+		}
+	}
+	for _, f := range result.Unique {
+		if f.Root == roots[0] {
+			onlyA = append(onlyA, []FileInfo{f})
+		} else {
+			onlyB = append(onlyB, []FileInfo{f})
+		}
+	}
+	return common, onlyA, onlyB, nil
+}
@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// groupPaths - пути групп относительно base, группы и пути внутри них по порядку
+func groupPaths(t *testing.T, base string, groups [][]FileInfo) [][]string {
+	t.Helper()
+	var result [][]string
+	for _, group := range groups {
+		var paths []string
+		for _, f := range group {
+			rel, err := filepath.Rel(base, f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		slices.Sort(paths)
+		result = append(result, paths)
+	}
+	slices.SortFunc(result, func(a, b []string) int { return slices.Compare(a, b) })
+	return result
+}
+
+func TestCompareDirs(t *testing.T) {
+	base := t.TempDir()
+	writeFiles(t, base, map[string]string{
+		"a/common.txt":   "common",
+		"a/copy.txt":     "common",
+		"a/only.txt":     "only in a",
+		"a/twice1.txt":   "twice in a",
+		"a/twice2.txt":   "twice in a",
+		"b/common.txt":   "common",
+		"b/only.txt":     "only in b",
+		"b/sub/also.txt": "also common",
+		"a/sub/also.txt": "also common",
+	})
+	// Фильтры результата из cfg не должны выкинуть группы ни из одной части
+	cfg := DefaultConfig("")
+	cfg.MinGroupSize = 3
+	cfg.MaxGroups = 1
+	cfg.AnnotateHardlinks, cfg.IgnoreHardlinks = true, false
+
+	common, onlyA, onlyB, err := CompareDirs(context.Background(), filepath.Join(base, "a"), filepath.Join(base, "b"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  [][]FileInfo
+		want [][]string
+	}{
+		{"common", common, [][]string{
+			{"a/common.txt", "a/copy.txt", "b/common.txt"},
+			{"a/sub/also.txt", "b/sub/also.txt"},
+		}},
+		{"onlyA", onlyA, [][]string{{"a/only.txt"}, {"a/twice1.txt", "a/twice2.txt"}}},
+		{"onlyB", onlyB, [][]string{{"b/only.txt"}}},
+	}
+	for _, tt := range tests {
+		if got := groupPaths(t, base, tt.got); !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Папки-копии: директории, все файлы которых есть в одной другой директории
+package scan
+
+import "sort"
+
+// DirDuplicate - пара директорий: у каждого файла A есть копия в B (только файлы прямо в A,
+// без поддиректорий). Если и у каждого файла B есть копия в A, пара одна, A < B и Mutual.
+type DirDuplicate struct {
+	A      string
+	B      string
+	Files  int  // Сколько файлов в A
+	Mutual bool // B тоже целиком копия A: папки одинаковы
+}
+
+// countDirFile учитывает файл в директории (для поиска папок-копий)
+func (s *Scanner) countDirFile(f FileInfo) {
+	if !s.config.DirDuplicates || f.fromManifest {
+		return
+	}
+	if s.dirFiles == nil {
+		s.dirFiles = make(map[string]int)
+	}
+	s.dirFiles[parentDir(f)]++
+}
+
+// findDirDuplicates ищет пары директорий, в которых все файлы одной имеют копии в другой.
+// dirFiles - сколько файлов было в каждой директории до группировки: файлы без копий
+// в группы не попадают, а папка с таким файлом не копия.
+func findDirDuplicates(groups [][]FileInfo, dirFiles map[string]int) []DirDuplicate {
+	// covered[A][B] - сколько файлов A имеют копию в B
+	covered := make(map[string]map[string]int)
+	for _, group := range groups {
+		dirs := make(map[string]int) // Директория -> сколько ее файлов в группе
+		for _, f := range group {
+			if !f.fromManifest {
+				dirs[parentDir(f)]++
+			}
+		}
+		for a, n := range dirs {
+			for b := range dirs {
+				if a == b {
+					continue
+				}
+				if covered[a] == nil {
+					covered[a] = make(map[string]int)
+				}
+				covered[a][b] += n
+			}
+		}
+	}
+
+	var result []DirDuplicate
+	for a, byDir := range covered {
+		for b, n := range byDir {
+			if n != dirFiles[a] {
+				continue
+			}
+			mutual := covered[b][a] == dirFiles[b]
+			if mutual && b < a {
+				continue // Эту пару добавит обход с A и B наоборот
+			}
+			result = append(result, DirDuplicate{A: a, B: b, Files: n, Mutual: mutual})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].A != result[j].A {
+			return result[i].A < result[j].A
+		}
+		return result[i].B < result[j].B
+	})
+	return result
+}
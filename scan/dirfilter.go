@@ -0,0 +1,76 @@
+// Фильтр групп по директориям: дубликаты внутри одной папки или между разными папками
+package scan
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Поддерживаемые значения Config.DirFilter
+const (
+	DirFilterNone      = ""           // Без фильтра
+	DirFilterWithin    = "within"     // Все файлы группы в одной директории
+	DirFilterAcross    = "across"     // Файлы группы минимум в двух разных директориях
+	DirFilterAcrossTop = "across_top" // Файлы группы минимум в двух разных директориях верхнего уровня корня
+)
+
+// validateDirFilter проверяет значение Config.DirFilter
+func validateDirFilter(filter string) error {
+	switch filter {
+	case DirFilterNone, DirFilterWithin, DirFilterAcross, DirFilterAcrossTop:
+		return nil
+	}
+	return fmt.Errorf("неизвестный фильтр директорий %q (допустимо: %s, %s, %s)",
+		filter, DirFilterWithin, DirFilterAcross, DirFilterAcrossTop)
+}
+
+// filterByDirs оставляет группы, подходящие под фильтр директорий
+func filterByDirs(groups [][]FileInfo, filter string) [][]FileInfo {
+	if filter == DirFilterNone {
+		return groups
+	}
+	dirOf := parentDir
+	if filter == DirFilterAcrossTop {
+		dirOf = topLevelDir
+	}
+
+	var result [][]FileInfo
+	for _, group := range groups {
+		distinct := countDistinct(group, dirOf)
+		if (filter == DirFilterWithin && distinct == 1) || (filter != DirFilterWithin && distinct > 1) {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+// countDistinct считает, сколько разных директорий встречается в группе
+func countDistinct(group []FileInfo, dirOf func(FileInfo) string) int {
+	seen := make(map[string]bool)
+	for _, f := range group {
+		seen[dirOf(f)] = true
+	}
+	return len(seen)
+}
+
+// parentDir возвращает директорию файла. filepath.Dir сравнивает пути по компонентам,
+// поэтому "a/b" и "a/bc" - разные директории, а "a//b/" и "a/b" - одна.
+func parentDir(f FileInfo) string {
+	return filepath.Dir(filepath.Clean(f.Path))
+}
+
+// topLevelDir возвращает первую директорию пути относительно корня сканирования.
+// Файлы, лежащие прямо в корне, относятся к самому корню (пустая строка).
+// Корень тоже входит в ключ: при нескольких корнях их верхние уровни не смешиваются.
+func topLevelDir(f FileInfo) string {
+	rel, err := filepath.Rel(f.Root, f.Path)
+	if err != nil {
+		return parentDir(f)
+	}
+	top := ""
+	if i := strings.IndexRune(rel, filepath.Separator); i >= 0 {
+		top = rel[:i]
+	}
+	return f.Root + string(filepath.Separator) + top
+}
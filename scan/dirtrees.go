@@ -0,0 +1,208 @@
+// Одинаковые деревья папок: та же структура и то же содержимое файлов (хэш Меркла по директориям)
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TreeDuplicate - директории, одинаковые целиком вместе с поддиректориями: те же относительные
+// пути файлов и то же содержимое. Вложенные совпадения (a/x = b/x при a = b) не показываются.
+type TreeDuplicate struct {
+	Dirs  []string // Папки-копии, по возрастанию пути
+	Files int      // Файлов в каждой папке, со всеми поддиректориями
+	Size  int64    // Объем одной копии, байт
+
+	// Группы дубликатов, целиком лежащие внутри этих папок: в Result.Groups их нет,
+	// чтобы отчет не распадался на тысячи отдельных файлов
+	Groups [][]FileInfo
+}
+
+// treeEntry - файл или поддиректория в листинге директории
+type treeEntry struct {
+	name string
+	dir  bool
+}
+
+// recordTreeFile запоминает файл для поиска одинаковых деревьев
+func (s *Scanner) recordTreeFile(f FileInfo) {
+	if !s.config.DirTrees || f.fromManifest {
+		return
+	}
+	s.treeFiles = append(s.treeFiles, f)
+}
+
+// dirTree - директория со всем, что в ней нашел обход
+type dirTree struct {
+	entries map[treeEntry]bool
+	files   int
+	size    int64
+	hash    string // Хэш Меркла; пустой, если в дереве есть файл без копий
+}
+
+// findTreeDuplicates строит хэши директорий снизу вверх (имена и хэши детей по порядку) и
+// ищет директории с равными хэшами. Вернутся только верхние совпадения и группы, не
+// поглощенные ими. files - все файлы обхода: файл без копий в группы не попал, и дерево с ним
+// не может быть копией другого.
+func findTreeDuplicates(groups [][]FileInfo, files []FileInfo) ([]TreeDuplicate, [][]FileInfo) {
+	// Хэш файла для дерева - номер его группы: файлы одной группы одинаковы
+	fileIDs := make(map[string]string)
+	for i, group := range groups {
+		for _, f := range group {
+			fileIDs[filepath.Clean(f.Path)] = strconv.Itoa(i)
+		}
+	}
+
+	dirs := make(map[string]*dirTree)
+	treeOf := func(dir string) *dirTree {
+		t, ok := dirs[dir]
+		if !ok {
+			t = &dirTree{entries: make(map[treeEntry]bool)}
+			dirs[dir] = t
+		}
+		return t
+	}
+	for _, f := range files {
+		root := filepath.Clean(f.Root)
+		dir := parentDir(f)
+		treeOf(dir).entries[treeEntry{name: filepath.Base(f.Path)}] = true
+		// Объем и число файлов копятся во всех предках до корня сканирования
+		for {
+			t := treeOf(dir)
+			t.files++
+			t.size += f.Size
+			parent := filepath.Dir(dir)
+			if dir == root || parent == dir || !isWithin(root, dir) {
+				break
+			}
+			treeOf(parent).entries[treeEntry{name: filepath.Base(dir), dir: true}] = true
+			dir = parent
+		}
+	}
+
+	// Снизу вверх: более длинный путь не может быть предком более короткого
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+	byHash := make(map[string][]string)
+	for _, dir := range paths {
+		t := dirs[dir]
+		t.hash = treeHash(dir, t, dirs, fileIDs)
+		if t.hash != "" {
+			byHash[t.hash] = append(byHash[t.hash], dir)
+		}
+	}
+
+	// Совпадение вложено, если родители всех его папок тоже одно совпадение
+	var result []TreeDuplicate
+	for _, same := range byHash {
+		if len(same) < 2 {
+			continue
+		}
+		parentHash := dirs[filepath.Dir(same[0])].hashOrEmpty()
+		nested := parentHash != ""
+		for _, dir := range same[1:] {
+			if dirs[filepath.Dir(dir)].hashOrEmpty() != parentHash {
+				nested = false
+			}
+		}
+		if nested && len(byHash[parentHash]) == len(same) {
+			continue
+		}
+		sort.Strings(same)
+		t := dirs[same[0]]
+		result = append(result, TreeDuplicate{Dirs: same, Files: t.files, Size: t.size})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Dirs[0] < result[j].Dirs[0] })
+
+	// Группа поглощена совпадением, если все ее файлы лежат в его папках
+	owner := make(map[string]int) // Папка совпадения -> номер в result
+	for i, d := range result {
+		for _, dir := range d.Dirs {
+			owner[dir] = i
+		}
+	}
+	var rest [][]FileInfo
+	for _, group := range groups {
+		match := -1
+		for _, f := range group {
+			i, ok := treeOwner(parentDir(f), f.Root, owner)
+			if !ok || (match >= 0 && i != match) {
+				match = -1
+				break
+			}
+			match = i
+		}
+		if match < 0 {
+			rest = append(rest, group)
+			continue
+		}
+		result[match].Groups = append(result[match].Groups, group)
+	}
+	return result, rest
+}
+
+// hashOrEmpty возвращает хэш директории; у директории вне обхода (выше корня) его нет
+func (t *dirTree) hashOrEmpty() string {
+	if t == nil {
+		return ""
+	}
+	return t.hash
+}
+
+// treeHash считает хэш директории по отсортированным именам детей и их хэшам.
+// Пустая строка - в дереве есть файл без копий: такое дерево ничему не равно.
+func treeHash(dir string, t *dirTree, dirs map[string]*dirTree, fileIDs map[string]string) string {
+	entries := make([]treeEntry, 0, len(t.entries))
+	for e := range t.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		return !entries[i].dir
+	})
+
+	var b strings.Builder
+	for _, e := range entries {
+		var child string
+		if e.dir {
+			child = dirs[filepath.Join(dir, e.name)].hashOrEmpty()
+			b.WriteString("d ")
+		} else {
+			child = fileIDs[filepath.Join(dir, e.name)]
+			b.WriteString("f ")
+		}
+		if child == "" {
+			return ""
+		}
+		b.WriteString(e.name)
+		b.WriteByte(0)
+		b.WriteString(child)
+		b.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// treeOwner ищет среди dir и ее предков (до корня сканирования) папку совпадения
+func treeOwner(dir, root string, owner map[string]int) (int, bool) {
+	root = filepath.Clean(root)
+	for {
+		if i, ok := owner[dir]; ok {
+			return i, true
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir {
+			return 0, false
+		}
+		dir = parent
+	}
+}
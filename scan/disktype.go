@@ -0,0 +1,85 @@
+// Тип накопителя и ограничение одновременных чтений при хэшировании
+package scan
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Поддерживаемые значения Config.DiskType
+const (
+	DiskSSD  = "ssd"  // Чтения не ограничиваются, параллелизм задает Workers
+	DiskHDD  = "hdd"  // Чтения идут строго по одному
+	DiskAuto = "auto" // Тип определяется по директории сканирования
+)
+
+// hddMaxReaders - сколько файлов читается одновременно на HDD.
+//
+// Почему так мало: у шпинделя одна головка. Несколько параллельных потоков чтения
+// заставляют ее прыгать между файлами, и каждое позиционирование стоит ~10 мс.
+// В итоге 8 читателей на HDD дают суммарно в разы меньше МБ/с, чем один
+// последовательный поток. На SSD позиционирования нет, и параллельные запросы
+// наоборот лучше загружают очередь устройства.
+// Воркеры при этом не ограничиваются: хэш считается на CPU уже после чтения.
+const hddMaxReaders = 1
+
+// Число воркеров при Config.Workers = 0 (см. autoWorkers)
+const (
+	// На HDD чтения и так идут по одному (hddMaxReaders), лишние воркеры только ждали бы своей очереди
+	hddWorkers = hddMaxReaders
+	// По сети воркер больше ждет ответа, чем считает: на ядро их можно держать несколько
+	remoteWorkersPerCPU = 4
+	// Больше одновременных запросов сервер S3 или SSH обычно не принимает без замедления
+	maxRemoteWorkers = 32
+)
+
+// autoWorkers подбирает число воркеров по накопителю корней (для Config.Workers = 0);
+// второе значение - чем выбор объясняется, для журнала.
+//
+//   - S3 и SFTP: по remoteWorkersPerCPU на ядро, не больше maxRemoteWorkers - задержка сети
+//     скрывается параллельными запросами;
+//   - HDD (DiskType hdd или auto с вращающимся диском хотя бы у одного корня): hddWorkers;
+//   - SSD и неизвестный тип: по воркеру на ядро - хэширование упирается в CPU.
+func autoWorkers(cfg Config, roots []string) (int, string) {
+	cpus := runtime.NumCPU()
+	_, s3 := cfg.FS.(*S3FS)
+	if s3 || slices.ContainsFunc(roots, func(root string) bool { return strings.HasPrefix(root, sftpScheme) }) {
+		return min(cpus*remoteWorkersPerCPU, maxRemoteWorkers), "сетевое хранилище"
+	}
+	diskRoots := roots
+	if cfg.FS != nil { // Пути внутри fs.FS - не пути ОС
+		diskRoots = nil
+	}
+	if readLimit(cfg.DiskType, diskRoots) > 0 {
+		return hddWorkers, "HDD: чтение по одному файлу"
+	}
+	return cpus, "SSD или тип диска неизвестен: по воркеру на ядро"
+}
+
+// validateDiskType проверяет значение Config.DiskType
+func validateDiskType(diskType string) error {
+	switch diskType {
+	case "", DiskSSD, DiskHDD, DiskAuto: // Пустое значение равносильно ssd
+		return nil
+	}
+	return fmt.Errorf("неизвестный тип диска %q (допустимо: %s, %s, %s)", diskType, DiskSSD, DiskHDD, DiskAuto)
+}
+
+// readLimit возвращает максимальное число одновременных чтений (0 - без ограничения).
+// В режиме auto достаточно одного корня на HDD, чтобы ограничить чтение.
+func readLimit(diskType string, roots []string) int {
+	if diskType == DiskAuto {
+		for _, root := range roots {
+			if rotational, ok := isRotational(root); ok && rotational {
+				diskType = DiskHDD
+				break
+			}
+		}
+	}
+	if diskType == DiskHDD {
+		return hddMaxReaders
+	}
+	return 0
+}
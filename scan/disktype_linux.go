@@ -0,0 +1,32 @@
+// Определение типа накопителя на Linux через sysfs
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// isRotational проверяет флаг queue/rotational блочного устройства, на котором лежит path.
+// Второе значение false, если устройство определить не удалось (tmpfs, NFS, контейнеры).
+func isRotational(path string) (rotational bool, ok bool) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return false, false
+	}
+	dev := fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(st.Dev), unix.Minor(st.Dev))
+	// Для раздела queue/ лежит у родительского диска
+	for _, candidate := range []string{
+		filepath.Join(dev, "queue", "rotational"),
+		filepath.Join(dev, "..", "queue", "rotational"),
+	} {
+		data, err := os.ReadFile(candidate)
+		if err == nil {
+			return strings.TrimSpace(string(data)) == "1", true
+		}
+	}
+	return false, false
+}
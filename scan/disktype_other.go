@@ -0,0 +1,9 @@
+//go:build !linux
+
+// На остальных ОС тип накопителя не определяется
+package scan
+
+// isRotational всегда сообщает, что тип неизвестен: такой диск считается SSD
+func isRotational(path string) (rotational bool, ok bool) {
+	return false, false
+}
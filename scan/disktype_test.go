@@ -0,0 +1,39 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// BenchmarkReaders сравнивает хэширование с одним читателем (DiskType hdd) и с восемью
+// (DiskType ssd) при восьми воркерах. Разница видна только на настоящем диске с холодным
+// кэшем, поэтому бенчмарк читает дерево из DUPLIFINDER_BENCH_DIR (папка на HDD с файлами
+// одного размера, всего больше ОЗУ или со сбросом кэша перед запуском):
+//
+//	DUPLIFINDER_BENCH_DIR=/mnt/hdd/photos go test ./scan -run '^$' -bench Readers -benchtime 1x
+func BenchmarkReaders(b *testing.B) {
+	dir := os.Getenv("DUPLIFINDER_BENCH_DIR")
+	if dir == "" {
+		b.Skip("DUPLIFINDER_BENCH_DIR не задан: нужна папка на HDD")
+	}
+	for _, bench := range []struct {
+		readers  int
+		diskType string
+	}{{1, DiskHDD}, {8, DiskSSD}} {
+		b.Run(fmt.Sprintf("readers=%d", bench.readers), func(b *testing.B) {
+			for b.Loop() {
+				s := newTestScanner(b, dir, func(cfg *Config) {
+					cfg.Workers = 8
+					cfg.DiskType = bench.diskType
+				})
+				result, err := s.Run(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(result.Stats.TotalCandidateBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+// Package scan ищет дубликаты файлов: обход корней, грубая группировка по размеру или имени
+// и конкурентное хэширование только тех файлов, у которых есть кандидаты в пару.
+//
+// Пример:
+//
+//	scanner, err := scan.NewScanner(scan.Config{DirPath: "/data", Mode: scan.ModeHash, Workers: 8})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := scanner.Run(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	for _, group := range result.Groups {
+//		fmt.Println(len(group), "копий по", group[0].Size, "bytes:", group[0].Path)
+//	}
+//	fmt.Println("можно освободить", result.Groups.TotalWasted(), "bytes")
+//
+// Пакет ничего не печатает и не завершает программу: ошибки возвращаются, а журнал
+// (Config.LogLevel или WithLogger) пишется только в stderr.
+package scan
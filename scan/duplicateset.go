@@ -0,0 +1,56 @@
+// DuplicateSet: группы дубликатов с методами для подсчетов и отбора
+package scan
+
+// DuplicateSet - группы дубликатов (Result.Groups). Это тот же [][]FileInfo,
+// так что его можно передать туда, где ждут срез групп, или взять Raw.
+type DuplicateSet [][]FileInfo
+
+// Raw возвращает группы как обычный срез
+func (d DuplicateSet) Raw() [][]FileInfo {
+	return d
+}
+
+// GroupCount возвращает число групп
+func (d DuplicateSet) GroupCount() int {
+	return len(d)
+}
+
+// FileCount возвращает число файлов во всех группах
+func (d DuplicateSet) FileCount() int {
+	n := 0
+	for _, group := range d {
+		n += len(group)
+	}
+	return n
+}
+
+// TotalWasted возвращает объем, который освободится, если в каждой группе оставить один файл (см. Reclaimable)
+func (d DuplicateSet) TotalWasted() int64 {
+	var total int64
+	for _, group := range d {
+		total += Reclaimable(group)
+	}
+	return total
+}
+
+// LargestGroup возвращает группу с наибольшим числом файлов (при равенстве - первую), nil - групп нет
+func (d DuplicateSet) LargestGroup() []FileInfo {
+	var largest []FileInfo
+	for _, group := range d {
+		if len(group) > len(largest) {
+			largest = group
+		}
+	}
+	return largest
+}
+
+// Filter возвращает группы, для которых keep вернула true. Сами группы не копируются.
+func (d DuplicateSet) Filter(keep func(group []FileInfo) bool) DuplicateSet {
+	var result DuplicateSet
+	for _, group := range d {
+		if keep(group) {
+			result = append(result, group)
+		}
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDuplicateSet(t *testing.T) {
+	file := func(path string, size int64) FileInfo {
+		return FileInfo{Path: path, Size: size}
+	}
+	set := DuplicateSet{
+		{file("a/photo.jpg", 100), file("b/photo.jpg", 100)},
+		{file("a/notes.txt", 10), file("b/notes.txt", 10), file("c/notes.txt", 10)},
+		{file("a/video.mp4", 1000), file("b/video.mp4", 1000)},
+	}
+	if got := set.GroupCount(); got != 3 {
+		t.Errorf("GroupCount = %d, want 3", got)
+	}
+	if got := set.FileCount(); got != 7 {
+		t.Errorf("FileCount = %d, want 7", got)
+	}
+	if got := set.TotalWasted(); got != 100+2*10+1000 {
+		t.Errorf("TotalWasted = %d, want %d", got, 100+2*10+1000)
+	}
+	if got := set.LargestGroup(); len(got) != 3 || got[0].Path != "a/notes.txt" {
+		t.Errorf("LargestGroup = %v, want группа notes.txt", got)
+	}
+	if raw := set.Raw(); len(raw) != 3 || &raw[0][0] != &set[0][0] {
+		t.Error("Raw вернул не те же группы")
+	}
+
+	tests := []struct {
+		name string
+		keep func(group []FileInfo) bool
+		want []string // Первые пути оставшихся групп
+	}{
+		{"все", func([]FileInfo) bool { return true }, []string{"a/photo.jpg", "a/notes.txt", "a/video.mp4"}},
+		{"ни одной", func([]FileInfo) bool { return false }, nil},
+		{"крупнее 50 байт", func(g []FileInfo) bool { return g[0].Size > 50 }, []string{"a/photo.jpg", "a/video.mp4"}},
+		{"больше двух файлов", func(g []FileInfo) bool { return len(g) > 2 }, []string{"a/notes.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, group := range set.Filter(tt.keep) {
+				got = append(got, group[0].Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Filter: %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if set.GroupCount() != 3 {
+		t.Error("Filter изменил исходный набор")
+	}
+
+	var empty DuplicateSet
+	if empty.LargestGroup() != nil || empty.TotalWasted() != 0 || empty.FileCount() != 0 {
+		t.Error("пустой набор: want nil и нули")
+	}
+}
@@ -0,0 +1,93 @@
+package scan_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+// exampleDir создает папку с двумя одинаковыми файлами и одним отличающимся
+func exampleDir() string {
+	dir, err := os.MkdirTemp("", "duplifinder-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"photo.jpg":      "одинаковое содержимое",
+		"copy/photo.jpg": "одинаковое содержимое",
+		"notes.txt":      "другое",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// relPaths - пути группы относительно dir
+func relPaths(dir string, group []scan.FileInfo) []string {
+	var paths []string
+	for _, f := range group {
+		rel, _ := filepath.Rel(dir, f.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths
+}
+
+func ExampleNewScanner() {
+	dir := exampleDir()
+	defer os.RemoveAll(dir)
+
+	cfg := scan.DefaultConfig(dir)
+	cfg.Mode = scan.ModeFastHash
+	cfg.Workers = 2
+	scanner, err := scan.NewScanner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(scanner.Workers())
+	// Output: 2
+}
+
+func ExampleScanner_Run() {
+	dir := exampleDir()
+	defer os.RemoveAll(dir)
+
+	scanner, err := scan.NewScanner(scan.DefaultConfig(dir))
+	if err != nil {
+		log.Fatal(err)
+	}
+	result, err := scanner.Run(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, group := range result.Groups {
+		fmt.Println(relPaths(dir, group))
+	}
+	fmt.Println("файлов:", result.Stats.TotalFiles, "можно освободить:", result.Groups.TotalWasted(), "bytes")
+	// Output:
+	// [copy/photo.jpg photo.jpg]
+	// файлов: 3 можно освободить: 41 bytes
+}
+
+func ExampleQuickScan() {
+	dir := exampleDir()
+	defer os.RemoveAll(dir)
+
+	groups, err := scan.QuickScan(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, group := range groups {
+		fmt.Println(len(group), "копии:", relPaths(dir, group))
+	}
+	// Output: 2 копии: [copy/photo.jpg photo.jpg]
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+// На остальных ОС устройство и inode неизвестны
+package scan
+
+import "io/fs"
+
+// fileID всегда возвращает нули: идентификатор файла на этой ОС не поддерживается
+func fileID(string, fs.FileInfo) (dev, inode uint64) {
+	return 0, 0
+}
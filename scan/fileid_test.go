@@ -0,0 +1,36 @@
+//go:build unix || windows
+
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileID(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a": "same", "b": "same"})
+	a, b, link := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "link")
+	if err := os.Link(a, link); err != nil {
+		t.Skipf("жесткие ссылки недоступны: %v", err)
+	}
+	id := func(path string) [2]uint64 {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dev, inode := fileID(path, info)
+		return [2]uint64{dev, inode}
+	}
+
+	if got := id(a); got[1] == 0 {
+		t.Errorf("fileID(a) = %v, want ненулевой inode", got)
+	}
+	if id(a) != id(link) {
+		t.Errorf("у жесткой ссылки %v, у файла %v, want одинаковые", id(link), id(a))
+	}
+	if id(a) == id(b) {
+		t.Errorf("у разных файлов одинаковый id %v", id(a))
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+// Устройство и inode файла на unix-системах: берутся из stat, уже полученного при обходе
+package scan
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID возвращает устройство и inode файла (нули, если fs.FileInfo не от ОС)
+func fileID(_ string, info fs.FileInfo) (dev, inode uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(st.Dev), uint64(st.Ino)
+}
@@ -0,0 +1,106 @@
+// Список файлов вместо обхода: пути из stdin или файла (вывод find, выборка из базы)
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// FilesFromStdin - корень (Config.DirPath или единственный элемент Config.Roots), означающий
+// список файлов из stdin: то же, что Config.FilesFrom = os.Stdin
+const FilesFromStdin = "-"
+
+// filesFromPeek - сколько байт начала списка смотрит readFileList, выбирая разделитель
+const filesFromPeek = 64 << 10
+
+// filesFromRoots проверяет корни при Config.FilesFrom: список заменяет обход,
+// так что других корней быть не должно
+func filesFromRoots(cfg Config) error {
+	if cfg.FS != nil {
+		return errors.New("FilesFrom - пути ОС, с FS он несовместим")
+	}
+	for _, root := range cfg.RootList() {
+		if root != "" && root != FilesFromStdin {
+			return errors.New("FilesFrom заменяет обход корней: корни, кроме \"-\", задавать не нужно")
+		}
+	}
+	return nil
+}
+
+// readFileList читает пути из Config.FilesFrom и дописывает файлы в files, как их дописал бы
+// обход. Пути разделяются NUL (find -print0), если он есть в начале списка, иначе переводом
+// строки; пустые строки пропускаются. Путь, которого нет или который не прочитать, - ошибка
+// в Stats.Errors, а не остановка сканирования; папки в списке пропускаются.
+func (s *Scanner) readFileList(files []FileInfo) ([]FileInfo, error) {
+	root := s.roots[0]
+	r := bufio.NewReaderSize(s.config.FilesFrom, filesFromPeek)
+	head, _ := r.Peek(filesFromPeek)
+	delim := byte('\n')
+	if bytes.IndexByte(head, 0) >= 0 {
+		delim = 0
+	}
+	s.logger.Debug("чтение списка файлов", "nul", delim == 0)
+
+	for !s.Interrupted() {
+		line, err := r.ReadString(delim)
+		path := strings.TrimSuffix(line, string(delim))
+		if delim == '\n' {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			s.waitIfPaused()
+			files = s.addListedFile(files, root, filepath.Clean(path))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, err
+		}
+	}
+	s.logger.Debug("список файлов прочитан", "files", atomic.LoadInt64(&s.rootFiles[0]))
+	return files, nil
+}
+
+// addListedFile дописывает в files файл path из списка
+func (s *Scanner) addListedFile(files []FileInfo, root, path string) []FileInfo {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		s.recordError(path, OpStat, err)
+		s.logger.Debug("путь пропущен", "path", path, "reason", "нет информации о файле", "err", err)
+		return files
+	case info.IsDir():
+		s.logger.Debug("путь пропущен", "path", path, "reason", "папка в списке файлов")
+		return files
+	case s.config.FileFilter != nil && !s.config.FileFilter(path, info):
+		s.logger.Debug("путь пропущен", "path", path, "reason", "FileFilter")
+		return files
+	case !s.ownedBy(info):
+		atomic.AddInt64(&s.stats.OtherOwnerFiles, 1)
+		s.logger.Debug("путь пропущен", "path", path, "reason", "другой владелец")
+		return files
+	}
+	f := FileInfo{
+		Path:    path,
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+		Root:    root,
+	}
+	f.Dev, f.Inode = fileID(path, info)
+	files = append(files, f)
+	atomic.AddInt64(&s.stats.TotalFiles, 1)
+	atomic.AddInt64(&s.rootFiles[0], 1)
+	if s.config.ScanArchives && archiveKind(f.Name) != "" {
+		files = s.walkArchive(files, 0, root, path)
+	}
+	return files
+}
@@ -0,0 +1,80 @@
+// Абстракция файловой системы: обход и чтение через fs.FS вместо прямых вызовов os
+package scan
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// walkDir обходит корень: в Config.FS, если он задан, иначе в файловой системе ОС.
+// Для ОС остается filepath.WalkDir, чтобы пути в результате были в привычном виде
+// (абсолютные, с родным разделителем) и с ними работали действия над файлами.
+// Обход идет по длинной форме корня (см. LongPath), а в fn пути приходят обычными.
+func (s *Scanner) walkDir(root string, fn fs.WalkDirFunc) error {
+	if fsys, dir, ok := s.remoteFS(root); ok {
+		return s.walkRemote(fsys, root, dir, fn)
+	}
+	if s.config.FS != nil {
+		return fs.WalkDir(s.config.FS, root, fn)
+	}
+	long := LongPath(root)
+	if long == root {
+		return filepath.WalkDir(root, fn)
+	}
+	return filepath.WalkDir(long, func(path string, d fs.DirEntry, err error) error {
+		return fn(root+strings.TrimPrefix(path, long), d, err)
+	})
+}
+
+// openFile открывает найденный при обходе файл для чтения (при ScanArchives - и запись архива)
+func (s *Scanner) openFile(name string) (fs.File, error) {
+	if s.config.FS != nil {
+		return s.config.FS.Open(name)
+	}
+	if fsys, rel, ok := s.remoteFS(name); ok {
+		return fsys.Open(rel)
+	}
+	if s.config.ScanArchives && strings.Contains(name, archiveSep) {
+		if _, _, ok := splitArchivePath(name); ok {
+			return openArchiveEntry(name)
+		}
+	}
+	return os.Open(LongPath(name))
+}
+
+// normalizeFSRoots - аналог normalizeRoots для путей внутри fs.FS:
+// пути там всегда относительные, через "/", а "." означает весь FS.
+func normalizeFSRoots(roots []string) []string {
+	cleaned := make([]string, len(roots))
+	for i, root := range roots {
+		cleaned[i] = path.Clean(root)
+		if root == "" {
+			cleaned[i] = "."
+		}
+	}
+
+	var result []string
+	for i, root := range cleaned {
+		covered := false
+		for j, other := range cleaned {
+			if i == j {
+				continue
+			}
+			if root == other {
+				covered = j < i
+			} else {
+				covered = other == "." || strings.HasPrefix(root, other+"/")
+			}
+			if covered {
+				break
+			}
+		}
+		if !covered {
+			result = append(result, root)
+		}
+	}
+	return result
+}
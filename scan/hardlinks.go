@@ -0,0 +1,100 @@
+// Жесткие ссылки: несколько путей к одному файлу на диске
+package scan
+
+import "slices"
+
+// fileKey - устройство и inode файла (на Windows - серийный номер тома и индекс файла)
+type fileKey struct {
+	dev, inode uint64
+}
+
+// identity возвращает ключ файла на диске; false - неизвестен (fs.FS, манифест, ошибка)
+func (f FileInfo) identity() (fileKey, bool) {
+	return fileKey{f.Dev, f.Inode}, f.Inode != 0
+}
+
+// findHardlinks собирает пути, ведущие к одному файлу (не меньше двух путей на файл).
+// Пути внутри каждой группы упорядочены, первый из них - представитель файла.
+func findHardlinks(files []FileInfo) [][]FileInfo {
+	byKey := make(map[fileKey][]FileInfo)
+	for _, f := range files {
+		if key, ok := f.identity(); ok {
+			byKey[key] = append(byKey[key], f)
+		}
+	}
+	var clusters [][]FileInfo
+	for _, paths := range byKey {
+		if len(paths) > 1 {
+			clusters = append(clusters, paths)
+		}
+	}
+	sortCanonical(clusters)
+	return clusters
+}
+
+// collapseHardlinks оставляет от каждой группы жестких ссылок только представителя:
+// удаление одного из путей не освобождает места, и "дубликатами" они не являются
+func collapseHardlinks(files []FileInfo, clusters [][]FileInfo) []FileInfo {
+	if len(clusters) == 0 {
+		return files
+	}
+	skip := make(map[string]bool)
+	for _, cluster := range clusters {
+		for _, f := range cluster[1:] {
+			skip[f.Path] = true
+		}
+	}
+	kept := make([]FileInfo, 0, len(files)-len(skip))
+	for _, f := range files {
+		if _, ok := f.identity(); ok && skip[f.Path] {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// annotateHardlinks (Config.AnnotateHardlinks) убирает группы, все пути которых ведут к одному
+// файлу на диске: освобождать в них нечего. В остальных у каждой жесткой ссылки на уже
+// встреченный в группе файл заполняется HardlinkOf - первый путь к этому файлу.
+func annotateHardlinks(groups [][]FileInfo) [][]FileInfo {
+	return slices.DeleteFunc(groups, func(group []FileInfo) bool {
+		first := make(map[fileKey]string, len(group))
+		for i := range group {
+			key, ok := group[i].identity()
+			if !ok {
+				continue
+			}
+			if path, seen := first[key]; seen {
+				group[i].HardlinkOf = path
+			} else {
+				first[key] = group[i].Path
+			}
+		}
+		return distinctFiles(group) < 2
+	})
+}
+
+// distinctFiles считает разные файлы на диске среди путей группы:
+// жесткие ссылки на один inode - один файл, файлы с неизвестным inode - каждый отдельно
+func distinctFiles(group []FileInfo) int {
+	seen := make(map[fileKey]bool, len(group))
+	n := 0
+	for _, f := range group {
+		if key, ok := f.identity(); ok {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		n++
+	}
+	return n
+}
+
+// Hardlinks возвращает группы путей к одному и тому же файлу, найденные последним запуском.
+// При Config.IgnoreHardlinks в группы дубликатов из каждой попадает только первый путь,
+// при Config.AnnotateHardlinks - все пути с пометкой FileInfo.HardlinkOf.
+func (s *Scanner) Hardlinks() [][]FileInfo {
+	return s.hardlinks
+}
@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// BenchmarkHashOverlap показывает выигрыш от того, что produce выдает задачи, пока воркеры
+// уже хэшируют. Подготовка каждой задачи (здесь - os.Stat, как при перечислении файлов)
+// в streamed идет вместе с хэшированием, а в serial - вся заранее, как было до отдельного
+// производителя: сначала полный список, потом раздача.
+func BenchmarkHashOverlap(b *testing.B) {
+	dir := b.TempDir()
+	const files = 256
+	content := strings.Repeat("x", 64<<10)
+	paths := make([]string, files)
+	var total int64
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%03d", i))
+		if err := os.WriteFile(paths[i], []byte(content), 0o644); err != nil {
+			b.Fatal(err)
+		}
+		total += int64(len(content))
+	}
+	prepare := func(path string) hashJob {
+		info, err := os.Stat(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return hashJob{path: path, size: info.Size()}
+	}
+
+	for _, bench := range []struct {
+		name    string
+		produce func(send func(hashJob) bool)
+	}{
+		{"streamed", func(send func(hashJob) bool) {
+			for _, path := range paths {
+				if !send(prepare(path)) {
+					return
+				}
+			}
+		}},
+		{"serial", func(send func(hashJob) bool) {
+			jobs := make([]hashJob, 0, len(paths))
+			for _, path := range paths {
+				jobs = append(jobs, prepare(path))
+			}
+			for _, job := range jobs {
+				if !send(job) {
+					return
+				}
+			}
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			s := newTestScanner(b, dir, func(cfg *Config) { cfg.Workers = 4 })
+			b.SetBytes(total)
+			for b.Loop() {
+				hashed := 0
+				s.hashFiles(total, bench.produce, s.contentRead(), func(r hashResult) {
+					if r.err != nil {
+						b.Fatal(r.err)
+					}
+					hashed++
+				})
+				if hashed != files {
+					b.Fatalf("посчитано %d хэшей, want %d", hashed, files)
+				}
+			}
+		})
+	}
+}
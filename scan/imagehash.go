@@ -0,0 +1,166 @@
+// Перцептивный хэш изображений (режим image): похожие, а не только одинаковые картинки
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Декодеры форматов, которые понимает режим image
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"math/bits"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// DefaultImageDistance - порог расстояния Хэмминга между хэшами изображений в DefaultConfig
+const DefaultImageDistance = 5
+
+// imageExts - расширения файлов, которые режим image декодирует; остальные файлы он пропускает
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+// isImage сообщает, похоже ли имя файла на изображение поддерживаемого формата
+func isImage(name string) bool {
+	return imageExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// dHash: картинка уменьшается до (dHashSize+1) x dHashSize в оттенках серого,
+// и каждый бит - ярче ли пиксель своего соседа справа. 8x8 = 64 бита.
+const (
+	dHashSize    = 8
+	dHashSamples = 4 // Точек на ячейку по каждой оси при уменьшении
+)
+
+// errImageDecode - файл с расширением изображения не удалось декодировать
+var errImageDecode = errors.New("не удалось декодировать изображение")
+
+// imageHash декодирует изображение и возвращает его dHash в виде 16 hex-цифр.
+// Пересохраненная, уменьшенная или пережатая копия дает хэш на малом расстоянии Хэмминга.
+// Ошибка декодирования (и паника декодера на поврежденном файле) - ошибка чтения файла.
+func imageHash(ctx context.Context, file fs.File) (hash string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errImageDecode, r)
+		}
+	}()
+	img, _, err := image.Decode(ctxReader{ctx: ctx, r: file})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("%w: %v", errImageDecode, err)
+	}
+	return fmt.Sprintf("%016x", dHash(img)), nil
+}
+
+// dHash считает разностный хэш изображения
+func dHash(img image.Image) uint64 {
+	var gray [dHashSize][dHashSize + 1]uint32
+	b := img.Bounds()
+	w, h := dHashSize+1, dHashSize
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Средняя яркость ячейки по нескольким точкам: дешевле, чем по всем пикселям большого фото
+			var sum, n uint32
+			for sy := 0; sy < dHashSamples; sy++ {
+				for sx := 0; sx < dHashSamples; sx++ {
+					px := b.Min.X + (x*dHashSamples+sx)*b.Dx()/(w*dHashSamples)
+					py := b.Min.Y + (y*dHashSamples+sy)*b.Dy()/(h*dHashSamples)
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += (19595*r + 38470*g + 7471*bl + 1<<15) >> 16
+					n++
+				}
+			}
+			gray[y][x] = sum / n
+		}
+	}
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < dHashSize; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// bkNode - узел BK-дерева: потомки разложены по расстоянию до хэша узла
+type bkNode struct {
+	hash     uint64
+	index    int
+	children map[int]*bkNode
+}
+
+// add вставляет хэш в дерево с корнем n
+func (n *bkNode) add(hash uint64, index int) {
+	for {
+		d := bits.OnesCount64(n.hash ^ hash)
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[int]*bkNode)
+			}
+			n.children[d] = &bkNode{hash: hash, index: index}
+			return
+		}
+		n = child
+	}
+}
+
+// near вызывает found для всех хэшей дерева на расстоянии не больше maxDist.
+// По неравенству треугольника обходятся только потомки на расстоянии d±maxDist.
+func (n *bkNode) near(hash uint64, maxDist int, found func(index int)) {
+	d := bits.OnesCount64(n.hash ^ hash)
+	if d <= maxDist {
+		found(n.index)
+	}
+	for cd, child := range n.children {
+		if cd >= d-maxDist && cd <= d+maxDist {
+			child.near(hash, maxDist, found)
+		}
+	}
+}
+
+// clusterImages делит файлы с посчитанными перцептивными хэшами на группы похожих:
+// файлы на расстоянии не больше maxDist связаны, группа - связная компонента
+// (так "A похожа на B, B похожа на C" дает одну группу A, B, C).
+func clusterImages(files []FileInfo, maxDist int) map[string][]FileInfo {
+	hashes := make([]uint64, len(files))
+	parent := make([]int, len(files))
+	var find func(i int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	var root *bkNode
+	for i, f := range files {
+		parent[i] = i
+		hashes[i], _ = strconv.ParseUint(f.Hash, 16, 64)
+		if root == nil {
+			root = &bkNode{hash: hashes[i], index: i}
+			continue
+		}
+		root.near(hashes[i], maxDist, func(j int) {
+			parent[find(j)] = find(i)
+		})
+		root.add(hashes[i], i)
+	}
+
+	groups := make(map[string][]FileInfo)
+	for i, f := range files {
+		key := files[find(i)].Path // Путь представителя компоненты - ключ группы
+		groups[key] = append(groups[key], f)
+	}
+	return groups
+}
@@ -0,0 +1,17 @@
+// Поиск группы по пути файла
+package scan
+
+// IndexByPath возвращает для каждого пути номер его группы (индекс в groups),
+// чтобы по пути сразу находить группу, например для подсветки в интерфейсе.
+// Путь не должен встречаться в двух группах; если это все же случилось, побеждает первая группа.
+func IndexByPath(groups [][]FileInfo) map[string]int {
+	index := make(map[string]int)
+	for i, group := range groups {
+		for _, f := range group {
+			if _, ok := index[f.Path]; !ok {
+				index[f.Path] = i
+			}
+		}
+	}
+	return index
+}
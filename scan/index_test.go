@@ -0,0 +1,25 @@
+package scan
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestIndexByPath(t *testing.T) {
+	groups := [][]FileInfo{
+		{{Path: "/a/1.jpg"}, {Path: "/b/1.jpg"}},
+		{{Path: "/a/2.txt"}, {Path: "/b/2.txt"}, {Path: "/a/1.jpg"}}, // Путь уже есть в первой группе
+	}
+	want := map[string]int{
+		"/a/1.jpg": 0,
+		"/b/1.jpg": 0,
+		"/a/2.txt": 1,
+		"/b/2.txt": 1,
+	}
+	if got := IndexByPath(groups); !maps.Equal(got, want) {
+		t.Errorf("IndexByPath = %v, want %v", got, want)
+	}
+	if got := IndexByPath(nil); len(got) != 0 {
+		t.Errorf("IndexByPath(nil) = %v, want пустой индекс", got)
+	}
+}
@@ -0,0 +1,124 @@
+// Потоковый вывод групп в JSON Lines: одна группа - одна строка; и загрузка их обратно
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// GroupRecord - группа дубликатов в выводе WriteJSONL
+type GroupRecord struct {
+	Group  int          `json:"group"`          // Номер группы с 1, в порядке поступления
+	Mode   Mode         `json:"mode,omitempty"` // Режим, которым найдена группа: по нему clean решает, можно ли удалять
+	Size   int64        `json:"size"`
+	Hash   string       `json:"hash,omitempty"`
+	Wasted int64        `json:"wasted"` // Сколько освободится, если оставить одну копию
+	Files  []FileRecord `json:"files"`
+}
+
+// FileRecord - файл группы в выводе WriteJSONL
+type FileRecord struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Root     string    `json:"root,omitempty"`
+	ReadOnly bool      `json:"read_only,omitempty"`   // FileInfo.ReadOnly: запись архива, объект S3 или удаленный файл
+	Hardlink string    `json:"hardlink_of,omitempty"` // FileInfo.HardlinkOf
+	Error    string    `json:"error,omitempty"`       // Ошибка чтения (FileInfo.Err)
+}
+
+// NewGroupRecord описывает группу для JSON; n - номер группы с 1
+func NewGroupRecord(n int, group []FileInfo) GroupRecord {
+	record := GroupRecord{
+		Group:  n,
+		Size:   group[0].Size,
+		Hash:   group[0].Hash,
+		Wasted: Reclaimable(group),
+		Files:  make([]FileRecord, len(group)),
+	}
+	for i, f := range group {
+		record.Files[i] = FileRecord{Path: f.Path, Size: f.Size, ModTime: f.ModTime, Root: f.Root, ReadOnly: f.ReadOnly, Hardlink: f.HardlinkOf}
+		if f.Err != nil {
+			record.Files[i].Error = f.Err.Error()
+		}
+	}
+	return record
+}
+
+// FileInfos восстанавливает файлы группы; хэш у всех - хэш группы
+func (r GroupRecord) FileInfos() []FileInfo {
+	files := make([]FileInfo, len(r.Files))
+	for i, f := range r.Files {
+		files[i] = FileInfo{
+			Path:     f.Path,
+			Name:     filepath.Base(f.Path),
+			Size:     f.Size,
+			ModTime:  f.ModTime,
+			Hash:     r.Hash,
+			Root:     f.Root,
+			ReadOnly: f.ReadOnly,
+
+			HardlinkOf: f.Hardlink,
+		}
+		if f.Error != "" {
+			files[i].Err = errors.New(f.Error)
+		}
+	}
+	return files
+}
+
+// ReadJSONL загружает группы, записанные WriteJSONL: файл результатов можно показать
+// или разобрать позже и на другой машине, не сканируя заново. Пустые группы пропускаются.
+// Режим - из первой группы (пустой, если его нет); группы разных режимов - ошибка.
+func ReadJSONL(r io.Reader) ([][]FileInfo, Mode, error) {
+	dec := json.NewDecoder(r)
+	var groups [][]FileInfo
+	var mode Mode
+	for n := 1; ; n++ {
+		var record GroupRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return groups, mode, nil
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("группа %d: %w", n, err)
+		}
+		if n == 1 {
+			mode = record.Mode
+		} else if record.Mode != mode {
+			return nil, "", fmt.Errorf("группа %d: режим %q, а у первой группы %q", n, record.Mode, mode)
+		}
+		if len(record.Files) > 0 {
+			groups = append(groups, record.FileInfos())
+		}
+	}
+}
+
+// WriteJSONL пишет группы по мере поступления из канала, по одному JSON-объекту на строку:
+// память не растет с числом групп, а каждую строку можно разбирать отдельно (grep, jq).
+// mode - режим, которым найдены группы, записывается в каждую строку.
+// Возвращается, когда канал закрыт, или при первой ошибке записи - тогда остаток канала
+// не вычитывается, и отправитель должен уметь остановиться сам (например, по отмене контекста).
+func WriteJSONL(w io.Writer, mode Mode, groups <-chan []FileInfo) error {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf) // Encode дописывает "\n" после каждого объекта
+	n := 0
+	for group := range groups {
+		n++
+		record := NewGroupRecord(n, group)
+		record.Mode = mode
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		// Строка уходит сразу: читатель на другом конце трубы видит группы по мере поиска
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
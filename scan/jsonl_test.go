@@ -0,0 +1,64 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONLStream(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := range 5 {
+		files[fmt.Sprintf("a/%d.txt", i)] = fmt.Sprintf("содержимое %d", i)
+		files[fmt.Sprintf("b/%d.txt", i)] = fmt.Sprintf("содержимое %d", i)
+	}
+	writeFiles(t, dir, files)
+
+	var buf bytes.Buffer
+	groups, errc := newTestScanner(t, dir, nil).Stream(context.Background())
+	if err := WriteJSONL(&buf, ModeHash, groups); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	// Строк столько же, сколько групп, и каждая разбирается отдельно
+	lines := 0
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		lines++
+		var record GroupRecord
+		if err := json.Unmarshal(sc.Bytes(), &record); err != nil {
+			t.Fatalf("строка %d: %v", lines, err)
+		}
+		if record.Group != lines || record.Mode != ModeHash || len(record.Files) != 2 {
+			t.Errorf("строка %d: группа %d, режим %q, файлов %d", lines, record.Group, record.Mode, len(record.Files))
+		}
+	}
+	if lines != 5 {
+		t.Errorf("строк %d, want 5", lines)
+	}
+
+	back, mode, err := ReadJSONL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 5 || mode != ModeHash {
+		t.Errorf("ReadJSONL: %d групп, режим %q, want 5 и %q", len(back), mode, ModeHash)
+	}
+}
+
+func TestReadJSONLMixedModes(t *testing.T) {
+	input := `{"group":1,"mode":"hash","size":1,"files":[{"path":"a"},{"path":"b"}]}
+{"group":2,"mode":"size_only","size":1,"files":[{"path":"c"},{"path":"d"}]}
+`
+	if _, _, err := ReadJSONL(strings.NewReader(input)); err == nil {
+		t.Error("ReadJSONL принял группы разных режимов")
+	}
+}
@@ -0,0 +1,219 @@
+// Режим compare: побайтовое сравнение файлов группы блоками вместо хэша
+package scan
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// compareBlockSize - сколько байт каждого файла группы читается за шаг сравнения
+const compareBlockSize = 64 << 10
+
+// compareOpenFiles - сколько файлов одной группы держится открытыми между шагами;
+// остальные открываются заново на каждый блок. Открытых файлов всего - не больше
+// Workers * compareOpenFiles, даже если в группе тысячи файлов одного размера.
+const compareOpenFiles = 32
+
+// compareMember - файл группы кандидатов во время сравнения
+type compareMember struct {
+	info   FileInfo
+	file   fs.File // Открыт между шагами (не больше compareOpenFiles файлов группы), иначе nil
+	offset int64   // Сколько байт уже сравнено
+}
+
+// compareGroups сравнивает файлы каждой группы кандидатов блок за блоком, а не хэшем:
+// все файлы группы читаются вместе и делятся, как только очередной блок у них различается.
+// Файл, отличный от всех, дальше не читается, так что разные файлы одного размера
+// обходятся первым блоком. Группы сравниваются параллельно, по группе на воркер.
+func (s *Scanner) compareGroups(groups [][]FileInfo, emit emitFunc) error {
+	var total int64
+	for _, group := range groups {
+		for _, f := range group {
+			total += f.Size
+		}
+	}
+	s.startHashPhase(total)
+
+	jobs := make(chan int, s.jobBufferSize())
+	results := make(chan [][]FileInfo, s.jobBufferSize())
+	var wg sync.WaitGroup
+	for w := 0; w < s.config.Workers; w++ {
+		wg.Add(1)
+		go func(current *atomic.Value) {
+			defer wg.Done()
+			for i := range jobs {
+				s.waitIfPaused()
+				if s.Interrupted() {
+					continue
+				}
+				// Группа, сравнение которой оборвала остановка, не отдается: копия могла остаться непроверенной
+				if same, ok := s.compareGroup(groups[i], current); ok {
+					results <- same
+				}
+			}
+		}(&s.current[w])
+	}
+	go func() {
+		defer close(jobs)
+		for i := range groups {
+			if s.Interrupted() {
+				return
+			}
+			jobs <- i
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for same := range results {
+		byKey := make(map[string][]FileInfo, len(same))
+		for i, group := range same {
+			byKey[strconv.Itoa(i)] = group
+		}
+		for _, group := range s.splitSingletons(byKey) {
+			emit(group)
+		}
+	}
+	return nil
+}
+
+// compareGroup делит группу на наборы файлов с одинаковым содержимым.
+// Файлы с ошибкой чтения в результат не попадают; false - сравнение прервано остановкой.
+func (s *Scanner) compareGroup(group []FileInfo, current *atomic.Value) ([][]FileInfo, bool) {
+	members := make([]*compareMember, len(group))
+	for i, f := range group {
+		members[i] = &compareMember{info: f}
+	}
+	open := 0
+	defer func() {
+		for _, m := range members {
+			if m.file != nil {
+				m.file.Close()
+			}
+		}
+	}()
+
+	var result [][]FileInfo
+	parts := [][]*compareMember{members}
+	for len(parts) > 0 {
+		s.waitIfPaused()
+		if s.Interrupted() {
+			return nil, false
+		}
+		var next [][]*compareMember
+		for _, part := range parts {
+			if part[0].offset >= part[0].info.Size { // Сравнено все: файлы одинаковы
+				same := make([]FileInfo, len(part))
+				for i, m := range part {
+					same[i] = m.info
+					s.compareDone(m, &open)
+				}
+				result = append(result, same)
+				continue
+			}
+			byBlock := make(map[string][]*compareMember)
+			var order []string // Порядок блоков, как в группе: результат не зависит от обхода карты
+			for _, m := range part {
+				current.Store(m.info.Path)
+				block, err := s.readBlock(m, &open)
+				current.Store("")
+				if err != nil {
+					if s.ctx.Err() != nil && errors.Is(err, s.ctx.Err()) {
+						return nil, false
+					}
+					m.info.Err = s.readFailed(m.info.Path, err)
+					s.compareDone(m, &open)
+					continue
+				}
+				key := string(block)
+				if _, ok := byBlock[key]; !ok {
+					order = append(order, key)
+				}
+				byBlock[key] = append(byBlock[key], m)
+			}
+			for _, key := range order {
+				if same := byBlock[key]; len(same) > 1 {
+					next = append(next, same)
+				} else { // Отличается от всех: дальше читать незачем
+					result = append(result, []FileInfo{same[0].info})
+					s.compareDone(same[0], &open)
+				}
+			}
+		}
+		parts = next
+	}
+	return result, true
+}
+
+// readBlock читает следующий блок файла. Пока открыто меньше compareOpenFiles файлов группы,
+// файл остается открытым до конца сравнения, иначе открывается только на время чтения блока.
+func (s *Scanner) readBlock(m *compareMember, open *int) ([]byte, error) {
+	file := m.file
+	if file == nil {
+		f, err := s.openFile(m.info.Path)
+		if err != nil {
+			return nil, &ScanError{Path: m.info.Path, Op: OpOpen, Err: err}
+		}
+		if info, err := f.Stat(); err == nil && info.Size() != m.info.Size {
+			f.Close()
+			return nil, &ScanError{Path: m.info.Path, Op: OpChanged, Err: ErrFileChanged}
+		}
+		if *open < compareOpenFiles {
+			m.file = f
+			*open++
+		} else {
+			defer f.Close()
+			if err := skipBytes(f, m.offset); err != nil {
+				return nil, err
+			}
+		}
+		file = f
+	}
+
+	block := make([]byte, min(compareBlockSize, m.info.Size-m.offset))
+	if _, err := io.ReadFull(ctxReader{ctx: s.ctx, r: s.throttle(s.ctx, file)}, block); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			// Файл стал короче, чем был при обходе
+			return nil, &ScanError{Path: m.info.Path, Op: OpChanged, Err: ErrFileChanged}
+		}
+		return nil, err
+	}
+	m.offset += int64(len(block))
+	s.progress.addBytes(int64(len(block)))
+	return block, nil
+}
+
+// compareDone закрывает файл, сравнение которого закончено (его место среди открытых
+// освобождается), и засчитывает в прогресс непрочитанный остаток: читать его уже не нужно
+func (s *Scanner) compareDone(m *compareMember, open *int) {
+	if m.file != nil {
+		m.file.Close()
+		m.file = nil
+		*open--
+	}
+	if rest := m.info.Size - m.offset; rest > 0 {
+		atomic.AddInt64(&s.progress.bytesHashed, rest) // Только прогресс: эти байты не читались
+		m.offset = m.info.Size
+	}
+	atomic.AddInt64(&s.progress.filesHashed, 1)
+}
+
+// skipBytes переходит к offset в только что открытом файле: через Seek, если файл умеет,
+// иначе дочитывая начало
+func skipBytes(file fs.File, offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+	if seeker, ok := file.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, file, offset)
+	return err
+}
@@ -0,0 +1,67 @@
+// Структурированный журнал сканера (log/slog): этапы, пропущенные пути, ошибки чтения
+package scan
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Форматы журнала (Config.LogFormat)
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// parseLogLevel переводит Config.LogLevel в уровень slog; пустая строка - журнал выключен
+func parseLogLevel(level string) (slog.Level, bool, error) {
+	switch level {
+	case "":
+		return 0, false, nil
+	case "error":
+		return slog.LevelError, true, nil
+	case "warn":
+		return slog.LevelWarn, true, nil
+	case "info":
+		return slog.LevelInfo, true, nil
+	case "debug":
+		return slog.LevelDebug, true, nil
+	}
+	return 0, false, fmt.Errorf("неизвестный уровень журнала %q (error, warn, info, debug)", level)
+}
+
+// validateLogConfig проверяет Config.LogLevel и Config.LogFormat
+func validateLogConfig(level, format string) error {
+	if _, _, err := parseLogLevel(level); err != nil {
+		return err
+	}
+	switch format {
+	case "", LogFormatText, LogFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("неизвестный формат журнала %q (text, json)", format)
+}
+
+// newLogger строит журнал по настройкам: Config.Logger, если задан, иначе по уровню в stderr,
+// чтобы не смешиваться с машиночитаемым выводом в stdout. Неверные настройки выключают
+// журнал (CLI проверяет их заранее через validateLogConfig).
+func newLogger(cfg Config) *slog.Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	level, enabled, err := parseLogLevel(cfg.LogLevel)
+	if err != nil || !enabled {
+		return slog.New(slog.DiscardHandler)
+	}
+	return slog.New(newLogHandler(os.Stderr, cfg.LogFormat, level))
+}
+
+// newLogHandler выбирает обработчик slog по формату
+func newLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
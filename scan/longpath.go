@@ -0,0 +1,20 @@
+// Расширенная форма путей Windows (\\?\), общая логика без обращений к ОС
+package scan
+
+import "strings"
+
+// extendedPath переводит абсолютный путь Windows в расширенную форму:
+// C:\dir\file -> \\?\C:\dir\file, сетевой \\server\share\file -> \\?\UNC\server\share\file.
+// Уже расширенные пути (\\?\, \\.\) и все, что не похоже на абсолютный путь, не меняются.
+// В расширенной форме Windows не разбирает путь: разделители должны быть \, без "." и "..".
+func extendedPath(abs string) string {
+	switch {
+	case strings.HasPrefix(abs, `\\?\`), strings.HasPrefix(abs, `\\.\`):
+		return abs
+	case strings.HasPrefix(abs, `\\`):
+		return `\\?\UNC\` + abs[2:]
+	case len(abs) >= 3 && abs[1] == ':' && abs[2] == '\\':
+		return `\\?\` + abs
+	}
+	return abs
+}
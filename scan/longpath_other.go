@@ -0,0 +1,9 @@
+//go:build !windows
+
+// Ограничение длины пути есть только в Windows
+package scan
+
+// LongPath возвращает путь без изменений: расширенная форма \\?\ нужна только в Windows
+func LongPath(path string) string {
+	return path
+}
@@ -0,0 +1,24 @@
+package scan
+
+import "testing"
+
+func TestExtendedPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`C:\dir\file.txt`, `\\?\C:\dir\file.txt`},
+		{`\\server\share\file.txt`, `\\?\UNC\server\share\file.txt`},
+		{`\\?\C:\dir\file.txt`, `\\?\C:\dir\file.txt`},
+		{`\\?\UNC\server\share\file.txt`, `\\?\UNC\server\share\file.txt`},
+		{`\\.\pipe\name`, `\\.\pipe\name`},
+		{`dir\file.txt`, `dir\file.txt`}, // Не абсолютный - без изменений
+		{`C:file.txt`, `C:file.txt`},
+		{`/home/user/file.txt`, `/home/user/file.txt`},
+	}
+	for _, tt := range tests {
+		if got := extendedPath(tt.path); got != tt.want {
+			t.Errorf("extendedPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
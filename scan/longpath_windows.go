@@ -0,0 +1,23 @@
+// Длинные пути Windows: расширенная форма \\?\ снимает ограничение MAX_PATH (260 символов)
+package scan
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LongPath возвращает путь в расширенной форме \\?\C:\... (сетевой - \\?\UNC\server\share\...),
+// с которой Windows API работает с путями длиннее 260 символов (глубокие node_modules, папка «Загрузки»).
+// Такой путь нужен только для вызовов ОС: в FileInfo.Path и выводе остается обычный.
+// Относительный путь сначала становится абсолютным и очищенным: в форме \\?\ нет "." и "..".
+// Уже расширенные пути (\\?\, \\.\) возвращаются как есть.
+func LongPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return extendedPath(abs)
+}
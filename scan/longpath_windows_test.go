@@ -0,0 +1,48 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPathScan(t *testing.T) {
+	dir := t.TempDir()
+	deep := dir
+	for len(deep) < 300 {
+		deep = filepath.Join(deep, strings.Repeat("d", 50))
+	}
+	if err := os.MkdirAll(LongPath(deep), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(LongPath(filepath.Join(deep, name)), []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := newTestScanner(t, dir, nil).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stats.Errors != 0 || len(result.Groups) != 1 || len(result.Groups[0]) != 2 {
+		t.Fatalf("ошибок %d, группы %v, want одна группа из двух файлов", result.Stats.Errors, result.Groups)
+	}
+	// В FileInfo.Path - обычный путь, расширенная форма только для вызовов ОС
+	for _, f := range result.Groups[0] {
+		if strings.HasPrefix(f.Path, `\\?\`) || len(f.Path) < 300 {
+			t.Errorf("путь %q, want обычный длинный путь", f.Path)
+		}
+	}
+}
+
+func TestLongPathRelative(t *testing.T) {
+	t.Chdir(t.TempDir())
+	got := LongPath(`sub\..\file.txt`)
+	wd, _ := os.Getwd()
+	if want := `\\?\` + filepath.Join(wd, "file.txt"); got != want {
+		t.Errorf("LongPath = %q, want %q", got, want)
+	}
+}
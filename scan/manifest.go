@@ -0,0 +1,173 @@
+// Манифест: снимок путей, размеров, времени изменения и хэшей для сравнения без доступа к файлам
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Заголовок манифеста: первая строка файла
+const (
+	manifestFormat    = "duplifinder-manifest"
+	manifestVersion   = 1
+	manifestAlgorithm = "sha256" // Полный SHA-256, как в режиме hash
+)
+
+// manifestRootPrefix отличает виртуальный корень манифеста от путей файловой системы
+const manifestRootPrefix = "manifest:"
+
+// ManifestHeader - первая строка манифеста
+type ManifestHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Algorithm string    `json:"algorithm"`
+	Created   time.Time `json:"created"`
+	Roots     []string  `json:"roots,omitempty"`
+}
+
+// ManifestEntry - одна запись манифеста (строка JSON Lines)
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+}
+
+// validateManifestMode проверяет, что хэши манифеста сравнимы с режимом поиска.
+// В манифесте полный SHA-256, а fast_hash считает хэш только по краям файла.
+func validateManifestMode(mode Mode) error {
+	if mode == ModeFastHash || mode == ModeImage {
+		return fmt.Errorf("манифест хранит полный хэш %s, режим %s с ним несравним", manifestAlgorithm, mode)
+	}
+	if mode == ModeCompare {
+		return fmt.Errorf("в режиме %s файлы сравниваются побайтово, а у записей манифеста есть только хэш", mode)
+	}
+	if mode == ModeChunks {
+		return fmt.Errorf("в режиме %s файлы режутся на блоки, а у записей манифеста есть только хэш всего файла", mode)
+	}
+	return nil
+}
+
+// WriteManifest сохраняет манифест файлов (с уже посчитанными хэшами).
+// Файлы, которые не удалось прочитать, не записываются; возвращается число записанных.
+func WriteManifest(path string, roots []string, files []FileInfo) (int, error) {
+	entries := make([]ManifestEntry, 0, len(files))
+	for _, file := range files {
+		if file.Hash == "" || file.Err != nil {
+			continue
+		}
+		entries = append(entries, ManifestEntry{Path: file.Path, Size: file.Size, ModTime: file.ModTime, Hash: file.Hash})
+	}
+	return writeManifestEntries(path, roots, entries)
+}
+
+// writeManifestEntries записывает заголовок и записи манифеста, возвращает число записанных
+func writeManifestEntries(path string, roots []string, entries []ManifestEntry) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	header := ManifestHeader{
+		Format:    manifestFormat,
+		Version:   manifestVersion,
+		Algorithm: manifestAlgorithm,
+		Created:   time.Now(),
+		Roots:     roots,
+	}
+	if err := enc.Encode(header); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := w.Flush(); err != nil {
+		return written, err
+	}
+	return written, f.Close()
+}
+
+// ReadManifest загружает манифест как файлы виртуального корня "manifest:<путь>".
+// Такие файлы уже имеют хэш и при поиске не читаются.
+func ReadManifest(path string) ([]FileInfo, error) {
+	_, entries, err := readManifestEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	root := manifestRootPrefix + path
+	files := make([]FileInfo, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, FileInfo{
+			Path:         e.Path,
+			Name:         manifestBase(e.Path),
+			Size:         e.Size,
+			Hash:         e.Hash,
+			ModTime:      e.ModTime,
+			Root:         root,
+			fromManifest: true,
+		})
+	}
+	return files, nil
+}
+
+// readManifestEntries читает и проверяет заголовок манифеста, затем все записи
+func readManifestEntries(path string) (ManifestHeader, []ManifestEntry, error) {
+	var header ManifestHeader
+	f, err := os.Open(path)
+	if err != nil {
+		return header, nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return header, nil, err
+		}
+		return header, nil, fmt.Errorf("%s: пустой файл, нет заголовка манифеста", path)
+	}
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil || header.Format != manifestFormat {
+		return header, nil, fmt.Errorf("%s: не манифест duplifinder", path)
+	}
+	if header.Version != manifestVersion {
+		return header, nil, fmt.Errorf("%s: неподдерживаемая версия манифеста %d", path, header.Version)
+	}
+	if header.Algorithm != manifestAlgorithm {
+		return header, nil, fmt.Errorf("%s: неподдерживаемый алгоритм хэша %q", path, header.Algorithm)
+	}
+
+	var entries []ManifestEntry
+	for line := 2; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e ManifestEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return header, nil, fmt.Errorf("%s: строка %d повреждена: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return header, entries, sc.Err()
+}
+
+// manifestBase возвращает имя файла из пути манифеста.
+// Манифест мог быть снят на другой ОС, поэтому разделителями считаются и "/", и "\".
+func manifestBase(path string) string {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
@@ -0,0 +1,172 @@
+// Метрики Prometheus: текстовый формат экспозиции для /metrics и textfile collector
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lastScan - итог последнего завершенного Run (читается из WriteMetrics во время следующего)
+type lastScan struct {
+	mu sync.Mutex
+	scanSummary
+}
+
+// scanSummary - то, что о завершенном запуске нужно метрикам
+type scanSummary struct {
+	done        bool
+	duration    time.Duration
+	throughput  float64 // Байт в секунду за время хэширования
+	reclaimable int64
+}
+
+// get возвращает копию итога
+func (l *lastScan) get() scanSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.scanSummary
+}
+
+// record запоминает итог запуска: его длительность, сколько байт прочитано за время hashTime
+// и сколько места освободится
+func (l *lastScan) record(duration, hashTime time.Duration, hashed, reclaimable int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done = true
+	l.duration = duration
+	l.throughput = 0
+	if hashTime > 0 {
+		l.throughput = float64(hashed) / hashTime.Seconds()
+	}
+	l.reclaimable = reclaimable
+}
+
+// metric - семейство метрик: имя, тип и описание печатаются один раз на все сканеры
+type metric struct {
+	name, kind, help string
+	values           []sample
+}
+
+// sample - значение метрики с метками
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+// WriteMetrics пишет метрики сканеров в текстовом формате Prometheus: для ответа на /metrics
+// или файла textfile collector node_exporter. У каждого сканера метки mode и root (корни через
+// запятую), так что один процесс может отдавать метрики нескольких целей; если у двух сканеров
+// они совпадают, передавать нужно один (например, последний запущенный).
+//
+// Статистика (файлы, ошибки, группы, объем кандидатов) - это текущий или последний запуск,
+// duplifinder_bytes_hashed_total копится за все запуски сканера, а длительность и освобождаемый
+// объем - итог последнего завершенного Run.
+func WriteMetrics(w io.Writer, scanners ...*Scanner) error {
+	families := []*metric{
+		{name: "duplifinder_files", kind: "gauge", help: "Найдено файлов текущим или последним запуском"},
+		{name: "duplifinder_root_files", kind: "gauge", help: "Найдено файлов в каждом корне"},
+		{name: "duplifinder_errors", kind: "gauge", help: "Ошибок обхода и чтения"},
+		{name: "duplifinder_changed_files", kind: "gauge", help: "Пропущено файлов, изменившихся во время сканирования"},
+		{name: "duplifinder_other_owner_files", kind: "gauge", help: "Пропущено файлов других владельцев"},
+		{name: "duplifinder_duplicate_groups", kind: "gauge", help: "Найдено групп дубликатов"},
+		{name: "duplifinder_candidate_bytes", kind: "gauge", help: "Объем кандидатов в дубликаты к чтению, байт"},
+		{name: "duplifinder_bytes_hashed_total", kind: "counter", help: "Прочитано для хэширования за все запуски, байт"},
+		{name: "duplifinder_hash_throughput_bytes", kind: "gauge", help: "Скорость хэширования, байт в секунду: текущей фазы или последнего запуска"},
+		{name: "duplifinder_active_workers", kind: "gauge", help: "Воркеров, которые сейчас читают файл"},
+		{name: "duplifinder_scan_running", kind: "gauge", help: "1, пока идет сканирование"},
+		{name: "duplifinder_scan_duration_seconds", kind: "gauge", help: "Длительность последнего завершенного запуска, секунд"},
+		{name: "duplifinder_reclaimable_bytes", kind: "gauge", help: "Сколько освободится, если оставить по одному файлу в группе (последний завершенный запуск), байт"},
+	}
+	byName := make(map[string]*metric, len(families))
+	for _, m := range families {
+		byName[m.name] = m
+	}
+	add := func(name string, labels [][2]string, value float64) {
+		byName[name].values = append(byName[name].values, sample{labels, value})
+	}
+
+	for _, s := range scanners {
+		labels := [][2]string{{"mode", string(s.config.Mode)}, {"root", strings.Join(s.roots, ",")}}
+		stats := s.GetStats()
+		st := s.Status()
+		active := 0
+		for _, path := range st.Current {
+			if path != "" {
+				active++
+			}
+		}
+		last := s.last.get()
+		throughput := last.throughput
+		if st.Phase == PhaseHashing || st.Phase == PhaseVerifying {
+			if elapsed := time.Since(time.Unix(0, s.progress.hashStarted.Load())); elapsed > 0 {
+				throughput = float64(st.BytesHashed) / elapsed.Seconds()
+			}
+		}
+
+		add("duplifinder_files", labels, float64(stats.TotalFiles))
+		roots := make([]string, 0, len(stats.FilesPerRoot))
+		for root := range stats.FilesPerRoot {
+			roots = append(roots, root)
+		}
+		sort.Strings(roots)
+		for _, root := range roots {
+			add("duplifinder_root_files", [][2]string{labels[0], {"root", root}}, float64(stats.FilesPerRoot[root]))
+		}
+		add("duplifinder_errors", labels, float64(stats.Errors))
+		add("duplifinder_changed_files", labels, float64(stats.ChangedFiles))
+		add("duplifinder_timed_out_files", labels, float64(stats.TimedOutFiles))
+		add("duplifinder_read_retries", labels, float64(stats.ReadRetries))
+		add("duplifinder_retry_failures", labels, float64(stats.RetryFailures))
+		add("duplifinder_other_owner_files", labels, float64(stats.OtherOwnerFiles))
+		add("duplifinder_duplicate_groups", labels, float64(stats.DuplicateGroups))
+		add("duplifinder_candidate_bytes", labels, float64(stats.TotalCandidateBytes))
+		add("duplifinder_bytes_hashed_total", labels, float64(atomic.LoadInt64(&s.progress.hashedTotal)))
+		add("duplifinder_hash_throughput_bytes", labels, throughput)
+		add("duplifinder_active_workers", labels, float64(active))
+		add("duplifinder_scan_running", labels, boolMetric(st.Phase != "" && st.Phase != PhaseDone))
+		if last.done { // Пока ни один запуск не завершен, этих метрик у сканера нет
+			add("duplifinder_scan_duration_seconds", labels, last.duration.Seconds())
+			add("duplifinder_reclaimable_bytes", labels, float64(last.reclaimable))
+		}
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, m := range families {
+		if len(m.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, v := range m.values {
+			buf.WriteString(m.name)
+			buf.WriteByte('{')
+			for i, label := range v.labels {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, "%s=\"%s\"", label[0], labelEscaper.Replace(label[1]))
+			}
+			buf.WriteString("} ")
+			buf.WriteString(strconv.FormatFloat(v.value, 'g', -1, 64))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Flush()
+}
+
+// labelEscaper экранирует значение метки, как требует формат Prometheus: только \, " и перевод строки
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// boolMetric - 1 для true, 0 для false
+func boolMetric(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
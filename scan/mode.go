@@ -0,0 +1,71 @@
+// Режимы поиска: по каким признакам файлы считаются копиями
+package scan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode - режим поиска (Config.Mode). Из строки получается через ParseMode.
+type Mode string
+
+// Поддерживаемые значения Config.Mode
+const (
+	ModeNameOnly     Mode = "name_only"      // Только имя, содержимое не сравнивается
+	ModeNameSize     Mode = "name_size"      // Имя и размер, без хэширования
+	ModeSizeOnly     Mode = "size_only"      // Только размер: быстрая оценка, есть ли смысл хэшировать
+	ModeNameCount    Mode = "name_count"     // Имена, повторяющиеся не меньше NameCountThreshold раз
+	ModeHash         Mode = "hash"           // Содержимое (полный SHA-256)
+	ModeFastHash     Mode = "fast_hash"      // Размер и первые/последние 4КБ, возможны ложные совпадения
+	ModeCombined     Mode = "combined"       // Имя и хэш
+	ModeNameSizeHash Mode = "name_size_hash" // Имя, размер и хэш
+	ModeNameHashDir  Mode = "name_hash_dir"  // Имя, хэш и имя родительской папки
+	ModeImage        Mode = "image"          // Похожие изображения: перцептивный хэш в пределах ImageDistance
+	ModeCompare      Mode = "compare"        // Содержимое: побайтовое сравнение блоками вместо хэша (см. compareGroups)
+	ModeChunks       Mode = "chunks"         // Не группы, а оценка экономии от дедупликации блоками (Result.ChunkReport)
+)
+
+// modes - все режимы в порядке справки
+var modes = []Mode{
+	ModeNameOnly, ModeNameSize, ModeSizeOnly, ModeNameCount,
+	ModeHash, ModeFastHash, ModeCombined, ModeNameSizeHash, ModeNameHashDir, ModeImage, ModeCompare, ModeChunks,
+}
+
+// ParseMode переводит строку (например, значение флага) в Mode.
+// Для неизвестного значения ошибка перечисляет допустимые.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range modes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	names := make([]string, len(modes))
+	for i, m := range modes {
+		names[i] = string(m)
+	}
+	return "", fmt.Errorf("неизвестный режим %q (допустимо: %s)", s, strings.Join(names, ", "))
+}
+
+// hashesContent сообщает, читает ли режим содержимое файлов
+func (m Mode) hashesContent() bool {
+	switch m {
+	case ModeNameOnly, ModeNameSize, ModeSizeOnly, ModeNameCount:
+		return false
+	}
+	return true
+}
+
+// ComparesNames сообщает, входит ли имя файла в ключ группировки режима
+func (m Mode) ComparesNames() bool {
+	switch m {
+	case ModeHash, ModeFastHash, ModeSizeOnly, ModeImage, ModeCompare, ModeChunks:
+		return false
+	}
+	return true
+}
+
+// validateMode проверяет значение Config.Mode
+func validateMode(mode Mode) error {
+	_, err := ParseMode(string(mode))
+	return err
+}
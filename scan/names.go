@@ -0,0 +1,77 @@
+// Сравнение имен файлов в ключах группировки: нормализация Unicode, суффиксы копий, регистр и расширение
+package scan
+
+import (
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// caseInsensitiveOS сообщает, различает ли обычная файловая система ОС регистр в именах:
+// на Windows (NTFS) и macOS (APFS по умолчанию) "Report.PDF" и "report.pdf" - одно имя
+func caseInsensitiveOS() bool {
+	return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+}
+
+// nameKey приводит имя файла к виду для ключа группировки. Меняется только ключ:
+// FileInfo.Name и FileInfo.Path остаются как на диске.
+//
+// При Config.NormalizeNames имя приводится к NFC: macOS хранит "é" как "e" и
+// комбинируемый акцент (NFD), а Linux обычно как один символ, и после копирования
+// между ними одинаковые на вид имена различаются байтами.
+//
+// При Config.CaseInsensitiveNames используется полное свертывание регистра Unicode
+// (case folding), а не побайтовое ToLower: "straße" и "STRASSE" дают одно и то же "strasse".
+// Свертывание не зависит от языка: турецкая "İ" (I с точкой) становится "i" с
+// комбинируемой точкой и не совпадает с "i" и "I".
+//
+// При Config.IgnoreExtensionInName последнее расширение отбрасывается: "song.mp3" -> "song".
+// У имен из одного расширения (".bashrc") и без него имя остается целым.
+func (s *Scanner) nameKey(name string) string {
+	if s.config.NormalizeNames {
+		name = norm.NFC.String(name)
+	}
+	if s.config.FuzzyNames {
+		name = CanonicalName(name)
+	}
+	if s.config.IgnoreExtensionInName {
+		if stem := strings.TrimSuffix(name, path.Ext(name)); stem != "" {
+			name = stem
+		}
+	}
+	if s.config.CaseInsensitiveNames {
+		name = cases.Fold().String(name)
+	}
+	return name
+}
+
+// copyNameRules - приметы копии, которые файловые менеджеры и облака добавляют к имени.
+// Применяются к имени без расширения по порядку; регистр не важен.
+var copyNameRules = []*regexp.Regexp{
+	regexp.MustCompile(`(?i) \(\d+\)$`),                               // "photo (1)": браузеры, Windows, Google Drive
+	regexp.MustCompile(`(?i) [-—] (copy|копия|kopie|copie|copia)$`),   // "photo - Copy": Проводник Windows
+	regexp.MustCompile(`(?i) (copy|копия|kopie|copie|copia)( \d+)?$`), // "photo copy 2": Finder macOS
+	regexp.MustCompile(`(?i)_(copy|копия)\d*$`),                       // "photo_copy"
+	regexp.MustCompile(`(?i)^(copy( \(\d+\))? of|копия) `),            // "Copy of photo": Google Drive, старые версии Windows
+}
+
+// CanonicalName убирает из имени файла приметы копии (" (1)", " - Copy", "Copy of " и т.п.,
+// в том числе русские, немецкие, французские и испанские варианты), сохраняя расширение:
+// "Copy of photo (2).jpg" -> "photo.jpg". По этому имени группирует Config.FuzzyNames.
+func CanonicalName(name string) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	// Приметы могут наслаиваться: копия копии - "photo - Copy - Copy (2)".
+	// После каждой замены правила проверяются заново с первого, чтобы " - Copy"
+	// снималось целиком, а не превращалось в " -" правилом Finder.
+	for i := 0; i < len(copyNameRules); i++ {
+		if s := copyNameRules[i].ReplaceAllString(stem, ""); s != stem && s != "" {
+			stem, i = s, -1
+		}
+	}
+	return stem + ext
+}
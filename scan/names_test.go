@@ -0,0 +1,60 @@
+package scan
+
+import "testing"
+
+func TestNameKeyCaseFolding(t *testing.T) {
+	s := &Scanner{config: Config{NormalizeNames: true, CaseInsensitiveNames: true}}
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Report.PDF", "report.pdf", true},
+		{"straße.txt", "STRASSE.TXT", true}, // Полное свертывание: ß -> ss
+		{"Straße.txt", "strasse.txt", true},
+		{"ПРИВЕТ.txt", "привет.txt", true},
+		{"café.txt", "CAFÉ.TXT", true}, // NFC и NFD с разным регистром
+		// Свертывание не зависит от языка: турецкая İ - это i с точкой сверху,
+		// а не i и не I; точечная и бесточечная i тоже разные буквы
+		{"İstanbul.txt", "istanbul.txt", false},
+		{"İstanbul.txt", "Istanbul.txt", false},
+		{"İSTANBUL.txt", "i̇stanbul.txt", true},
+		{"ılık.txt", "ILIK.txt", false},
+	}
+	for _, tt := range tests {
+		if got := s.nameKey(tt.a) == s.nameKey(tt.b); got != tt.same {
+			t.Errorf("nameKey(%q) == nameKey(%q): %v, want %v (%q, %q)", tt.a, tt.b, got, tt.same, s.nameKey(tt.a), s.nameKey(tt.b))
+		}
+	}
+
+	// Без CaseInsensitiveNames регистр различается
+	s.config.CaseInsensitiveNames = false
+	if s.nameKey("straße.txt") == s.nameKey("STRASSE.TXT") {
+		t.Error("без CaseInsensitiveNames straße.txt и STRASSE.TXT совпали")
+	}
+}
+
+func TestCanonicalName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"photo (1).jpg", "photo.jpg"},
+		{"Copy of photo (2).jpg", "photo.jpg"},
+		{"photo - Copy - Copy (2).jpg", "photo.jpg"},
+		{"Straße - Kopie.txt", "Straße.txt"},
+		{"STRASSE - KOPIE.TXT", "STRASSE.TXT"},
+		{"İstanbul copy 2.txt", "İstanbul.txt"},
+		{"копия отчет.docx", "отчет.docx"},
+		{" (1).jpg", " (1).jpg"}, // От имени ничего не осталось бы
+	}
+	for _, tt := range tests {
+		if got := CanonicalName(tt.name); got != tt.want {
+			t.Errorf("CanonicalName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Нечеткие имена и регистр вместе: копия с другим регистром - то же имя
+	s := &Scanner{config: Config{NormalizeNames: true, CaseInsensitiveNames: true, FuzzyNames: true}}
+	if a, b := s.nameKey("STRASSE - Kopie.TXT"), s.nameKey("straße.txt"); a != b {
+		t.Errorf("nameKey: %q != %q", a, b)
+	}
+}
@@ -0,0 +1,142 @@
+// Необязательные настройки сканера (функциональные опции) и значения по умолчанию
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"log/slog"
+)
+
+// Option - необязательная настройка сканера, передается в NewScanner или NewScannerE.
+// Опция проверяет свое значение сразу; ошибка возвращается из конструктора.
+type Option func(*Scanner) error
+
+// DefaultConfig возвращает настройки по умолчанию для сканирования dir:
+// режим hash, тип диска и число воркеров определяются автоматически,
+// имена приводятся к NFC, регистр в именах не различается на Windows и macOS,
+// жесткие ссылки на один файл не считаются дубликатами
+func DefaultConfig(dir string) Config {
+	return Config{
+		DirPath:     dir,
+		Mode:        ModeHash,
+		DiskType:    DiskAuto,
+		ReadRetries: 2,
+		SortBy:      SortReclaimable,
+
+		NormalizeNames:       true,
+		CaseInsensitiveNames: caseInsensitiveOS(),
+		IgnoreHardlinks:      true,
+		ImageDistance:        DefaultImageDistance,
+	}
+}
+
+// NewScannerE создает сканер для dir с настройками DefaultConfig, измененными опциями.
+// Неверное значение опции - ошибка, а не паника.
+//
+//	scanner, err := scan.NewScannerE("/data", scan.WithMode(scan.ModeFastHash), scan.WithWorkers(4))
+func NewScannerE(dir string, opts ...Option) (*Scanner, error) {
+	return NewScanner(DefaultConfig(dir), opts...)
+}
+
+// QuickScan ищет дубликаты по содержимому в dir без настройки: DefaultConfig (режим hash,
+// по воркеру на ядро), без пустых файлов и без особых - симлинков, каналов, сокетов, устройств.
+// Возвращает группы дубликатов, как Result.Groups.
+//
+//	groups, err := scan.QuickScan("/data")
+func QuickScan(dir string) ([][]FileInfo, error) {
+	cfg := DefaultConfig(dir)
+	cfg.IgnoreEmptyFiles = true
+	cfg.FileFilter = func(_ string, info fs.FileInfo) bool { return info.Mode().IsRegular() }
+	scanner, err := NewScanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	result, err := scanner.Run(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return result.Groups, nil
+}
+
+// WithMode задает режим поиска (Config.Mode)
+func WithMode(mode Mode) Option {
+	return func(s *Scanner) error {
+		if err := validateMode(mode); err != nil {
+			return err
+		}
+		s.config.Mode = mode
+		return nil
+	}
+}
+
+// WithWorkers задает число воркеров (Config.Workers) вместо подобранного по накопителю
+func WithWorkers(n int) Option {
+	return func(s *Scanner) error {
+		if n < 1 {
+			return fmt.Errorf("нужен хотя бы один воркер, задано %d", n)
+		}
+		s.config.Workers = n
+		return nil
+	}
+}
+
+// WithHasher задает алгоритм хэша содержимого вместо SHA-256 (например, более быстрый
+// некриптографический). Манифесты и контрольные суммы по-прежнему считаются в SHA-256,
+// поэтому сравнение с манифестами (Config.Manifests) с этой опцией недоступно.
+func WithHasher(newHash func() hash.Hash) Option {
+	return func(s *Scanner) error {
+		if newHash == nil {
+			return errors.New("WithHasher: нужна функция создания хэша")
+		}
+		s.newHash = newHash
+		return nil
+	}
+}
+
+// WithFilter задает пользовательский фильтр файлов (Config.FileFilter)
+func WithFilter(filter func(path string, info fs.FileInfo) bool) Option {
+	return func(s *Scanner) error {
+		s.config.FileFilter = filter
+		return nil
+	}
+}
+
+// WithProgress задает колбэк прогресса (Config.OnProgress)
+func WithProgress(onProgress func(Progress)) Option {
+	return func(s *Scanner) error {
+		s.config.OnProgress = onProgress
+		return nil
+	}
+}
+
+// WithLogger задает свой журнал, как Config.Logger (и важнее его): Config.LogLevel и
+// Config.LogFormat тогда не используются
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scanner) error {
+		if logger == nil {
+			return errors.New("WithLogger: журнал не задан")
+		}
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithFS задает файловую систему для обхода и чтения, как Config.FS: например, fstest.MapFS
+// в тестах или zip.Reader для архива. Корни (Config.DirPath, Config.Roots) - пути внутри fsys.
+// Без нее используется файловая система ОС.
+//
+// Чего fs.FS не умеет, то отключается: симлинки не раскрываются (fs.WalkDir их не обходит),
+// тип диска не определяется (DiskType auto работает как ssd), а пути в результате
+// относительные, через "/" - действия над такими файлами неприменимы.
+func WithFS(fsys fs.FS) Option {
+	return func(s *Scanner) error {
+		if fsys == nil {
+			return errors.New("WithFS: файловая система не задана")
+		}
+		s.config.FS = fsys
+		return nil
+	}
+}
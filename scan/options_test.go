@@ -0,0 +1,419 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// mapFSGroups сканирует fsys с настройками cfg и опцией WithFS и возвращает пути групп
+func mapFSGroups(t *testing.T, fsys fstest.MapFS, cfg Config) [][]string {
+	t.Helper()
+	s, err := NewScanner(cfg, WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups [][]string
+	for _, group := range result.Groups {
+		var paths []string
+		for _, f := range group {
+			paths = append(paths, f.Path)
+		}
+		groups = append(groups, paths)
+	}
+	slices.SortFunc(groups, func(a, b []string) int { return slices.Compare(a, b) })
+	return groups
+}
+
+func TestWithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/same.txt":   {Data: []byte("содержимое")},
+		"a/other.txt":  {Data: []byte("СОДЕРЖИМОЕ")}, // Тот же размер, другое содержимое
+		"a/empty1":     {Data: nil},
+		"a/empty2":     {Data: nil},
+		"b/same.txt":   {Data: []byte("содержимое")},
+		"b/inside.txt": {Data: []byte("копия внутри b")},
+		"b/dup.txt":    {Data: []byte("копия внутри b")},
+	}
+	tests := []struct {
+		name      string
+		roots     []string
+		configure func(*Config)
+		want      [][]string
+	}{
+		{"hash", []string{"a", "b"}, nil, [][]string{
+			{"a/empty1", "a/empty2"},
+			{"a/same.txt", "b/same.txt"},
+			{"b/dup.txt", "b/inside.txt"},
+		}},
+		{"без пустых", []string{"a", "b"}, func(cfg *Config) { cfg.IgnoreEmptyFiles = true }, [][]string{
+			{"a/same.txt", "b/same.txt"},
+			{"b/dup.txt", "b/inside.txt"},
+		}},
+		{"только между корнями", []string{"a", "b"}, func(cfg *Config) { cfg.CrossRoot = true }, [][]string{
+			{"a/same.txt", "b/same.txt"},
+		}},
+		{"name_only", []string{"."}, func(cfg *Config) { cfg.Mode = ModeNameOnly }, [][]string{
+			{"a/same.txt", "b/same.txt"},
+		}},
+		{"один корень", []string{"b"}, nil, [][]string{
+			{"b/dup.txt", "b/inside.txt"},
+		}},
+		{"FileFilter", []string{"a", "b"}, func(cfg *Config) {
+			cfg.FileFilter = func(path string, _ fs.FileInfo) bool { return path != "b/same.txt" }
+		}, [][]string{
+			{"a/empty1", "a/empty2"},
+			{"b/dup.txt", "b/inside.txt"},
+		}},
+		// Файл должен пройти и FileFilter, и встроенные фильтры
+		{"FileFilter и IgnoreEmptyFiles", []string{"a", "b"}, func(cfg *Config) {
+			cfg.FileFilter = func(path string, _ fs.FileInfo) bool { return path != "b/inside.txt" }
+			cfg.IgnoreEmptyFiles = true
+		}, [][]string{
+			{"a/same.txt", "b/same.txt"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig("")
+			cfg.Roots = tt.roots
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+			if got := mapFSGroups(t, fsys, cfg); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("группы %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirFilter(t *testing.T) {
+	fsys := fstest.MapFS{
+		"top.txt":        {Data: []byte("в корне")},
+		"photos/top.txt": {Data: []byte("в корне")},
+		"a/b/one.txt":    {Data: []byte("соседние папки")},
+		"a/bc/one.txt":   {Data: []byte("соседние папки")}, // "a/b" - не префикс "a/bc"
+		"a/b/x.txt":      {Data: []byte("одна папка")},
+		"a/b/y.txt":      {Data: []byte("одна папка")},
+		"root1.txt":      {Data: []byte("оба в корне")},
+		"root2.txt":      {Data: []byte("оба в корне")},
+		"photos/2023/p":  {Data: []byte("одна верхняя папка")},
+		"photos/2024/p":  {Data: []byte("одна верхняя папка")},
+	}
+	tests := []struct {
+		filter string
+		want   [][]string
+	}{
+		{DirFilterWithin, [][]string{
+			{"a/b/x.txt", "a/b/y.txt"},
+			{"root1.txt", "root2.txt"},
+		}},
+		{DirFilterAcross, [][]string{
+			{"a/b/one.txt", "a/bc/one.txt"},
+			{"photos/2023/p", "photos/2024/p"},
+			{"photos/top.txt", "top.txt"},
+		}},
+		// Файлы прямо в корне - своя "верхняя папка", отличная от photos
+		{DirFilterAcrossTop, [][]string{
+			{"photos/top.txt", "top.txt"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			cfg := DefaultConfig(".")
+			cfg.DirFilter = tt.filter
+			if got := mapFSGroups(t, fsys, cfg); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("группы %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModeKeys(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x.txt":        {Data: []byte("same")},
+		"b/x.txt":        {Data: []byte("same")},
+		"a/y.txt":        {Data: []byte("same")}, // Другое имя
+		"c/photos/p.jpg": {Data: []byte("img")},
+		"d/photos/p.jpg": {Data: []byte("img")},
+		"d/other/p.jpg":  {Data: []byte("img")}, // Другое имя папки
+		"a/notes.md":     {Data: []byte("коротко")},
+		"b/notes.md":     {Data: []byte("совсем другой и длинный текст")}, // Другой размер
+	}
+	tests := []struct {
+		mode Mode
+		want [][]string
+	}{
+		{ModeHash, [][]string{
+			{"a/x.txt", "a/y.txt", "b/x.txt"},
+			{"c/photos/p.jpg", "d/other/p.jpg", "d/photos/p.jpg"},
+		}},
+		// combined: имя + хэш
+		{ModeCombined, [][]string{
+			{"a/x.txt", "b/x.txt"},
+			{"c/photos/p.jpg", "d/other/p.jpg", "d/photos/p.jpg"},
+		}},
+		// name_size_hash: имя + размер + хэш
+		{ModeNameSizeHash, [][]string{
+			{"a/x.txt", "b/x.txt"},
+			{"c/photos/p.jpg", "d/other/p.jpg", "d/photos/p.jpg"},
+		}},
+		// name_hash_dir: имя + хэш + имя родительской папки (не весь путь)
+		{ModeNameHashDir, [][]string{
+			{"c/photos/p.jpg", "d/photos/p.jpg"},
+		}},
+		// name_only: только имя - файлы разного размера тоже в одной группе
+		{ModeNameOnly, [][]string{
+			{"a/notes.md", "b/notes.md"},
+			{"a/x.txt", "b/x.txt"},
+			{"c/photos/p.jpg", "d/other/p.jpg", "d/photos/p.jpg"},
+		}},
+		// name_size: при разном размере одно имя - не копия
+		{ModeNameSize, [][]string{
+			{"a/x.txt", "b/x.txt"},
+			{"c/photos/p.jpg", "d/other/p.jpg", "d/photos/p.jpg"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			cfg := DefaultConfig(".")
+			cfg.Mode = tt.mode
+			if got := mapFSGroups(t, fsys, cfg); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("группы %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreExtensionInName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/song.mp3":  {Data: []byte("звук")},
+		"b/song.flac": {Data: []byte("звук")},
+		"c/song.ogg":  {Data: []byte("шумы")}, // То же имя и размер, другое содержимое
+		"d/track.mp3": {Data: []byte("звук")}, // То же содержимое, другое имя
+		"e/.bashrc":   {Data: []byte("rc")},
+		"f/.bashrc":   {Data: []byte("rc")}, // Имя из одного расширения не обрезается
+	}
+	tests := []struct {
+		name   string
+		mode   Mode
+		ignore bool
+		want   [][]string
+	}{
+		{"combined", ModeCombined, false, [][]string{
+			{"e/.bashrc", "f/.bashrc"},
+		}},
+		{"combined без расширений", ModeCombined, true, [][]string{
+			{"a/song.mp3", "b/song.flac"},
+			{"e/.bashrc", "f/.bashrc"},
+		}},
+		{"name_size_hash без расширений", ModeNameSizeHash, true, [][]string{
+			{"a/song.mp3", "b/song.flac"},
+			{"e/.bashrc", "f/.bashrc"},
+		}},
+		{"name_only без расширений", ModeNameOnly, true, [][]string{
+			{"a/song.mp3", "b/song.flac", "c/song.ogg"},
+			{"e/.bashrc", "f/.bashrc"},
+		}},
+		// В hash имени в ключе нет: настройка ничего не меняет
+		{"hash", ModeHash, false, [][]string{
+			{"a/song.mp3", "b/song.flac", "d/track.mp3"},
+			{"e/.bashrc", "f/.bashrc"},
+		}},
+		{"hash без расширений", ModeHash, true, [][]string{
+			{"a/song.mp3", "b/song.flac", "d/track.mp3"},
+			{"e/.bashrc", "f/.bashrc"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig(".")
+			cfg.Mode = tt.mode
+			cfg.IgnoreExtensionInName = tt.ignore
+			if got := mapFSGroups(t, fsys, cfg); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("группы %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireSameName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/report.pdf": {Data: []byte("отчет")},
+		"b/report.pdf": {Data: []byte("отчет")},
+		"c/copy.pdf":   {Data: []byte("отчет")}, // То же содержимое, другое имя
+		"a/Notes.txt":  {Data: []byte("заметки")},
+		"b/notes.txt":  {Data: []byte("заметки")}, // Имя отличается регистром
+		"a/song.mp3":   {Data: []byte("звук")},
+		"b/song.flac":  {Data: []byte("звук")}, // Имя отличается расширением
+	}
+	tests := []struct {
+		name      string
+		mode      Mode
+		require   bool
+		configure func(cfg *Config)
+		want      [][]string
+	}{
+		{"hash", ModeHash, false, nil, [][]string{
+			{"a/Notes.txt", "b/notes.txt"},
+			{"a/report.pdf", "b/report.pdf", "c/copy.pdf"},
+			{"a/song.mp3", "b/song.flac"},
+		}},
+		{"hash с именем", ModeHash, true, nil, [][]string{
+			{"a/report.pdf", "b/report.pdf"},
+		}},
+		// С именем hash - то же, что name_size_hash
+		{"name_size_hash", ModeNameSizeHash, false, nil, [][]string{
+			{"a/report.pdf", "b/report.pdf"},
+		}},
+		{"size_only с именем", ModeSizeOnly, true, nil, [][]string{
+			{"a/report.pdf", "b/report.pdf"},
+		}},
+		{"compare с именем", ModeCompare, true, nil, [][]string{
+			{"a/report.pdf", "b/report.pdf"},
+		}},
+		// Имя сравнивается по тем же правилам, что в режимах с именем
+		{"hash с именем без регистра", ModeHash, true, func(cfg *Config) { cfg.CaseInsensitiveNames = true }, [][]string{
+			{"a/Notes.txt", "b/notes.txt"},
+			{"a/report.pdf", "b/report.pdf"},
+		}},
+		{"hash с именем без расширения", ModeHash, true, func(cfg *Config) { cfg.IgnoreExtensionInName = true }, [][]string{
+			{"a/report.pdf", "b/report.pdf"},
+			{"a/song.mp3", "b/song.flac"},
+		}},
+		// В режимах с именем в ключе настройка ничего не меняет
+		{"combined с именем", ModeCombined, true, nil, [][]string{
+			{"a/report.pdf", "b/report.pdf"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig(".")
+			cfg.Mode = tt.mode
+			cfg.CaseInsensitiveNames = false
+			cfg.RequireSameName = tt.require
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+			if got := mapFSGroups(t, fsys, cfg); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("группы %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, mode := range []Mode{ModeImage, ModeChunks} {
+		cfg := DefaultConfig(".")
+		cfg.Mode = mode
+		cfg.RequireSameName = true
+		if _, err := NewScanner(cfg, WithFS(fsys)); err == nil {
+			t.Errorf("RequireSameName с режимом %s принят", mode)
+		}
+	}
+}
+
+func TestModesWithoutReading(t *testing.T) {
+	files := fstest.MapFS{
+		"a/notes.md": {Data: []byte("коротко")},
+		"b/notes.md": {Data: []byte("совсем другой и длинный текст")},
+		"c/one.bin":  {Data: []byte("aaaa")},
+		"d/two.bin":  {Data: []byte("bbbb")}, // Тот же размер, другое содержимое
+	}
+	tests := []struct {
+		mode Mode
+		want []string // Пути единственной группы
+	}{
+		{ModeNameOnly, []string{"a/notes.md", "b/notes.md"}},
+		{ModeSizeOnly, []string{"c/one.bin", "d/two.bin"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			fsys := openCountingFS{MapFS: files, mu: new(sync.Mutex), opens: make(map[string]int)}
+			cfg := DefaultConfig(".")
+			cfg.Mode = tt.mode
+			s, err := NewScanner(cfg, WithFS(fsys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := s.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			if len(result.Groups) == 1 {
+				for _, f := range result.Groups[0] {
+					got = append(got, f.Path)
+				}
+				slices.Sort(got)
+			}
+			if len(result.Groups) != 1 || !slices.Equal(got, tt.want) {
+				t.Errorf("группы %v, want одна %q", result.Groups, tt.want)
+			}
+			// Режим без хэширования не читает содержимое
+			if len(fsys.opens) != 0 {
+				t.Errorf("открыты файлы %v, want ни одного", fsys.opens)
+			}
+		})
+	}
+}
+
+func TestNameCount(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/cover.jpg": {Data: []byte("1")},
+		"b/cover.jpg": {Data: []byte("22")}, // Содержимое не важно
+		"c/cover.jpg": {Data: []byte("333")},
+		"a/notes.txt": {Data: []byte("1")},
+		"b/notes.txt": {Data: []byte("1")},
+		"c/unique":    {Data: []byte("1")},
+	}
+	covers := []string{"a/cover.jpg", "b/cover.jpg", "c/cover.jpg"}
+	tests := []struct {
+		threshold int
+		want      [][]string
+	}{
+		{3, [][]string{covers}},
+		{0, [][]string{covers, {"a/notes.txt", "b/notes.txt"}}}, // 0 - от двух
+		{4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.threshold), func(t *testing.T) {
+			cfg := DefaultConfig(".")
+			cfg.Mode = ModeNameCount
+			cfg.NameCountThreshold = tt.threshold
+			if got := mapFSGroups(t, fsys, cfg); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("группы %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmallJobBuffer(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := range 1000 {
+		content := []byte(fmt.Sprintf("файл %04d", i)) // Один размер у всех: все - кандидаты
+		fsys[fmt.Sprintf("a/%d", i)] = &fstest.MapFile{Data: content}
+		fsys[fmt.Sprintf("b/%d", i)] = &fstest.MapFile{Data: content}
+	}
+	cfg := DefaultConfig(".")
+	cfg.Workers = 4
+	cfg.JobBufferSize = 1
+	if got := len(mapFSGroups(t, fsys, cfg)); got != 1000 {
+		t.Errorf("групп %d, want 1000", got)
+	}
+
+	// Без JobBufferSize очередь - по несколько задач на воркера, а не по задаче на файл
+	s, err := NewScanner(DefaultConfig("."), WithFS(fsys), WithWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.jobBufferSize(); got != 4*defaultJobsPerWorker {
+		t.Errorf("jobBufferSize() = %d, want %d", got, 4*defaultJobsPerWorker)
+	}
+}
@@ -0,0 +1,33 @@
+// Фильтр файлов по владельцу (Config.OwnerUID, Config.OnlyCurrentUser)
+package scan
+
+import (
+	"io/fs"
+	"os"
+)
+
+// resolveOwner определяет uid для фильтра по владельцу (-1 - фильтра нет).
+// Где uid не поддерживается, фильтр выключается с предупреждением в журнале,
+// а не ошибкой: те же настройки можно использовать на разных ОС.
+func (s *Scanner) resolveOwner() int {
+	if s.config.OwnerUID == nil && !s.config.OnlyCurrentUser {
+		return -1
+	}
+	if !ownerSupported || s.config.FS != nil {
+		s.logger.Warn("фильтр по владельцу недоступен, берутся файлы всех владельцев")
+		return -1
+	}
+	if s.config.OnlyCurrentUser {
+		return os.Getuid()
+	}
+	return *s.config.OwnerUID
+}
+
+// ownedBy сообщает, проходит ли файл фильтр по владельцу
+func (s *Scanner) ownedBy(info fs.FileInfo) bool {
+	if s.ownerUID < 0 {
+		return true
+	}
+	uid, ok := fileOwner(info)
+	return !ok || uid == s.ownerUID
+}
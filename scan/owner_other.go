@@ -0,0 +1,14 @@
+//go:build !unix
+
+// Без uid (Windows и др.): фильтр по владельцу не работает, файлы не отсеиваются
+package scan
+
+import "io/fs"
+
+// ownerSupported - можно ли фильтровать файлы по владельцу на этой ОС
+const ownerSupported = false
+
+// fileOwner всегда сообщает, что владелец неизвестен
+func fileOwner(fs.FileInfo) (uid int, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+// Владелец файла на unix-системах: uid из stat, уже полученного при обходе
+package scan
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// ownerSupported - можно ли фильтровать файлы по владельцу на этой ОС
+const ownerSupported = true
+
+// fileOwner возвращает uid владельца; ok == false, если fs.FileInfo не от ОС
+func fileOwner(info fs.FileInfo) (uid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(st.Uid), true
+}
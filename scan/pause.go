@@ -0,0 +1,52 @@
+// Пауза и продолжение запущенного сканирования (в отличие от Interrupt, работа не теряется)
+package scan
+
+import "sync"
+
+// pauseGate останавливает воркеров, пока сканирование на паузе.
+// Ждущие горутины спят на sync.Cond и не тратят CPU.
+type pauseGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+// Pause приостанавливает сканирование: воркеры доделывают текущий файл и ждут Resume,
+// обход останавливается на следующем файле. Безопасно вызывать из любой горутины.
+func (s *Scanner) Pause() {
+	s.pause.mu.Lock()
+	s.pause.paused = true
+	s.pause.mu.Unlock()
+}
+
+// Resume продолжает сканирование после Pause
+func (s *Scanner) Resume() {
+	s.pause.mu.Lock()
+	s.pause.paused = false
+	s.pause.mu.Unlock()
+	s.pause.cond.Broadcast()
+}
+
+// Paused сообщает, стоит ли сканирование на паузе
+func (s *Scanner) Paused() bool {
+	s.pause.mu.Lock()
+	defer s.pause.mu.Unlock()
+	return s.pause.paused
+}
+
+// waitIfPaused блокирует вызывающую горутину, пока действует пауза.
+// Остановка (Interrupt или отмена контекста) снимает блокировку, чтобы запуск мог завершиться.
+func (s *Scanner) waitIfPaused() {
+	s.pause.mu.Lock()
+	defer s.pause.mu.Unlock()
+	for s.pause.paused && !s.Interrupted() {
+		s.pause.cond.Wait()
+	}
+}
+
+// wakePaused будит ждущие горутины, чтобы они заметили остановку
+func (s *Scanner) wakePaused() {
+	s.pause.mu.Lock()
+	defer s.pause.mu.Unlock()
+	s.pause.cond.Broadcast()
+}
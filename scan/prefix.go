@@ -0,0 +1,132 @@
+// Недокачанные копии: файл, содержимое которого - начало другого, большего файла
+package scan
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// prefixBlock - сколько первых байт сравнивается у всех файлов, чтобы найти пары для побайтовой
+// проверки. Файлы меньше этого в поиске недокачанных не участвуют: короткое начало совпадает
+// у слишком многих файлов (заголовки форматов), и пар было бы слишком много.
+const prefixBlock = 4 << 10
+
+// PrefixMatch - файл Partial совпадает с началом большего файла Complete: скорее всего,
+// это прерванная загрузка или обрезанная копия
+type PrefixMatch struct {
+	Partial  FileInfo
+	Complete FileInfo
+}
+
+// PrefixMatches возвращает недокачанные копии, найденные последним запуском (Config.FindPrefixes)
+func (s *Scanner) PrefixMatches() []PrefixMatch {
+	return s.prefixes
+}
+
+// findPrefixes ищет среди files пары "начало - целый файл". Сначала у файлов от prefixBlock байт
+// хэшируются первые prefixBlock байт; внутри группы с одинаковым началом каждый файл побайтово
+// сравнивается с большими, от самого большого: для каждого недокачанного файла возвращается
+// одна пара - с самым большим файлом, началом которого он является. Файлы одного размера
+// сюда не попадают: это обычные дубликаты.
+func (s *Scanner) findPrefixes(files []FileInfo) []PrefixMatch {
+	var heads []FileInfo
+	for _, f := range files {
+		if f.Size >= prefixBlock && !f.fromManifest {
+			heads = append(heads, f)
+		}
+	}
+	s.setPhase(PhaseHashing)
+	s.hashFiles(int64(len(heads))*prefixBlock, func(send func(hashJob) bool) {
+		for i, f := range heads {
+			if !send(hashJob{path: f.Path, size: f.Size, index: i}) {
+				return
+			}
+		}
+	}, s.readHash(headHash), func(r hashResult) {
+		heads[r.index].Hash, heads[r.index].Err = r.hash, r.err
+	})
+
+	byHead := make(map[string][]FileInfo)
+	for _, f := range heads {
+		if f.Err == nil {
+			byHead[f.Hash] = append(byHead[f.Hash], f)
+		}
+	}
+	var matches []PrefixMatch
+	for _, group := range byHead {
+		if len(group) < 2 || s.Interrupted() {
+			continue
+		}
+		slices.SortFunc(group, func(a, b FileInfo) int { return cmp.Compare(b.Size, a.Size) })
+		for i, partial := range group {
+			for _, complete := range group[:i] {
+				if complete.Size == partial.Size {
+					break // Дальше только файлы того же размера
+				}
+				ok, err := s.isPrefix(partial, complete)
+				if err != nil {
+					s.logger.Debug("не удалось сравнить начало файлов", "partial", partial.Path, "complete", complete.Path, "err", err)
+					continue
+				}
+				if ok {
+					partial.Hash, complete.Hash = "", ""
+					matches = append(matches, PrefixMatch{Partial: partial, Complete: complete})
+					break
+				}
+			}
+		}
+	}
+	slices.SortFunc(matches, func(a, b PrefixMatch) int {
+		return strings.Compare(a.Partial.Path, b.Partial.Path)
+	})
+	s.logger.Info("поиск недокачанных копий завершен", "files", len(heads), "matches", len(matches))
+	return matches
+}
+
+// headHash - хэш первых prefixBlock байт файла
+func headHash(ctx context.Context, file fs.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.CopyN(h, ctxReader{ctx: ctx, r: file}, prefixBlock); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isPrefix побайтово сравнивает partial с началом complete
+func (s *Scanner) isPrefix(partial, complete FileInfo) (bool, error) {
+	a, err := s.openFile(partial.Path)
+	if err != nil {
+		return false, err
+	}
+	defer a.Close()
+	b, err := s.openFile(complete.Path)
+	if err != nil {
+		return false, err
+	}
+	defer b.Close()
+
+	ra := ctxReader{ctx: s.ctx, r: s.throttle(s.ctx, a)}
+	rb := ctxReader{ctx: s.ctx, r: s.throttle(s.ctx, b)}
+	bufA, bufB := make([]byte, 64<<10), make([]byte, 64<<10)
+	for left := partial.Size; left > 0; {
+		n := int(min(left, int64(len(bufA))))
+		if _, err := io.ReadFull(ra, bufA[:n]); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(rb, bufB[:n]); err != nil {
+			return false, err
+		}
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			return false, nil
+		}
+		left -= int64(n)
+	}
+	return true, nil
+}
@@ -0,0 +1,144 @@
+// Прогресс сканирования для библиотечного использования: фазы, счетчики и колбэк
+package scan
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Phase - этап работы сканера
+type Phase string
+
+const (
+	PhaseWalking   Phase = "walking"   // Обход файловой системы
+	PhaseGrouping  Phase = "grouping"  // Группировка кандидатов
+	PhaseHashing   Phase = "hashing"   // Чтение и хэширование кандидатов
+	PhaseVerifying Phase = "verifying" // Перепроверка fast_hash полным хэшем
+	PhaseDone      Phase = "done"      // Работа завершена
+)
+
+// progressInterval - как часто (не чаще) вызывается Config.OnProgress внутри одной фазы
+const progressInterval = 200 * time.Millisecond
+
+// Progress - снимок прогресса для Config.OnProgress
+type Progress struct {
+	Phase       Phase
+	FilesSeen   int64 // Найдено файлов при обходе
+	FilesHashed int64 // Обработано файлов в текущей фазе хэширования (включая ошибки)
+	BytesHashed int64 // Прочитано байт в текущей фазе хэширования: обработанные файлы и уже прочитанная часть тех, что читаются сейчас
+	BytesTotal  int64 // Всего байт к хэшированию (0, пока фаза хэширования не началась)
+	Errors      int64
+
+	// Готовность в процентах, 0-100: доля прочитанных байт текущей фазы хэширования
+	// (BytesHashed / BytesTotal). До хэширования - 0; если читать нечего (режим без хэша,
+	// нет кандидатов) - 100 сразу после группировки.
+	Percent float64
+}
+
+// progressState - счетчики прогресса сканера (атомики: их обновляют воркеры)
+type progressState struct {
+	phase       atomic.Value // Phase
+	filesHashed int64
+	bytesHashed int64
+	bytesTotal  int64
+	noHashing   int32 // 1 - кандидаты определены, а читать ничего не нужно (режим без хэша)
+
+	hashedTotal int64        // Байт, обработанных всеми фазами хэширования всех запусков (для метрик)
+	hashStarted atomic.Int64 // Начало текущей фазы хэширования, Unix-наносекунды
+
+	changed chan struct{} // Будит горутину колбэка при смене фазы (nil - колбэка нет)
+}
+
+// Progress возвращает текущий прогресс (безопасно для конкурентного чтения)
+func (s *Scanner) Progress() Progress {
+	phase, _ := s.progress.phase.Load().(Phase)
+	p := Progress{
+		Phase:       phase,
+		FilesSeen:   atomic.LoadInt64(&s.stats.TotalFiles),
+		FilesHashed: atomic.LoadInt64(&s.progress.filesHashed),
+		BytesHashed: atomic.LoadInt64(&s.progress.bytesHashed) + s.readingNow(),
+		BytesTotal:  atomic.LoadInt64(&s.progress.bytesTotal),
+		Errors:      atomic.LoadInt64(&s.stats.Errors),
+	}
+	p.Percent = percent(p, atomic.LoadInt32(&s.progress.noHashing) == 1)
+	return p
+}
+
+// percent считает готовность по байтам; noHashing - хэшировать нечего, работа почти сделана
+func percent(p Progress, noHashing bool) float64 {
+	switch {
+	case p.Phase == PhaseDone || noHashing:
+		return 100
+	case p.Phase != PhaseHashing && p.Phase != PhaseVerifying:
+		return 0
+	case p.BytesTotal <= 0:
+		return 100
+	}
+	return min(float64(p.BytesHashed)*100/float64(p.BytesTotal), 100)
+}
+
+// setPhase переключает фазу и просит немедленно сообщить о ней.
+// Если горутина колбэка занята, сигнал не копится: она все равно прочитает свежее состояние.
+func (s *Scanner) setPhase(phase Phase) {
+	s.progress.phase.Store(phase)
+	s.logger.Info("фаза сканирования", "phase", phase)
+	if s.progress.changed != nil {
+		select {
+		case s.progress.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// startHashPhase обнуляет счетчики хэширования и запоминает общий объем для новой фазы
+func (s *Scanner) startHashPhase(total int64) {
+	atomic.StoreInt32(&s.progress.noHashing, 0)
+	atomic.StoreInt64(&s.progress.filesHashed, 0)
+	atomic.StoreInt64(&s.progress.bytesHashed, 0)
+	atomic.StoreInt64(&s.progress.bytesTotal, total)
+	s.progress.hashStarted.Store(time.Now().UnixNano())
+}
+
+// addBytes засчитывает обработанные байты в текущую фазу и в общий счетчик
+func (p *progressState) addBytes(n int64) {
+	atomic.AddInt64(&p.bytesHashed, n)
+	atomic.AddInt64(&p.hashedTotal, n)
+}
+
+// startProgress запускает горутину, которая вызывает Config.OnProgress.
+// Колбэк вызывается только из нее, поэтому реализациям не нужны свои блокировки,
+// а воркеры лишь обновляют атомики и никогда не ждут колбэк: медленный колбэк
+// просто пропускает промежуточные состояния.
+// Возвращает функцию остановки: она выставляет PhaseDone, сообщает финальное состояние
+// и дожидается завершения горутины.
+func (s *Scanner) startProgress() (stop func()) {
+	if s.config.OnProgress == nil {
+		return func() { s.setPhase(PhaseDone) }
+	}
+	s.progress.changed = make(chan struct{}, 1)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.progress.changed:
+			case <-done:
+				s.config.OnProgress(s.Progress())
+				return
+			}
+			s.config.OnProgress(s.Progress())
+		}
+	}()
+
+	return func() {
+		s.progress.phase.Store(PhaseDone)
+		close(done)
+		<-finished
+		s.progress.changed = nil
+	}
+}
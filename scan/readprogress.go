@@ -0,0 +1,89 @@
+// Прогресс внутри одного файла: у многогигабайтного файла видно, сколько уже прочитано
+package scan
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync/atomic"
+)
+
+// fileProgress - сколько байт файла задачи уже прочитал воркер (Status.CurrentRead).
+// Свой на каждую задачу: чтение, брошенное по Config.FileTimeout, не портит счет следующего файла.
+type fileProgress struct {
+	size int64
+	read atomic.Int64
+}
+
+// set начинает счет заново с n байт (повтор чтения, продолжение с сохраненного места)
+func (p *fileProgress) set(n int64) {
+	if p != nil {
+		p.read.Store(n)
+	}
+}
+
+// countRead оборачивает файл так, чтобы прочитанные байты шли в progress. Это одно атомарное
+// сложение на буфер (io.Copy читает по 32 КБ) - рядом с чтением с диска его не видно.
+// Файл, хэш которого считает сервер (remoteHasher), не оборачивается: локально он не читается,
+// а обертка спрятала бы от computeHash эту возможность. nil progress - чтение не из воркера.
+func countRead(file fs.File, progress *fileProgress) fs.File {
+	if progress == nil {
+		return file
+	}
+	if _, remote := file.(remoteHasher); remote {
+		return file
+	}
+	c := countingFile{File: file, progress: progress}
+	// fast_hash читает края файла через ReaderAt: эту возможность надо сохранить
+	if ra, ok := file.(io.ReaderAt); ok {
+		return countingReaderAtFile{countingFile: c, ra: ra}
+	}
+	return c
+}
+
+// countingHash - hashFn, чтение которого видно в progress; каждая попытка считается с нуля
+func countingHash(hashFn hashFunc, progress *fileProgress) hashFunc {
+	if progress == nil {
+		return hashFn
+	}
+	return func(ctx context.Context, file fs.File) (string, error) {
+		progress.set(0)
+		return hashFn(ctx, countRead(file, progress))
+	}
+}
+
+// countingFile - файл, прочитанные байты которого складываются в fileProgress
+type countingFile struct {
+	fs.File
+	progress *fileProgress
+}
+
+func (f countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.progress.read.Add(int64(n))
+	return n, err
+}
+
+// countingReaderAtFile - то же для файлов с произвольным доступом
+type countingReaderAtFile struct {
+	countingFile
+	ra io.ReaderAt
+}
+
+func (f countingReaderAtFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.ra.ReadAt(p, off)
+	f.progress.read.Add(int64(n))
+	return n, err
+}
+
+// readingNow - сколько байт уже прочитано из файлов, которые воркеры читают прямо сейчас
+// (в Progress.BytesHashed они еще не вошли: файл засчитывается целиком, когда дочитан)
+func (s *Scanner) readingNow() int64 {
+	var n int64
+	for i := range s.reading {
+		if p := s.reading[i].Load(); p != nil {
+			n += min(p.read.Load(), p.size)
+		}
+	}
+	return n
+}
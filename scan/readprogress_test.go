@@ -0,0 +1,108 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// scriptedFile отдает по Read заранее заданные порции; перед порцией с gate ждет закрытия gate
+type scriptedFile struct {
+	fs.File
+	reads  []int
+	gates  map[int]chan struct{}
+	before map[int]chan struct{} // Закрывается, когда Read с этим номером начался
+	call   int
+}
+
+func (f *scriptedFile) Read(p []byte) (int, error) {
+	call := f.call
+	f.call++
+	if ch, ok := f.before[call]; ok {
+		close(ch)
+	}
+	if gate, ok := f.gates[call]; ok {
+		<-gate
+	}
+	if call >= len(f.reads) {
+		return 0, io.EOF
+	}
+	return min(f.reads[call], len(p)), nil
+}
+
+// scriptedFS - fstest.MapFS, в которой файлы из files читаются по сценарию
+type scriptedFS struct {
+	fstest.MapFS
+	files map[string]*scriptedFile
+}
+
+func (f scriptedFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if script, ok := f.files[name]; ok && err == nil {
+		script.File = file
+		return script, nil
+	}
+	return file, err
+}
+
+// Чтение, брошенное по FileTimeout, дочитывает уже после того, как воркер взял следующий
+// файл: эти байты не должны попасть в счет следующего файла
+func TestAbandonedReadDoesNotLeakProgress(t *testing.T) {
+	const size = 4096
+	releaseSlow, releaseNext := make(chan struct{}), make(chan struct{})
+	nextBlocked := make(chan struct{})
+	fsys := scriptedFS{
+		MapFS: fstest.MapFS{
+			"a-slow": {Data: make([]byte, size)},
+			"b-next": {Data: make([]byte, size)},
+		},
+		files: map[string]*scriptedFile{
+			// 100 байт, зависание до releaseSlow, потом еще 2000 - уже после предела времени
+			"a-slow": {reads: []int{100, 2000}, gates: map[int]chan struct{}{1: releaseSlow}},
+			"b-next": {
+				reads:  []int{10, size - 10},
+				gates:  map[int]chan struct{}{1: releaseNext},
+				before: map[int]chan struct{}{1: nextBlocked},
+			},
+		},
+	}
+	cfg := DefaultConfig(".")
+	cfg.FS = fsys
+	cfg.Workers = 1
+	cfg.FileTimeout = 300 * time.Millisecond
+	s, err := NewScanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan *Result)
+	go func() {
+		result, err := s.Run(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+
+	<-nextBlocked // Воркер бросил a-slow по пределу и прочитал 10 байт b-next
+	close(releaseSlow)
+	for atomic.LoadInt64(&s.stuckReads) > 0 { // Брошенное чтение досчитало свои 2000 байт
+		time.Sleep(time.Millisecond)
+	}
+	st := s.Status()
+	if st.Current[0] != "b-next" || st.CurrentRead[0] != 10 {
+		t.Errorf("воркер читает %q, прочитано %d, want b-next и 10", st.Current[0], st.CurrentRead[0])
+	}
+	if st.BytesHashed != 10 {
+		t.Errorf("BytesHashed = %d, want 10 (только b-next)", st.BytesHashed)
+	}
+	close(releaseNext)
+
+	result := <-done
+	if result.Stats.TimedOutFiles != 1 {
+		t.Errorf("TimedOutFiles = %d, want 1", result.Stats.TimedOutFiles)
+	}
+}
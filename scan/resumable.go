@@ -0,0 +1,230 @@
+// Хэш, который можно продолжить с середины файла: после обрыва связи и после перезапуска
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointEvery - как часто (по прочитанным байтам) состояние хэша сохраняется в Config.CheckpointDir
+const checkpointEvery = 64 << 20
+
+// ResumableHash - хэш с числом уже прочитанных байт. Его состояние можно сохранить посреди
+// файла (MarshalBinary) и потом продолжить с того же места (UnmarshalBinary): итоговый
+// хэш будет тем же, что и при чтении за один раз. Годится любой hash.Hash, умеющий
+// encoding.BinaryMarshaler (SHA-256, SHA-512, MD5, CRC-32 и другие из стандартной библиотеки).
+type ResumableHash struct {
+	hash   hash.Hash
+	offset int64
+}
+
+// NewResumableHash оборачивает h; ошибка - h не умеет сохранять состояние
+func NewResumableHash(h hash.Hash) (*ResumableHash, error) {
+	if _, ok := h.(encoding.BinaryMarshaler); !ok {
+		return nil, fmt.Errorf("хэш %T не умеет сохранять состояние (encoding.BinaryMarshaler)", h)
+	}
+	if _, ok := h.(encoding.BinaryUnmarshaler); !ok {
+		return nil, fmt.Errorf("хэш %T не умеет восстанавливать состояние (encoding.BinaryUnmarshaler)", h)
+	}
+	return &ResumableHash{hash: h}, nil
+}
+
+// Write добавляет данные к хэшу
+func (r *ResumableHash) Write(p []byte) (int, error) {
+	n, err := r.hash.Write(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Offset возвращает, сколько байт уже прочитано: с этого места файл читается дальше
+func (r *ResumableHash) Offset() int64 {
+	return r.offset
+}
+
+// Sum возвращает хэш прочитанного в hex
+func (r *ResumableHash) Sum() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// MarshalBinary сохраняет смещение (8 байт) и состояние хэша
+func (r *ResumableHash) MarshalBinary() ([]byte, error) {
+	state, err := r.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.BigEndian.AppendUint64(nil, uint64(r.offset)), state...), nil
+}
+
+// UnmarshalBinary восстанавливает смещение и состояние, сохраненные MarshalBinary.
+// Хэш должен быть того же алгоритма, иначе вернется ошибка.
+func (r *ResumableHash) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("состояние хэша короче 8 байт")
+	}
+	if err := r.hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(data[8:]); err != nil {
+		return err
+	}
+	r.offset = int64(binary.BigEndian.Uint64(data))
+	return nil
+}
+
+// checkpoint - сохраненное состояние хэша файла в Config.CheckpointDir
+type checkpoint struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	State   []byte    `json:"state"` // ResumableHash.MarshalBinary
+}
+
+// checkpointPath возвращает файл состояния для path
+func (s *Scanner) checkpointPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(s.config.CheckpointDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadCheckpoint продолжает rh с сохраненного места, если файл с тех пор не менялся
+func (s *Scanner) loadCheckpoint(path string, info os.FileInfo, rh *ResumableHash) {
+	data, err := os.ReadFile(s.checkpointPath(path))
+	if err != nil {
+		return
+	}
+	var c checkpoint
+	if json.Unmarshal(data, &c) != nil || c.Path != path || c.Size != info.Size() || !c.ModTime.Equal(info.ModTime()) {
+		return // Чужое или устаревшее состояние: файл читается с начала
+	}
+	if err := rh.UnmarshalBinary(c.State); err != nil || rh.Offset() > c.Size {
+		rh.hash.Reset()
+		rh.offset = 0
+		return
+	}
+	s.logger.Debug("хэш продолжается с сохраненного места", "path", path, "offset", rh.Offset())
+}
+
+// saveCheckpoint сохраняет состояние rh. Ошибка записи не мешает хэшированию: в худшем
+// случае файл после перезапуска прочитается с начала.
+func (s *Scanner) saveCheckpoint(path string, info os.FileInfo, rh *ResumableHash) {
+	state, err := rh.MarshalBinary()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(checkpoint{Path: path, Size: info.Size(), ModTime: info.ModTime(), State: state})
+	if err != nil {
+		return
+	}
+	target := s.checkpointPath(path)
+	tmp := target + ".tmp"
+	if err := os.MkdirAll(s.config.CheckpointDir, 0o700); err != nil {
+		s.logger.Warn("не удалось сохранить состояние хэша", "path", path, "err", err)
+		return
+	}
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		s.logger.Warn("не удалось сохранить состояние хэша", "path", path, "err", err)
+		return
+	}
+	os.Rename(tmp, target)
+}
+
+// resumableHashFile считает полный хэш файла, сохраняя состояние в Config.CheckpointDir
+// каждые checkpointEvery байт и при ошибке чтения. Повтор после временной ошибки
+// (Config.ReadRetries) и следующий запуск после остановки продолжают с сохраненного места.
+func (s *Scanner) resumableHashFile(path string, size int64, progress *fileProgress) (string, error) {
+	newHash := s.newHash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	rh, err := NewResumableHash(newHash())
+	if err != nil { // Алгоритм WithHasher не умеет сохранять состояние: обычное чтение
+		return s.hashFile(path, size, countingHash(s.contentHash(), progress))
+	}
+	loaded := false
+	return s.withRetries(path, func() (string, error) {
+		file, err := s.openFile(path)
+		if err != nil {
+			return "", &ScanError{Path: path, Op: OpOpen, Err: err}
+		}
+		defer file.Close()
+		before, err := file.Stat()
+		if err != nil {
+			return "", &ScanError{Path: path, Op: OpOpen, Err: err}
+		}
+		if before.Size() != size {
+			return "", &ScanError{Path: path, Op: OpChanged, Err: ErrFileChanged}
+		}
+		if _, remote := file.(remoteHasher); remote { // Хэш считает сервер, продолжать нечего
+			return computeHash(s.ctx, file)
+		}
+		if !loaded {
+			s.loadCheckpoint(path, before, rh)
+			loaded = true
+		}
+		if err := skipBytes(file, rh.Offset()); err != nil {
+			return "", err
+		}
+
+		progress.set(rh.Offset())
+		r := ctxReader{ctx: s.ctx, r: countRead(s.throttle(s.ctx, file), progress)}
+		buf := make([]byte, 1<<20)
+		next := rh.Offset() + checkpointEvery
+		for {
+			n, err := r.Read(buf)
+			rh.Write(buf[:n])
+			if rh.Offset() >= next {
+				s.saveCheckpoint(path, before, rh)
+				next += checkpointEvery
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if rh.Offset() > 0 {
+					s.saveCheckpoint(path, before, rh)
+				}
+				return "", err
+			}
+		}
+		if rh.Offset() != size || changedSince(file, before) {
+			os.Remove(s.checkpointPath(path))
+			return "", &ScanError{Path: path, Op: OpChanged, Err: ErrFileChanged}
+		}
+		os.Remove(s.checkpointPath(path))
+		return rh.Sum(), nil
+	})
+}
+
+// CheckpointInfo - сохраненное состояние хэша файла в Config.CheckpointDir
+type CheckpointInfo struct {
+	File   string // Файл состояния
+	Path   string // Файл, хэш которого сохранен
+	Size   int64  // Размер файла при сохранении
+	Offset int64  // Сколько байт уже прочитано
+}
+
+// ListCheckpoints возвращает состояния хэшей, сохраненные в dir (например, чтобы показать
+// или удалить их). Нечитаемые файлы состояния возвращаются с пустым Path.
+func ListCheckpoints(dir string) ([]CheckpointInfo, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]CheckpointInfo, 0, len(names))
+	for _, name := range names {
+		info := CheckpointInfo{File: name}
+		var c checkpoint
+		if data, err := os.ReadFile(name); err == nil && json.Unmarshal(data, &c) == nil && len(c.State) >= 8 {
+			info.Path, info.Size = c.Path, c.Size
+			info.Offset = int64(binary.BigEndian.Uint64(c.State))
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestResumableHashMidStream(t *testing.T) {
+	data := strings.Repeat("0123456789", 10000)
+	first, err := NewResumableHash(sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Write([]byte(data[:33333]))
+	state, err := first.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := NewResumableHash(sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.UnmarshalBinary(state); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Offset() != 33333 {
+		t.Fatalf("Offset = %d, want 33333", resumed.Offset())
+	}
+	resumed.Write([]byte(data[resumed.Offset():]))
+	if got, want := resumed.Sum(), sha256Hex(data); got != want {
+		t.Errorf("продолженный хэш %s, want %s", got, want)
+	}
+}
+
+func TestResumableHashFileFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.bin")
+	data := strings.Repeat("a", 5000) + strings.Repeat("b", 5000)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScanner(t, dir, func(cfg *Config) { cfg.CheckpointDir = filepath.Join(dir, "checkpoints") })
+
+	// checkpoint сохраняет для файла состояние хэша, уже прочитавшего prefix
+	checkpoint := func(prefix string) {
+		t.Helper()
+		rh, err := NewResumableHash(sha256.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rh.Write([]byte(prefix))
+		s.saveCheckpoint(path, info, rh)
+		if _, err := os.Stat(s.checkpointPath(path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Состояние от настоящего начала файла: тот же хэш, что и при чтении целиком
+	checkpoint(data[:5000])
+	hash, err := s.resumableHashFile(path, info.Size(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256Hex(data); hash != want {
+		t.Errorf("хэш с продолжением %s, want %s", hash, want)
+	}
+	if _, err := os.Stat(s.checkpointPath(path)); !os.IsNotExist(err) {
+		t.Errorf("состояние не удалено после успешного хэширования: %v", err)
+	}
+
+	// Состояние от другого начала: хэш выдает, что первая половина с диска не читалась
+	checkpoint(strings.Repeat("c", 5000))
+	hash, err = s.resumableHashFile(path, info.Size(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256Hex(strings.Repeat("c", 5000) + data[5000:]); hash != want {
+		t.Errorf("чтение не продолжилось с сохраненного места: %s, want %s", hash, want)
+	}
+}
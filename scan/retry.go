@@ -0,0 +1,83 @@
+// Повтор чтения файла при временных ошибках (сетевые диски NFS/SMB)
+package scan
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// retryBackoff - пауза перед первым повтором; каждый следующий ждет вдвое дольше
+const retryBackoff = 100 * time.Millisecond
+
+// maxRunRetries - сколько повторов чтения допускается за весь запуск. Если отвалилась вся
+// шара, каждый файл упал бы по ReadRetries раз с паузами; после этого предела файлы с
+// временной ошибкой сразу считаются ошибочными.
+const maxRunRetries = 1000
+
+// transientErrnos - системные ошибки, после которых чтение стоит повторить: сбои ввода-вывода
+// и сети между клиентом и сетевым диском (SMB, NFS, SSHFS)
+var transientErrnos = []error{
+	syscall.EIO,
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.ETIMEDOUT,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.ECONNREFUSED,
+	syscall.ENETDOWN,
+	syscall.ENETUNREACH,
+	syscall.ENETRESET,
+	syscall.EHOSTUNREACH,
+	syscall.EPIPE,
+}
+
+// isTransientReadError сообщает, стоит ли повторить чтение после ошибки.
+// Временными считаются transientErrnos и таймауты (у syscall.Errno это в том числе EAGAIN
+// и ETIMEDOUT): на сетевых дисках они часто проходят со второй попытки.
+// Отсутствие файла и нехватка прав не пройдут сами - их не повторяем.
+func isTransientReadError(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// withRetries вызывает read, пока он не завершится успешно, ошибка не окажется постоянной
+// или не кончатся повторы: Config.ReadRetries сверх первой попытки на файл и maxRunRetries
+// на запуск. read каждый раз открывает файл заново и считает хэш с начала.
+// Повторы считаются в Stats.ReadRetries, а файлы, не прочитанные и после них, - в Stats.RetryFailures.
+func (s *Scanner) withRetries(path string, read func() (string, error)) (string, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		result, err := read()
+		if err == nil || !isTransientReadError(err) {
+			return result, err
+		}
+		if attempt >= s.config.ReadRetries || atomic.LoadInt64(&s.stats.ReadRetries) >= maxRunRetries {
+			if attempt > 0 {
+				atomic.AddInt64(&s.stats.RetryFailures, 1)
+			}
+			return result, err
+		}
+		atomic.AddInt64(&s.stats.ReadRetries, 1)
+		s.logger.Debug("повтор чтения", "path", path, "attempt", attempt+1, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return "", s.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
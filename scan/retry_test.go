@@ -0,0 +1,44 @@
+package scan
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestWithRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    []error // Ошибки попыток по порядку, дальше - успех
+		wantErr     error
+		wantCalls   int
+		wantRetries int64
+	}{
+		{"два сбоя и успех", []error{syscall.EIO, fmt.Errorf("read: %w", syscall.ETIMEDOUT)}, nil, 3, 2},
+		{"сбои дольше повторов", []error{syscall.EIO, syscall.EIO, syscall.EIO}, syscall.EIO, 3, 2},
+		{"постоянная ошибка", []error{fs.ErrPermission}, fs.ErrPermission, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, t.TempDir(), func(cfg *Config) { cfg.ReadRetries = 2 })
+			calls := 0
+			hash, err := s.withRetries("file", func() (string, error) {
+				calls++
+				if calls <= len(tt.failures) {
+					return "", tt.failures[calls-1]
+				}
+				return "hash", nil
+			})
+			if err != tt.wantErr || (err == nil && hash != "hash") {
+				t.Errorf("withRetries = %q, %v, want err %v", hash, err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("попыток %d, want %d", calls, tt.wantCalls)
+			}
+			if s.stats.ReadRetries != tt.wantRetries {
+				t.Errorf("Stats.ReadRetries = %d, want %d", s.stats.ReadRetries, tt.wantRetries)
+			}
+		})
+	}
+}
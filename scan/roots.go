@@ -0,0 +1,85 @@
+// Несколько корней сканирования: устранение пересечений
+package scan
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RootList возвращает корни сканирования: Roots, а если он пуст - DirPath.
+// Без путей, но с манифестами сравниваются только манифесты, без обхода диска.
+func (c Config) RootList() []string {
+	if len(c.Roots) > 0 {
+		return c.Roots
+	}
+	if c.DirPath == "" && len(c.Manifests) > 0 {
+		return nil
+	}
+	return []string{c.DirPath}
+}
+
+// normalizeRoots убирает повторы и корни, вложенные в другие корни,
+// чтобы один и тот же файл не попал в результат дважды.
+// Сравниваются абсолютные пути с раскрытыми симлинками, а возвращаются корни в исходном написании.
+func normalizeRoots(roots []string) []string {
+	resolved := make([]string, len(roots))
+	for i, root := range roots {
+		resolved[i] = resolvePath(root)
+	}
+
+	var result []string
+	for i, root := range roots {
+		covered := false
+		for j := range roots {
+			if i == j {
+				continue
+			}
+			// Из двух одинаковых корней оставляем первый
+			if resolved[i] == resolved[j] {
+				covered = j < i
+			} else {
+				covered = isWithin(resolved[j], resolved[i])
+			}
+			if covered {
+				break
+			}
+		}
+		if !covered {
+			result = append(result, root)
+		}
+	}
+	return result
+}
+
+// isScanRoot проверяет, что path - один из корней сканирования
+func isScanRoot(path string, roots []string) bool {
+	resolved := resolvePath(path)
+	for _, root := range roots {
+		if resolvePath(root) == resolved {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath приводит путь к абсолютному виду с раскрытыми симлинками (насколько получится)
+func resolvePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// isWithin сообщает, лежит ли child внутри parent (по компонентам пути, а не по префиксу строки:
+// /data/a2 не лежит внутри /data/a)
+func isWithin(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,34 @@
+// Поиск дубликатов в готовом списке файлов, без обхода (для бенчмарков и своих источников файлов)
+package scan
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+)
+
+// RunFiles ищет дубликаты среди переданных файлов так же, как Run, но без обхода корней:
+// группировка и хэширование работают со списком как есть. Так алгоритм можно измерять
+// отдельно от задержек файловой системы, например, на fstest.MapFS через Config.FS.
+//
+// Содержимое читается по FileInfo.Path через Config.FS (или из файловой системы ОС),
+// поэтому в files достаточно Path, Name и Size. Сам срез не меняется: хэши пишутся в копию.
+// Фильтры и сортировка результата применяются, как в Run; корни (Config.DirPath, Config.Roots)
+// не обходятся, а для CrossRoot и RedundantIn нужно заполнить FileInfo.Root.
+func (s *Scanner) RunFiles(files []FileInfo) ([][]FileInfo, error) {
+	defer s.startProgress()()
+	s.begin(context.Background())
+
+	files = slices.Clone(files)
+	atomic.AddInt64(&s.stats.TotalFiles, int64(len(files)))
+	candidates, err := s.groupFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups [][]FileInfo
+	if err := s.processCandidates(candidates, func(group []FileInfo) { groups = append(groups, group) }); err != nil {
+		return nil, err
+	}
+	return s.finalize(groups), s.interruptErr()
+}
@@ -0,0 +1,699 @@
+// Бакет S3 как файловая система: обход через ListObjectsV2, чтение через GET с Range
+package scan
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3Scheme - приставка корня в бакете S3: "s3://bucket/prefix"
+const s3Scheme = "s3://"
+
+// Значения S3Config по умолчанию
+const (
+	DefaultS3Region      = "us-east-1"
+	DefaultS3Retries     = 5  // Повторов запроса при SlowDown, 5xx и сетевых ошибках
+	DefaultS3Connections = 32 // Одновременных соединений с сервером
+)
+
+// s3MaxBackoff - наибольшая пауза между повторами запроса
+const s3MaxBackoff = 10 * time.Second
+
+// emptySHA256 - SHA-256 пустого тела запроса (у всех запросов S3FS тела нет)
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config - доступ к S3 или совместимому хранилищу (MinIO, Ceph...).
+// Без ключей запросы не подписываются: так читаются публичные бакеты.
+type S3Config struct {
+	Endpoint     string // Адрес сервера, например http://localhost:9000 (пусто - AWS по Region)
+	Region       string // Регион для подписи (пусто - DefaultS3Region)
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // Временные ключи (STS)
+	PathStyle    bool   // Бакет в пути (endpoint/bucket/key), а не в имени хоста; с Endpoint - всегда
+
+	Retries     int  // Повторов запроса при перегрузке и временных ошибках (0 - DefaultS3Retries, <0 - без повторов)
+	Connections int  // Одновременных соединений (0 - DefaultS3Connections)
+	IgnoreETags bool // Не сравнивать ETag: при SSE-KMS и SSE-C он не MD5 содержимого
+}
+
+// S3ConfigFromEnv берет настройки из тех же переменных окружения, что и AWS CLI:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION
+// (или AWS_DEFAULT_REGION) и AWS_ENDPOINT_URL_S3 (или AWS_ENDPOINT_URL).
+func S3ConfigFromEnv() S3Config {
+	cfg := S3Config{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       os.Getenv("AWS_REGION"),
+		Endpoint:     os.Getenv("AWS_ENDPOINT_URL_S3"),
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	return cfg
+}
+
+// S3Object - сведения об объекте из листинга (FileInfo.Sys() у файлов S3FS)
+type S3Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string // Как вернул сервер, без кавычек
+
+	md5 string // MD5 содержимого из ETag, если ему можно верить
+}
+
+// MD5 возвращает MD5 содержимого, если ETag - это он: у объектов, загруженных одним запросом,
+// ETag - 32 шестнадцатеричные цифры, а у составных ("...-N") - хэш хэшей частей.
+// Пусто, если ETag не MD5 или S3Config.IgnoreETags.
+func (o *S3Object) MD5() string {
+	return o.md5
+}
+
+// S3FS - бакет S3 как fs.FS: ключи - пути через "/", общие префиксы ключей - директории.
+// Размеры и ETag берутся из листинга, а файл читается только когда его читают, запросами
+// GET с Range (ReadAt читает лишь нужный кусок - так fast_hash не качает объект целиком).
+// Чтение идет с If-Match по ETag: объект, перезаписанный после обхода, дает ErrFileChanged.
+type S3FS struct {
+	cfg     S3Config
+	bucket  string
+	base    *url.URL // Адрес бакета: https://bucket.s3.region.amazonaws.com или endpoint/bucket
+	client  *http.Client
+	objects sync.Map // Ключ -> *S3Object из листинга: Open не делает лишний HEAD
+}
+
+// NewS3FS создает файловую систему бакета
+func NewS3FS(cfg S3Config, bucket string) (*S3FS, error) {
+	if bucket == "" {
+		return nil, errors.New("S3: не задан бакет")
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultS3Region
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = DefaultS3Retries
+	}
+	if cfg.Connections <= 0 {
+		cfg.Connections = DefaultS3Connections
+	}
+	if (cfg.AccessKey == "") != (cfg.SecretKey == "") {
+		return nil, errors.New("S3: нужны оба ключа, AccessKey и SecretKey, или ни одного")
+	}
+
+	var base *url.URL
+	var err error
+	switch {
+	case cfg.Endpoint != "":
+		base, err = url.Parse(strings.TrimSuffix(cfg.Endpoint, "/") + "/" + bucket)
+	case cfg.PathStyle || strings.Contains(bucket, "."): // Точки в имени ломают сертификат *.s3...
+		base, err = url.Parse(fmt.Sprintf("https://s3.%s.amazonaws.com/%s", cfg.Region, bucket))
+	default:
+		base, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, cfg.Region))
+	}
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("S3: некорректный адрес сервера %q", cfg.Endpoint)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = cfg.Connections
+	transport.MaxIdleConnsPerHost = cfg.Connections
+	transport.ResponseHeaderTimeout = time.Minute // Общий таймаут оборвал бы чтение больших объектов
+	return &S3FS{cfg: cfg, bucket: bucket, base: base, client: &http.Client{Transport: transport}}, nil
+}
+
+// parseS3Root разбирает корень "s3://bucket/prefix" на бакет и путь внутри S3FS
+func parseS3Root(root string) (bucket, dir string, ok bool) {
+	rest, ok := strings.CutPrefix(root, s3Scheme)
+	if !ok {
+		return "", "", false
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		prefix = "."
+	}
+	return bucket, prefix, true
+}
+
+// s3Roots проверяет, заданы ли корни в S3, и если да - открывает бакет.
+// Все корни должны быть в одном бакете: смешивать их с локальными путями нельзя.
+// nil - корни обычные, пути ОС.
+func s3Roots(roots []string, cfg *S3Config) (*S3FS, []string, error) {
+	var bucket string
+	var dirs []string
+	for i, root := range roots {
+		b, dir, ok := parseS3Root(root)
+		if i > 0 && (ok != (bucket != "") || (ok && b != bucket)) { // Первый корень решает, где все остальные
+			return nil, nil, fmt.Errorf("корень %s: все корни должны быть в одном бакете S3 или все на диске", root)
+		}
+		if ok {
+			bucket = b
+			dirs = append(dirs, dir)
+		}
+	}
+	if bucket == "" {
+		return nil, nil, nil
+	}
+	s3cfg := S3ConfigFromEnv()
+	if cfg != nil {
+		s3cfg = *cfg
+	}
+	fsys, err := NewS3FS(s3cfg, bucket)
+	return fsys, dirs, err
+}
+
+// etagMD5 возвращает MD5 содержимого объекта S3 из листинга, если он известен
+func etagMD5(info fs.FileInfo) string {
+	if o, ok := info.Sys().(*S3Object); ok {
+		return o.MD5()
+	}
+	return ""
+}
+
+// splitByETag делит группы кандидатов по MD5 из ETag: объекты S3 с разным MD5 заведомо
+// разные, и читать их незачем. Группа делится, только если MD5 известен у всех ее файлов -
+// у составного объекта содержимое может совпасть с любым. Файлы, оставшиеся в одиночестве,
+// уходят в уникальные.
+func (s *Scanner) splitByETag(groups [][]FileInfo) [][]FileInfo {
+	var result [][]FileInfo
+	for _, group := range groups {
+		byMD5 := make(map[string][]FileInfo)
+		var order []string
+		for _, f := range group {
+			if f.etag == "" {
+				byMD5 = nil
+				break
+			}
+			if _, ok := byMD5[f.etag]; !ok {
+				order = append(order, f.etag)
+			}
+			byMD5[f.etag] = append(byMD5[f.etag], f)
+		}
+		if byMD5 == nil {
+			result = append(result, group)
+			continue
+		}
+		for _, md5 := range order {
+			same := byMD5[md5]
+			if len(same) > 1 {
+				result = append(result, same)
+			} else if s.config.ReportUnique {
+				s.unique = append(s.unique, same[0])
+			}
+		}
+	}
+	return result
+}
+
+// Open открывает объект или "директорию" (общий префикс ключей)
+func (fsys *S3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		return &s3Dir{fsys: fsys, name: name, info: info}, nil
+	}
+	return &s3File{fsys: fsys, obj: info.Sys().(*S3Object), info: info}, nil
+}
+
+// Stat возвращает сведения об объекте или директории
+func (fsys *S3FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// stat ищет объект сначала в листинге, потом запросом HEAD; нет объекта - проверяет,
+// есть ли ключи с префиксом name/ (тогда это директория)
+func (fsys *S3FS) stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return s3DirInfo{name: "."}, nil
+	}
+	if o, ok := fsys.objects.Load(name); ok {
+		return s3FileInfo{o.(*S3Object)}, nil
+	}
+	resp, err := fsys.do(http.MethodHead, name, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+		o := fsys.object(name, resp.ContentLength, resp.Header.Get("Last-Modified"), resp.Header.Get("ETag"))
+		return s3FileInfo{o}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	list, err := fsys.list(name+"/", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Contents) == 0 && len(list.CommonPrefixes) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return s3DirInfo{name: path.Base(name)}, nil
+}
+
+// ReadDir читает "директорию" листингом с разделителем "/": объекты - файлы,
+// общие префиксы - поддиректории. Ключи, которые не являются путями fs.FS
+// ("a//b", заглушки директорий "a/"), пропускаются.
+func (fsys *S3FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	var entries []fs.DirEntry
+	token := ""
+	for {
+		list, err := fsys.list(prefix, token, 0)
+		if err != nil {
+			return entries, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, c := range list.Contents {
+			child := strings.TrimPrefix(c.Key, prefix)
+			if child == "" || !fs.ValidPath(c.Key) {
+				continue
+			}
+			o := fsys.object(c.Key, c.Size, "", c.ETag)
+			o.LastModified = c.LastModified
+			entries = append(entries, fs.FileInfoToDirEntry(s3FileInfo{o}))
+		}
+		for _, p := range list.CommonPrefixes {
+			child := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/")
+			if child == "" || !fs.ValidPath(strings.TrimSuffix(p.Prefix, "/")) {
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(s3DirInfo{name: child}))
+		}
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			break
+		}
+		token = list.NextContinuationToken
+	}
+	if len(entries) == 0 && name != "." {
+		if _, err := fsys.stat(name); err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// object запоминает сведения об объекте для Open
+func (fsys *S3FS) object(key string, size int64, lastModified, etag string) *S3Object {
+	o := &S3Object{Key: key, Size: size, ETag: strings.Trim(etag, `"`)}
+	if t, err := http.ParseTime(lastModified); err == nil {
+		o.LastModified = t
+	}
+	if !fsys.cfg.IgnoreETags && len(o.ETag) == 32 {
+		if _, err := hex.DecodeString(o.ETag); err == nil {
+			o.md5 = strings.ToLower(o.ETag)
+		}
+	}
+	fsys.objects.Store(key, o)
+	return o
+}
+
+// s3List - ответ ListObjectsV2
+type s3List struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+// list запрашивает одну страницу листинга ключей с префиксом prefix
+func (fsys *S3FS) list(prefix, token string, maxKeys int) (*s3List, error) {
+	query := url.Values{"list-type": {"2"}, "delimiter": {"/"}, "prefix": {prefix}}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+	resp, err := fsys.do(http.MethodGet, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var list s3List
+	if err := xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("S3: ответ ListObjectsV2: %w", err)
+	}
+	return &list, nil
+}
+
+// s3Error - ошибка в ответе S3
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("S3: %s: %s", e.Code, e.Message)
+}
+
+// Is сводит ошибки S3 к ошибкам fs и сканера
+func (e *s3Error) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Status == http.StatusNotFound
+	case fs.ErrPermission:
+		return e.Status == http.StatusForbidden
+	case ErrFileChanged:
+		return e.Status == http.StatusPreconditionFailed
+	}
+	return false
+}
+
+// retryable сообщает, стоит ли повторить запрос: перегрузка (SlowDown, 429) и сбои сервера
+func (e *s3Error) retryable() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// do выполняет подписанный запрос к ключу key (пусто - к бакету) и повторяет его при
+// перегрузке, сбоях сервера и сетевых ошибках с экспоненциальной паузой и разбросом.
+// Ответ не 2xx превращается в *s3Error.
+func (fsys *S3FS) do(method, key string, query url.Values, header http.Header) (*http.Response, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := fsys.send(method, key, query, header)
+		if err == nil && resp.StatusCode/100 == 2 {
+			return resp, nil
+		}
+		if err == nil {
+			err = readS3Error(resp)
+		}
+		var s3err *s3Error
+		if attempt >= fsys.cfg.Retries || (errors.As(err, &s3err) && !s3err.retryable()) {
+			return nil, err
+		}
+		// Полный разброс: клиенты, которых притормозили одновременно, не вернутся все разом
+		time.Sleep(time.Duration(rand.Int64N(int64(backoff))) + time.Millisecond)
+		backoff = min(backoff*2, s3MaxBackoff)
+	}
+}
+
+// readS3Error читает ошибку из ответа и закрывает его
+func readS3Error(resp *http.Response) error {
+	defer resp.Body.Close()
+	e := &s3Error{Status: resp.StatusCode}
+	var body struct {
+		Code    string
+		Message string
+	}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil && xml.Unmarshal(data, &body) == nil {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+// send собирает, подписывает и отправляет один запрос
+func (fsys *S3FS) send(method, key string, query url.Values, header http.Header) (*http.Response, error) {
+	u := *fsys.base
+	basePath := strings.TrimSuffix(u.Path, "/")
+	u.Path = basePath + "/" + key
+	u.RawPath = s3Escape(basePath, false) + "/" + s3Escape(key, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(context.Background(), method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	// Иначе http сам попросит gzip и распакует ответ: смещения Range перестанут совпадать с телом
+	req.Header.Set("Accept-Encoding", "identity")
+	fsys.sign(req, time.Now().UTC())
+	return fsys.client.Do(req)
+}
+
+// sign подписывает запрос по AWS Signature Version 4 (без ключей - не подписывает)
+func (fsys *S3FS) sign(req *http.Request, now time.Time) {
+	if fsys.cfg.AccessKey == "" {
+		return
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if fsys.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", fsys.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, emptySHA256,
+	}, "\n")
+	scope := date + "/" + fsys.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+fsys.cfg.SecretKey), date)
+	for _, part := range []string{fsys.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		fsys.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape кодирует строку для подписи: все, кроме A-Z a-z 0-9 - _ . ~ (и "/" в пути)
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery кодирует параметры, как того требует подпись: по порядку имен
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3FileInfo - объект S3 как fs.FileInfo
+type s3FileInfo struct{ obj *S3Object }
+
+func (fi s3FileInfo) Name() string       { return path.Base(fi.obj.Key) }
+func (fi s3FileInfo) Size() int64        { return fi.obj.Size }
+func (fi s3FileInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi s3FileInfo) ModTime() time.Time { return fi.obj.LastModified }
+func (fi s3FileInfo) IsDir() bool        { return false }
+func (fi s3FileInfo) Sys() any           { return fi.obj }
+
+// s3DirInfo - общий префикс ключей как директория
+type s3DirInfo struct{ name string }
+
+func (di s3DirInfo) Name() string       { return di.name }
+func (di s3DirInfo) Size() int64        { return 0 }
+func (di s3DirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (di s3DirInfo) ModTime() time.Time { return time.Time{} }
+func (di s3DirInfo) IsDir() bool        { return true }
+func (di s3DirInfo) Sys() any           { return nil }
+
+// s3Dir - открытая директория S3FS
+type s3Dir struct {
+	fsys    *S3FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *s3Dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *s3Dir) Close() error               { return nil }
+
+func (d *s3Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("это директория")}
+}
+
+// ReadDir нужен fs.WalkDir только для FS без ReadDirFS; поддержан по контракту fs.ReadDirFile
+func (d *s3Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// s3File - открытый объект: последовательное чтение одним GET с Range от текущего места,
+// ReadAt - отдельным GET на каждый кусок
+type s3File struct {
+	fsys   *S3FS
+	obj    *S3Object
+	info   fs.FileInfo
+	offset int64
+	body   io.ReadCloser // Текущий ответ последовательного чтения, nil - еще не запрошен
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.obj.Size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		body, err := f.get(f.offset, f.obj.Size-1)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.obj.Size {
+		err = io.ErrUnexpectedEOF // Соединение оборвалось раньше конца объекта
+	}
+	return n, err
+}
+
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.obj.Size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), f.obj.Size)
+	body, err := f.get(off, end-1)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:end-off])
+	if err == nil && end-off < int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.obj.Size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.obj.Key, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *s3File) Close() error {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	return nil
+}
+
+// get запрашивает байты объекта с first по last включительно
+func (f *s3File) get(first, last int64) (io.ReadCloser, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", first, last)}}
+	if f.obj.ETag != "" {
+		header.Set("If-Match", `"`+f.obj.ETag+`"`)
+	}
+	resp, err := f.fsys.do(http.MethodGet, f.obj.Key, nil, header)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: f.obj.Key, Err: err}
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 - бакет в памяти: ListObjectsV2 с разделителем "/", HEAD и GET с Range и If-Match
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]string // Ключ -> содержимое
+	etags    map[string]string // Ключ -> ETag, если он не MD5 (составная загрузка)
+	gets     map[string]int    // Ключ -> число GET
+	slowDown int               // Столько первых запросов получат 503 SlowDown
+}
+
+func newFakeS3(t *testing.T, bucket string, objects map[string]string) (*fakeS3, *S3Config) {
+	t.Helper()
+	f := &fakeS3{objects: objects, etags: make(map[string]string), gets: make(map[string]int)}
+	server := httptest.NewServer(http.StripPrefix("/"+bucket, f))
+	t.Cleanup(server.Close)
+	return f, &S3Config{Endpoint: server.URL, Retries: 3}
+}
+
+func (f *fakeS3) etag(key string) string {
+	if etag, ok := f.etags[key]; ok {
+		return etag
+	}
+	sum := md5.Sum([]byte(f.objects[key]))
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.slowDown > 0 {
+		f.slowDown--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>")
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if key == "" {
+		f.list(w, r.URL.Query().Get("prefix"))
+		return
+	}
+	data, ok := f.objects[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	etag := `"` + f.etag(key) + `"`
+	if match := r.Header.Get("If-Match"); match != "" && match != etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		return
+	}
+	f.gets[key]++
+	var first, last int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &first, &last); err != nil {
+		first, last = 0, len(data)-1
+	}
+	w.WriteHeader(http.StatusPartialContent)
+	fmt.Fprint(w, data[first:min(last+1, len(data))])
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	var list s3List
+	var prefixes []string
+	for key := range f.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if dir, _, nested := strings.Cut(rest, "/"); nested {
+			if p := prefix + dir + "/"; !slices.Contains(prefixes, p) {
+				prefixes = append(prefixes, p)
+			}
+			continue
+		}
+		list.Contents = append(list.Contents, struct {
+			Key          string
+			LastModified time.Time
+			ETag         string
+			Size         int64
+		}{Key: key, ETag: `"` + f.etag(key) + `"`, Size: int64(len(f.objects[key]))})
+	}
+	for _, p := range prefixes {
+		list.CommonPrefixes = append(list.CommonPrefixes, struct{ Prefix string }{p})
+	}
+	xml.NewEncoder(w).Encode(list)
+}
+
+func TestS3Scan(t *testing.T) {
+	fake, s3cfg := newFakeS3(t, "bucket", map[string]string{
+		"photos/a.jpg":      "одинаковое",
+		"photos/2024/b.jpg": "одинаковое",
+		"photos/c.jpg":      "одинакового", // Размер другой
+		"photos/d.jpg":      "отличается",  // Размер как у a.jpg, ETag другой - не читается
+		"photos/e.bin":      "составной объект",
+		"photos/f.bin":      "составной объект",
+		"other/g.jpg":       "одинаковое", // Вне корня
+	})
+	// У составных объектов ETag - не MD5: их приходится читать
+	fake.etags["photos/e.bin"] = "0123456789abcdef0123456789abcdef-2"
+	fake.etags["photos/f.bin"] = "fedcba9876543210fedcba9876543210-2"
+	fake.slowDown = 2 // Первые запросы притормаживаются: сканер повторяет их
+
+	cfg := DefaultConfig("s3://bucket/photos")
+	cfg.S3 = s3cfg
+	s, err := NewScanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, group := range result.Groups {
+		var paths []string
+		for _, f := range group {
+			paths = append(paths, filepath.ToSlash(f.Path))
+		}
+		slices.Sort(paths)
+		got = append(got, paths)
+	}
+	slices.SortFunc(got, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	want := [][]string{{"photos/2024/b.jpg", "photos/a.jpg"}, {"photos/e.bin", "photos/f.bin"}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("группы %v, want %v", got, want)
+	}
+	if n := fake.gets["photos/d.jpg"]; n != 0 {
+		t.Errorf("photos/d.jpg прочитан %d раз, want ни разу: ETag уже отличает его", n)
+	}
+	if fake.gets["photos/e.bin"] == 0 || fake.gets["photos/f.bin"] == 0 {
+		t.Errorf("составные объекты не прочитаны: %v", fake.gets)
+	}
+}
+
+func TestS3MixedRoots(t *testing.T) {
+	local := t.TempDir()
+	tests := []struct {
+		name  string
+		roots []string
+	}{
+		{"локальный, потом S3", []string{local, "s3://bucket/photos"}},
+		{"S3, потом локальный", []string{"s3://bucket/photos", local}},
+		{"разные бакеты", []string{"s3://bucket/photos", "s3://other/photos"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig("")
+			cfg.Roots = tt.roots
+			cfg.S3 = &S3Config{Endpoint: "http://127.0.0.1:1"}
+			if _, err := NewScanner(cfg); err == nil || !strings.Contains(err.Error(), "в одном бакете") {
+				t.Errorf("NewScanner(%v): %v, want отказ смешивать корни", tt.roots, err)
+			}
+		})
+	}
+}
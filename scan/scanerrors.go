@@ -0,0 +1,126 @@
+// Подробный отчет об ошибках сканирования: какой путь, на какой операции и почему
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Операции, на которых может произойти ошибка (ScanError.Op)
+const (
+	OpWalk = "walk" // Чтение директории при обходе
+	OpStat = "stat" // Получение информации о файле
+	OpOpen = "open" // Открытие файла для хэширования
+	OpRead = "read" // Чтение содержимого
+
+	OpTimeout = "timeout" // Файл не прочитан за Config.FileTimeout (ErrReadTimeout)
+
+	OpChanged = "changed" // Файл изменился после обхода (ErrFileChanged, в Errors не попадает)
+)
+
+// Ошибки корня сканирования (RootError.Err): корня нет или это не папка
+var (
+	ErrRootNotFound = errors.New("путь для сканирования не найден")
+	ErrRootNotDir   = errors.New("путь для сканирования - не папка")
+)
+
+// RootError - корень нельзя обойти: Run, Candidates и Stream возвращают ее сразу, до чтения
+// файлов. Err - ErrRootNotFound или ErrRootNotDir, так что проверка - errors.Is(err, ErrRootNotFound).
+type RootError struct {
+	Path string
+	Err  error
+}
+
+func (e *RootError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Path)
+}
+
+func (e *RootError) Unwrap() error {
+	return e.Err
+}
+
+// maxScanErrors - сколько записей об ошибках хранится за запуск. Счетчик Stats.Errors
+// учитывает все ошибки, а записи сверх лимита отбрасываются, чтобы не расходовать память
+// на дереве, где не читается каждый файл.
+const maxScanErrors = 1000
+
+// ScanError - ошибка с одним путем. Если нет доступа к директории, обход в нее не заходит,
+// поэтому ошибка записывается один раз на директорию, а не на каждый файл внутри.
+type ScanError struct {
+	Path string
+	Op   string // OpWalk, OpStat, OpOpen, OpRead, OpTimeout
+	Err  error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, e.Path, e.Reason())
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Reason - причина без пути: текст системной ошибки (например, "permission denied").
+// По ней ошибки удобно группировать в отчете.
+func (e *ScanError) Reason() string {
+	var pathErr *fs.PathError
+	if errors.As(e.Err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+// errorLog - ограниченный потокобезопасный список ошибок запуска
+type errorLog struct {
+	mu    sync.Mutex
+	items []ScanError
+}
+
+// recordError учитывает ошибку в Stats.Errors и сохраняет запись о ней (в пределах лимита)
+func (s *Scanner) recordError(path, op string, err error) {
+	atomic.AddInt64(&s.stats.Errors, 1)
+	s.errors.mu.Lock()
+	defer s.errors.mu.Unlock()
+	if len(s.errors.items) < maxScanErrors {
+		s.errors.items = append(s.errors.items, ScanError{Path: path, Op: op, Err: err})
+	}
+}
+
+// Errors возвращает ошибки последнего запуска по порядку путей
+// (не больше maxScanErrors, всего их Stats.Errors)
+func (s *Scanner) Errors() []ScanError {
+	s.errors.mu.Lock()
+	defer s.errors.mu.Unlock()
+	errs := append([]ScanError(nil), s.errors.items...)
+	// Воркеры записывают ошибки в порядке завершения, а не обхода
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+// ErrorGroup - ошибки с одинаковой операцией и причиной
+type ErrorGroup struct {
+	Op     string
+	Reason string
+	Paths  []string
+}
+
+// GroupErrors группирует ошибки по операции и причине, в порядке первого появления
+func GroupErrors(errs []ScanError) []ErrorGroup {
+	var groups []ErrorGroup
+	index := make(map[[2]string]int)
+	for _, e := range errs {
+		key := [2]string{e.Op, e.Reason()}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ErrorGroup{Op: e.Op, Reason: key[1]})
+		}
+		groups[i].Paths = append(groups[i].Paths, e.Path)
+	}
+	return groups
+}
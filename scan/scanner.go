@@ -0,0 +1,1312 @@
+// Логика: структуры данных, обход файлов, оптимизация групп, конкурентные воркеры
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrInterrupted возвращается вместе с неполным результатом, если работа остановлена через Interrupt
+var ErrInterrupted = errors.New("сканирование прервано")
+
+// ErrFileChanged - размер файла изменился между обходом и чтением; такой файл пропускается
+// (FileInfo.Err) и считается в Stats.ChangedFiles, а не в ошибках
+var ErrFileChanged = errors.New("файл изменился во время сканирования")
+
+// FileInfo хранит данные об одном файле
+type FileInfo struct {
+	Path    string      // Полный путь
+	Name    string      // Имя файла
+	Size    int64       //Размер в байтах
+	Hash    string      // Хэш SHA-256 (вычисляется только при необходимости; пуст, если файл не прочитан; при Config.Comparator - его ключ)
+	ModTime time.Time   // Время последнего изменения
+	Mode    fs.FileMode // Права и тип файла
+	Dev     uint64      // Устройство (на Windows - серийный номер тома), 0 - неизвестно
+	Inode   uint64      // Inode (на Windows - индекс файла), 0 - неизвестно
+	Root    string      // Корень сканирования, в котором найден файл
+	Err     error       // Ошибка хэширования (*ScanError); такой файл не попадает ни в одну группу
+
+	HardlinkOf string // При Config.AnnotateHardlinks: путь группы, жесткой ссылкой на который является файл (пусто - отдельный файл)
+
+	ReadOnly     bool   // Виртуальный файл - запись архива (Config.ScanArchives): удалить или переместить его нельзя
+	fromManifest bool   // Запись из манифеста: хэш уже известен, файла на диске нет
+	fromSnapshot bool   // Хэш взят из снимка прошлого запуска (DeltaRun), файл не читается
+	hashedAll    bool   // Хэш посчитан до группировки (Config.HashAll), файл не читается повторно
+	etag         string // MD5 содержимого из ETag объекта S3 (см. S3Object.MD5), пусто - неизвестен
+}
+
+// hashKnown сообщает, что хэш файла уже известен и читать его не нужно
+func (f FileInfo) hashKnown() bool {
+	return f.fromManifest || f.fromSnapshot || f.hashedAll
+}
+
+// Stats - для атомарного счетчика проггресса
+type Stats struct {
+	TotalFiles      int64
+	DuplicateGroups int64
+	Errors          int64
+	ChangedFiles    int64 // Пропущено файлов, изменившихся между обходом и чтением (ErrFileChanged)
+	TimedOutFiles   int64 // Из Errors: файлов, не прочитанных за Config.FileTimeout (ErrReadTimeout)
+	ReadRetries     int64 // Повторов чтения после временных ошибок (Config.ReadRetries)
+	RetryFailures   int64 // Из Errors: файлов, не прочитанных и после повторов
+	OtherOwnerFiles int64 // Пропущено файлов других владельцев (Config.OwnerUID, Config.OnlyCurrentUser)
+
+	TotalCandidateBytes int64 // Объем кандидатов в дубликаты, которые нужно прочитать (0 в режимах без хэша)
+
+	FilesPerRoot map[string]int64 // Сколько файлов найдено в каждом корне
+}
+
+// Scanner инкпсулирует логику поиска
+type Scanner struct {
+	config    Config
+	stats     Stats    // Используем атомики для конкурентного доступа
+	roots     []string // Корни без повторов и вложенных друг в друга
+	rootFiles []int64  // Счетчики файлов по корням (атомики, индексы как в roots)
+
+	unique   []FileInfo // Файлы без пары (собираются только при Config.ReportUnique)
+	allFiles []FileInfo // Все файлы обхода с хэшами (только при Config.HashAll)
+
+	progress    progressState // Фаза и счетчики для Config.OnProgress
+	interrupted int32         // 1 - запрошена остановка (атомик)
+
+	ctx context.Context // Контекст текущего запуска (Run, Candidates, ManifestFiles), иначе context.Background
+
+	current []atomic.Value                 // Файл, который читает каждый воркер (string), для Status
+	reading []atomic.Pointer[fileProgress] // Сколько прочитано из файла каждого воркера (nil - не читает)
+	pause   pauseGate                      // Pause/Resume
+
+	logger *slog.Logger // Журнал (WithLogger, Config.Logger или по Config.LogLevel), никогда не nil
+	errors errorLog     // Записи об ошибках текущего запуска (Errors)
+
+	stuckReads int64 // Брошенные по Config.FileTimeout чтения, которые еще висят (атомик, см. maxStuckReads)
+
+	limiter *rate.Limiter    // Общий лимит скорости чтения (Config.MaxBytesPerSecond), nil - без ограничения
+	newHash func() hash.Hash // Алгоритм хэша для группировки (WithHasher), nil - SHA-256
+
+	dirFiles map[string]int // Файлов в каждой директории (при Config.DirDuplicates)
+	dirDups  []DirDuplicate // Папки-копии последнего запуска
+	prefixes []PrefixMatch  // Недокачанные копии последнего запуска (Config.FindPrefixes)
+
+	treeFiles []FileInfo      // Все файлы обхода (при Config.DirTrees)
+	treeDups  []TreeDuplicate // Одинаковые деревья папок последнего запуска
+
+	chunkReport *ChunkReport // Оценка режима chunks последнего запуска
+
+	ownerUID int // Владелец, файлы которого берутся при обходе; -1 - любой
+
+	hardlinks [][]FileInfo // Группы путей к одному файлу последнего запуска (Hardlinks)
+
+	remotes map[string]*SSHFS // Серверы удаленных корней по префиксу "sftp://user@host"
+
+	delta *deltaState // Снимки во время DeltaRun, иначе nil
+
+	timings stageTimes // Длительность этапов последнего запуска (Timings)
+	last    lastScan   // Итог последнего завершенного Run (для WriteMetrics)
+}
+
+// Result - итог запуска Run
+type Result struct {
+	Groups DuplicateSet // Группы дубликатов после фильтров, сортировки и MaxGroups
+	Unique []FileInfo   // Файлы без копий (только при Config.ReportUnique)
+	Stats  Stats        // Статистика запуска
+	Errors []ScanError  // Подробности ошибок (всего ошибок - Stats.Errors)
+
+	DirDuplicates  []DirDuplicate  // Папки-копии (только при Config.DirDuplicates)
+	TreeDuplicates []TreeDuplicate // Одинаковые деревья папок (только при Config.DirTrees)
+	PrefixMatches  []PrefixMatch   // Недокачанные копии (только при Config.FindPrefixes)
+	ChunkReport    *ChunkReport    // Оценка дедупликации блоками (только в режиме chunks)
+}
+
+// NewScanner проверяет настройки и создает сканер (с настройками по умолчанию - NewScannerE).
+// Опции применяются к cfg до проверки; ошибка - неверное значение в cfg или в опции.
+func NewScanner(cfg Config, opts ...Option) (*Scanner, error) {
+	s := &Scanner{config: cfg, ctx: context.Background()}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	cfg = s.config
+	if s.newHash != nil && len(cfg.Manifests) > 0 {
+		return nil, errors.New("манифесты хранят SHA-256: сравнение с ними несовместимо с WithHasher")
+	}
+
+	if cfg.FilesFrom == nil && slices.Equal(cfg.RootList(), []string{FilesFromStdin}) {
+		cfg.FilesFrom = os.Stdin
+		s.config = cfg
+	}
+	if cfg.FilesFrom != nil {
+		if err := filesFromRoots(cfg); err != nil {
+			return nil, err
+		}
+	} else if cfg.FS == nil {
+		fsys, roots, err := s3Roots(cfg.RootList(), cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		if fsys != nil { // Корни в бакете S3: дальше он - обычная fs.FS
+			cfg.FS, cfg.Roots = fsys, roots
+			s.config = cfg
+		}
+	}
+	switch {
+	case cfg.FilesFrom != nil:
+		s.roots = []string{FilesFromStdin}
+	case cfg.FS != nil:
+		s.roots = normalizeFSRoots(cfg.RootList())
+	default:
+		s.roots = normalizeRoots(cfg.RootList())
+	}
+	var workersReason string
+	if cfg.Workers == 0 {
+		cfg.Workers, workersReason = autoWorkers(cfg, s.roots)
+		s.config = cfg
+	}
+	if err := cfg.validate(s.roots); err != nil {
+		return nil, err
+	}
+	if err := s.openRemotes(s.roots); err != nil {
+		return nil, err
+	}
+	s.rootFiles = make([]int64, len(s.roots))
+	s.current = make([]atomic.Value, cfg.Workers)
+	s.reading = make([]atomic.Pointer[fileProgress], cfg.Workers)
+	s.pause.cond = sync.NewCond(&s.pause.mu)
+	s.limiter = newReadLimiter(cfg.MaxBytesPerSecond)
+	if s.logger == nil {
+		s.logger = newLogger(cfg)
+	}
+	if workersReason != "" {
+		s.logger.Info("число воркеров выбрано автоматически", "workers", cfg.Workers, "reason", workersReason)
+	}
+	s.ownerUID = s.resolveOwner()
+	return s, nil
+}
+
+// Workers возвращает число воркеров: Config.Workers или подобранное, если там 0
+func (s *Scanner) Workers() int {
+	return s.config.Workers
+}
+
+// Unique возвращает файлы, ключ группировки которых не совпал ни с одним другим файлом.
+// Заполняется при Config.ReportUnique; в режиме hash уникальность - это отсутствие копий по содержимому.
+// Файлы, которые не удалось прочитать, сюда не попадают: про них ничего не известно.
+func (s *Scanner) Unique() []FileInfo {
+	return s.unique
+}
+
+// AllFiles возвращает все файлы последнего запуска с полным хэшем, а не только дубликаты,
+// упорядоченные по пути. Заполняется при Config.HashAll; у файлов, которые не удалось
+// прочитать, хэш пустой, а ошибка - в Err.
+func (s *Scanner) AllFiles() []FileInfo {
+	return s.allFiles
+}
+
+// Roots возвращает корни, которые действительно будут обойдены
+func (s *Scanner) Roots() []string {
+	return s.roots
+}
+
+// GetStats возвращает текущую статистику (юезопасно для конкурентного чтения благодаря атомикам)
+func (s *Scanner) GetStats() Stats {
+	return Stats{
+		TotalFiles:      atomic.LoadInt64(&s.stats.TotalFiles),
+		DuplicateGroups: atomic.LoadInt64(&s.stats.DuplicateGroups),
+		Errors:          atomic.LoadInt64(&s.stats.Errors),
+		ChangedFiles:    atomic.LoadInt64(&s.stats.ChangedFiles),
+		TimedOutFiles:   atomic.LoadInt64(&s.stats.TimedOutFiles),
+		ReadRetries:     atomic.LoadInt64(&s.stats.ReadRetries),
+		RetryFailures:   atomic.LoadInt64(&s.stats.RetryFailures),
+		OtherOwnerFiles: atomic.LoadInt64(&s.stats.OtherOwnerFiles),
+
+		TotalCandidateBytes: atomic.LoadInt64(&s.stats.TotalCandidateBytes),
+		FilesPerRoot:        s.filesPerRoot(),
+	}
+}
+
+// Reset обнуляет статистику и накопленные результаты прошлого запуска.
+// Run, Candidates и ManifestFiles вызывают его сами, так что один Scanner можно запускать повторно.
+func (s *Scanner) Reset() {
+	atomic.StoreInt64(&s.stats.TotalFiles, 0)
+	atomic.StoreInt64(&s.stats.DuplicateGroups, 0)
+	atomic.StoreInt64(&s.stats.Errors, 0)
+	atomic.StoreInt64(&s.stats.ChangedFiles, 0)
+	atomic.StoreInt64(&s.stats.TimedOutFiles, 0)
+	atomic.StoreInt64(&s.stats.ReadRetries, 0)
+	atomic.StoreInt64(&s.stats.RetryFailures, 0)
+	atomic.StoreInt64(&s.stats.OtherOwnerFiles, 0)
+	atomic.StoreInt64(&s.stats.TotalCandidateBytes, 0)
+	s.errors.mu.Lock()
+	s.errors.items = nil
+	s.errors.mu.Unlock()
+	for i := range s.rootFiles {
+		atomic.StoreInt64(&s.rootFiles[i], 0)
+	}
+	atomic.StoreInt64(&s.progress.filesHashed, 0)
+	atomic.StoreInt64(&s.progress.bytesHashed, 0)
+	atomic.StoreInt64(&s.progress.bytesTotal, 0)
+	atomic.StoreInt32(&s.progress.noHashing, 0)
+	atomic.StoreInt32(&s.interrupted, 0)
+	s.resetTimings()
+	s.ctx = context.Background()
+	s.unique = nil
+	s.allFiles = nil
+	s.dirFiles = nil
+	s.dirDups = nil
+	s.prefixes = nil
+	s.treeFiles = nil
+	s.treeDups = nil
+	s.chunkReport = nil
+	s.hardlinks = nil
+	for _, fsys := range s.remotes {
+		fsys.forget()
+	}
+}
+
+// Interrupt просит остановить текущий запуск: обход прекращается, новые файлы не хэшируются,
+// а уже начатые дочитываются. Run вернет группы, проверенные целиком, и ErrInterrupted.
+// Безопасно вызывать из другой горутины (например, из обработчика сигнала).
+func (s *Scanner) Interrupt() {
+	atomic.StoreInt32(&s.interrupted, 1)
+	s.wakePaused()
+}
+
+// Interrupted сообщает, была ли запрошена остановка (через Interrupt или отменой контекста)
+func (s *Scanner) Interrupted() bool {
+	return atomic.LoadInt32(&s.interrupted) == 1 || s.ctx.Err() != nil
+}
+
+// interruptErr возвращает ошибку контекста, если он отменен, или ErrInterrupted после Interrupt
+func (s *Scanner) interruptErr() error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if s.Interrupted() {
+		return ErrInterrupted
+	}
+	return nil
+}
+
+// filesPerRoot собирает счетчики файлов по корням
+func (s *Scanner) filesPerRoot() map[string]int64 {
+	result := make(map[string]int64, len(s.roots))
+	for i, root := range s.roots {
+		result[root] = atomic.LoadInt64(&s.rootFiles[i])
+	}
+	return result
+}
+
+// Run запускает весь паплайн. Контекст проверяется при обходе, при раздаче задач
+// и между файлами в воркерах, а чтение большого файла прерывается в пределах одного буфера.
+// Результат возвращается всегда, даже с ошибкой: после отмены, Interrupt или сбоя обхода в нем
+// группы, проверенные целиком к этому моменту (при остановке на обходе или группировке - ни одной),
+// статистика и ошибки файлов, а рядом - ошибка контекста, ErrInterrupted или ошибка запуска.
+// Run собирает все группы из того же потока, что и Stream, и упорядочивает их.
+func (s *Scanner) Run(ctx context.Context) (*Result, error) {
+	start, hashedBefore := time.Now(), atomic.LoadInt64(&s.progress.hashedTotal)
+	var groups [][]FileInfo
+	err := s.run(ctx, func(group []FileInfo) { groups = append(groups, group) })
+	groups = s.finalize(groups)
+
+	result := &Result{
+		Groups: groups,
+		Unique: s.unique,
+		Stats:  s.GetStats(),
+		Errors: s.Errors(),
+
+		DirDuplicates:  s.dirDups,
+		TreeDuplicates: s.treeDups,
+		PrefixMatches:  s.prefixes,
+		ChunkReport:    s.chunkReport,
+	}
+	if err == nil {
+		err = s.interruptErr()
+	}
+	if err == nil {
+		s.last.record(time.Since(start), s.Timings().HashDuration, atomic.LoadInt64(&s.progress.hashedTotal)-hashedBefore, result.Groups.TotalWasted())
+	}
+	return result, err
+}
+
+// Stream запускает паплайн и отдает группы по мере готовности: группа уходит в канал,
+// как только проверены все файлы с тем же ключом кандидата (в режиме hash - все файлы
+// того же размера). Каждая группа отправляется ровно один раз и после отправки не меняется.
+// Файлы внутри группы упорядочены по пути, а порядок самих групп зависит от того,
+// какие файлы прочитаны раньше, и между запусками может отличаться.
+//
+// Фильтры CrossRoot, RedundantIn и DirFilter применяются к каждой группе, а SortBy,
+// MaxGroups, DirDuplicates и DirTrees - нет: для них нужны все группы сразу (это делает Run).
+// Когда группы кончились, канал групп закрывается, а в канал ошибок приходит итог, как у Run:
+// nil, ошибка, ErrInterrupted или ошибка контекста. Группы нужно вычитывать до закрытия
+// канала; чтобы бросить чтение раньше, отмените ctx.
+func (s *Scanner) Stream(ctx context.Context) (<-chan []FileInfo, <-chan error) {
+	groups := make(chan []FileInfo)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		err := s.run(ctx, func(group []FileInfo) {
+			group, ok := s.filterGroup(group)
+			if !ok {
+				return
+			}
+			atomic.AddInt64(&s.stats.DuplicateGroups, 1)
+			select {
+			case groups <- group:
+			case <-ctx.Done(): // Читатель ушел, отменив контекст
+			}
+		})
+		close(groups)
+		if err == nil {
+			err = s.interruptErr()
+		}
+		errc <- err
+	}()
+	return groups, errc
+}
+
+// run - общая часть Run и Stream: обход, группировка и хэширование, каждая готовая группа
+// (без фильтров результата) передается в emit. Ошибка - только если не удалось собрать кандидатов.
+func (s *Scanner) run(ctx context.Context, emit emitFunc) error {
+	defer s.startProgress()()
+	s.begin(ctx)
+	defer context.AfterFunc(ctx, s.wakePaused)() // Отмена должна разбудить воркеров на паузе
+
+	// 1-2. Сбор файлов и группировка кандидатов
+	candidates, err := s.candidates()
+	if err != nil {
+		return err
+	}
+
+	// 3. Уточнение (вычисление всех хэшей конкурентно, если нужно)
+	return s.processCandidates(candidates, emit)
+}
+
+// Candidates выполняет только обход и "грубую" группировку, без чтения содержимого файлов.
+// Возвращает группы кандидатов (в режиме hash - файлы с совпадающим размером),
+// чтобы заранее оценить объем хэширования.
+func (s *Scanner) Candidates(ctx context.Context) ([][]FileInfo, error) {
+	defer s.startProgress()()
+	s.begin(ctx)
+	groups, err := s.candidates()
+	sortFiles(s.unique)
+	return groups, err
+}
+
+// begin готовит сканер к новому запуску с контекстом ctx
+func (s *Scanner) begin(ctx context.Context) {
+	s.Reset()
+	s.ctx = ctx
+}
+
+// candidates - обход и группировка кандидатов без сброса статистики и запуска колбэка прогресса
+func (s *Scanner) candidates() ([][]FileInfo, error) {
+	// 1. Сбор всех файлов (быстрый проход)
+	s.setPhase(PhaseWalking)
+	start := time.Now()
+	allFiles, err := s.scanFileSystem()
+	if err != nil {
+		return nil, err
+	}
+
+	// Записи манифестов группируются вместе с файлами как еще один корень
+	for _, path := range s.config.Manifests {
+		entries, err := ReadManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		allFiles = append(allFiles, entries...)
+		atomic.AddInt64(&s.stats.TotalFiles, int64(len(entries)))
+	}
+	s.measure(&s.timings.walk, start)
+	s.carrySnapshot(allFiles)
+	if s.config.HashAll {
+		// Хэшируются все файлы, а не только кандидаты; группировка дальше берет готовые хэши
+		s.hashAll(allFiles)
+		s.allFiles = slices.Clone(allFiles)
+		sortFiles(s.allFiles)
+	}
+	if s.config.FindPrefixes {
+		s.prefixes = s.findPrefixes(allFiles)
+	}
+
+	// 2. Группировка кандидатов (отсеиваем явно уникальные файлы)
+	return s.groupFiles(allFiles)
+}
+
+// groupFiles группирует кандидатов среди собранных файлов (фаза grouping)
+func (s *Scanner) groupFiles(allFiles []FileInfo) ([][]FileInfo, error) {
+	s.setPhase(PhaseGrouping)
+	defer s.measure(&s.timings.group, time.Now())
+	s.hardlinks = findHardlinks(allFiles)
+	if s.config.IgnoreHardlinks {
+		allFiles = collapseHardlinks(allFiles, s.hardlinks)
+	}
+	groups, err := s.groupCandidates(allFiles)
+	if err != nil {
+		return nil, err
+	}
+	candidates := 0
+	for _, group := range groups {
+		candidates += len(group)
+	}
+	s.logger.Info("кандидаты сгруппированы", "files", len(allFiles), "groups", len(groups), "candidates", candidates)
+	return groups, s.interruptErr()
+}
+
+// scanFileSystem обходит все корни рекурсивно; группировка дальше идет по их объединению
+func (s *Scanner) scanFileSystem() ([]FileInfo, error) {
+	var files []FileInfo
+	if s.config.FilesFrom != nil {
+		return s.readFileList(files)
+	}
+	for i, root := range s.roots {
+		if s.Interrupted() {
+			break
+		}
+		if err := s.checkRoot(root); err != nil {
+			if !s.config.SkipMissingRoots {
+				return files, err
+			}
+			s.recordError(root, OpWalk, errors.Unwrap(err)) // Путь уже есть в записи
+			s.logger.Warn("корень пропущен", "root", root, "err", err)
+			continue
+		}
+		var err error
+		if files, err = s.walkRoot(files, i, root); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// checkRoot проверяет, что корень есть и это папка (*RootError). Корни SFTP здесь не проверяются:
+// лишнее подключение до обхода ничего не дает, ошибку покажет обход. Прочие ошибки (нет доступа)
+// попадают в Errors при обходе, как и раньше.
+func (s *Scanner) checkRoot(root string) error {
+	if _, _, remote := s.remoteFS(root); remote {
+		return nil
+	}
+	var info fs.FileInfo
+	var err error
+	if s.config.FS != nil {
+		info, err = fs.Stat(s.config.FS, root)
+	} else {
+		info, err = os.Stat(LongPath(root))
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &RootError{Path: root, Err: ErrRootNotFound}
+	case err == nil && !info.IsDir():
+		return &RootError{Path: root, Err: ErrRootNotDir}
+	}
+	return nil
+}
+
+// walkRoot обходит один корень и дописывает найденные файлы в files.
+//
+// Порядок фильтров: Config.FileFilter вызывается здесь, при обходе, для каждого файла
+// после получения его информации. Встроенные фильтры (например, IgnoreEmptyFiles)
+// применяются позже, при группировке. В результат попадают только файлы, прошедшие все фильтры.
+func (s *Scanner) walkRoot(files []FileInfo, rootIndex int, root string) ([]FileInfo, error) {
+	s.logger.Debug("обход корня", "root", root)
+	defer func() {
+		s.logger.Debug("обход корня завершен", "root", root, "files", atomic.LoadInt64(&s.rootFiles[rootIndex]))
+	}()
+	err := s.walkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			s.recordError(path, OpWalk, err)
+			s.logger.Debug("путь пропущен", "path", path, "reason", "ошибка обхода", "err", err)
+			return nil
+		}
+		s.waitIfPaused()
+		if s.Interrupted() {
+			return fs.SkipAll
+		}
+		if !d.IsDir() {
+			info, err := d.Info()
+			switch {
+			case err != nil:
+				s.recordError(path, OpStat, err)
+				s.logger.Debug("путь пропущен", "path", path, "reason", "нет информации о файле", "err", err)
+			case s.config.FileFilter != nil && !s.config.FileFilter(path, info):
+				s.logger.Debug("путь пропущен", "path", path, "reason", "FileFilter")
+			case !s.ownedBy(info):
+				atomic.AddInt64(&s.stats.OtherOwnerFiles, 1)
+				s.logger.Debug("путь пропущен", "path", path, "reason", "другой владелец")
+			default:
+				f := FileInfo{
+					Path:    path,
+					Name:    d.Name(),
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+					Mode:    info.Mode(),
+					Root:    root,
+				}
+				_, _, remote := s.remoteFS(path)
+				switch {
+				case remote: // Удаленный файл только читается
+					f.ReadOnly = true
+				case s.config.FS == nil: // Пути внутри fs.FS - не пути ОС
+					f.Dev, f.Inode = fileID(path, info)
+				default:
+					f.etag = etagMD5(info)
+				}
+				files = append(files, f)
+				atomic.AddInt64(&s.stats.TotalFiles, 1)
+				atomic.AddInt64(&s.rootFiles[rootIndex], 1)
+				if s.config.ScanArchives && s.config.FS == nil && !remote && archiveKind(f.Name) != "" {
+					files = s.walkArchive(files, rootIndex, root, path)
+				}
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// groupCandidates выполняет "грубую" группировку перед тяжелой обработкой.
+//
+// Ключи режимов (кандидаты -> итоговая группа):
+//
+//	name_only       имя               -> (без хэширования, размер не важен)
+//	name_size       имя|размер        -> (без хэширования)
+//	size_only       размер            -> (без хэширования, содержимое не читается)
+//	name_count      имя               -> (без хэширования, только имена, встретившиеся NameCountThreshold раз)
+//	hash, fast_hash размер            -> хэш
+//	compare         размер            -> (побайтовое сравнение, без ключа, см. compareGroups)
+//	combined        имя|размер        -> имя|хэш
+//	name_size_hash  имя|размер        -> имя|размер|хэш
+//	name_hash_dir   имя|размер|папка  -> имя|хэш|папка (папка - имя родительской директории)
+//	image           все изображения   -> похожие по перцептивному хэшу (не ключ, а кластеры, см. clusterImages)
+func (s *Scanner) groupCandidates(files []FileInfo) ([][]FileInfo, error) {
+	groups := make(map[string][]FileInfo)
+
+	for _, f := range files {
+		// Пустые файлы одинаковы по определению и дают одну огромную "группу"
+		if s.config.IgnoreEmptyFiles && f.Size == 0 {
+			s.logger.Debug("путь пропущен", "path", f.Path, "reason", "пустой файл")
+			continue
+		}
+		// Режим image сравнивает только картинки: остальные файлы не декодируются вовсе
+		if s.config.Mode == ModeImage && !isImage(f.Name) {
+			continue
+		}
+		s.countDirFile(f)
+		s.recordTreeFile(f)
+		if s.config.Comparator != nil {
+			// Свое определение дубликата: ключи посчитает processCandidates, кандидаты - все файлы
+			groups[""] = append(groups[""], f)
+			continue
+		}
+		var key string
+		switch s.config.Mode {
+		case ModeNameOnly, ModeNameCount:
+			key = s.nameKey(f.Name)
+		case ModeNameSize, ModeCombined, ModeNameSizeHash:
+			key = fmt.Sprintf("%s|%s", s.nameKey(f.Name), s.sizeKey(f))
+		case ModeNameHashDir:
+			key = fmt.Sprintf("%s|%s|%s", s.nameKey(f.Name), s.sizeKey(f), parentDirName(f))
+		case ModeHash, ModeFastHash, ModeSizeOnly, ModeCompare:
+			//ОПТИМИЗАЦИЯ: Сначала группируем ТОЛЬКО по размеру
+			key = s.sizeKey(f)
+			if s.config.RequireSameName {
+				// Файлы с разными именами не станут копиями, их незачем и читать
+				key = fmt.Sprintf("%s|%s", s.nameKey(f.Name), key)
+			}
+		case ModeImage:
+			// Уменьшенная или пережатая копия другого размера: размер не важен, кандидаты - все картинки
+			key = string(ModeImage)
+		case ModeChunks:
+			// Общие блоки бывают у файлов любого размера и даже внутри одного файла
+			key = string(ModeChunks)
+		default: // Иначе все файлы попали бы в одну группу с пустым ключом
+			return nil, fmt.Errorf("неизвестный режим %q", s.config.Mode)
+		}
+		groups[key] = append(groups[key], f)
+	}
+
+	if all := groups[string(ModeChunks)]; s.config.Mode == ModeChunks && s.config.Comparator == nil && len(all) > 0 {
+		return [][]FileInfo{all}, nil // Один файл - тоже кандидат
+	}
+	return s.splitSingletons(groups), nil
+}
+
+// minGroupSize - сколько файлов должно быть в группе, чтобы она попала в результат
+// (Config.MinGroupSize, а в режиме name_count - не меньше Config.NameCountThreshold)
+func (s *Scanner) minGroupSize() int {
+	size := max(s.config.MinGroupSize, 2)
+	if s.config.Mode == ModeNameCount {
+		size = max(size, s.config.NameCountThreshold)
+	}
+	return size
+}
+
+// splitSingletons возвращает группы не меньше minGroupSize файлов, упорядоченные по путям.
+// Одиночки при Config.ReportUnique не выбрасываются, а сохраняются как уникальные файлы;
+// группы меньше порога просто отбрасываются - у их файлов есть копии.
+func (s *Scanner) splitSingletons(groups map[string][]FileInfo) [][]FileInfo {
+	var result [][]FileInfo
+	minSize := s.minGroupSize()
+	for _, group := range groups {
+		if len(group) >= minSize {
+			result = append(result, group)
+		} else if len(group) == 1 && s.config.ReportUnique {
+			s.unique = append(s.unique, group[0])
+		}
+	}
+	sortCanonical(result)
+	return result
+}
+
+// parentDirName возвращает имя папки, в которой лежит файл (не весь путь):
+// a/photos/x.jpg и b/photos/x.jpg относятся к одной папке "photos"
+func parentDirName(f FileInfo) string {
+	return filepath.Base(filepath.Dir(f.Path))
+}
+
+// IsEmptyGroup сообщает, что группа состоит из пустых файлов.
+// Такая группа обычно шум, а не настоящие дубликаты, и ее стоит показывать отдельно.
+func IsEmptyGroup(group []FileInfo) bool {
+	return len(group) > 0 && group[0].Size == 0
+}
+
+// emitFunc получает готовую группу. Вызывается из одной горутины (той, что собирает
+// результаты воркеров), а группа после передачи больше не меняется.
+type emitFunc func(group []FileInfo)
+
+// processCandidates обрабатывает кандидатов (считает хэш конкурентно) и передает в emit
+// каждую группу, как только проверены все файлы ее группы кандидатов
+func (s *Scanner) processCandidates(groups [][]FileInfo, emit emitFunc) error {
+	if !s.config.Mode.hashesContent() && s.config.Comparator == nil {
+		atomic.StoreInt32(&s.progress.noHashing, 1)
+		for _, group := range groups {
+			emit(group)
+		}
+		return nil
+	}
+	if s.config.Comparator == nil && s.config.Mode != ModeImage && !s.config.ContentOnly {
+		groups = s.splitByETag(groups)
+	}
+	var total int64
+	for _, group := range groups {
+		for _, f := range group {
+			total += f.Size
+		}
+	}
+	atomic.StoreInt64(&s.stats.TotalCandidateBytes, total)
+
+	if s.config.Comparator != nil {
+		// Ключи Comparator считаются воркерами, как хэши: Key может читать файл
+		s.setPhase(PhaseHashing)
+		defer s.measure(&s.timings.hash, time.Now())
+		return s.hashAndRegroup(groups, func(job hashJob) (string, error) {
+			f := groups[job.group][job.index] // Файл задачи меняется только после ее результата
+			return s.config.Comparator.Key(f.Path, f)
+		}, emit)
+	}
+	if s.config.Mode == ModeChunks {
+		// Групп нет: все кандидаты - одна группа, по ней только оценка
+		for _, group := range groups {
+			s.chunkReport = s.estimateChunks(group)
+		}
+		return nil
+	}
+	if s.config.Mode == ModeCompare {
+		s.setPhase(PhaseHashing)
+		defer s.measure(&s.timings.hash, time.Now())
+		return s.compareGroups(groups, emit)
+	}
+
+	read := s.contentRead()
+	switch s.config.Mode {
+	case ModeFastHash:
+		read = s.readHash(s.edgesHash())
+	case ModeImage:
+		read = s.readHash(imageHash)
+	}
+	s.setPhase(PhaseHashing)
+	defer s.measure(&s.timings.hash, time.Now())
+	if fullHashModes[s.config.Mode] && !s.config.ContentOnly {
+		// Полный хэш: неизменившиеся файлы берутся из снимка DeltaRun, посчитанные - записываются в него
+		s.reuseHashes(groups)
+		defer s.rememberHashes(groups)
+	}
+	if s.config.Mode != ModeFastHash || !s.config.Verify {
+		return s.hashAndRegroup(groups, read, emit)
+	}
+
+	// Группы быстрого хэша - лишь кандидаты: наружу идут только подтвержденные полным хэшем.
+	// Перепроверка (Config.Verify): группы, совпавшие только по началу и концу файла,
+	// распадаются или исчезают.
+	var fast [][]FileInfo
+	if err := s.hashAndRegroup(groups, read, func(group []FileInfo) { fast = append(fast, group) }); err != nil {
+		return err
+	}
+	if s.Interrupted() {
+		return nil
+	}
+	s.setPhase(PhaseVerifying)
+	for _, group := range fast {
+		for j := range group {
+			group[j].Hash = "" // Хэш краев: в снимок должен попасть только полный
+		}
+	}
+	s.reuseHashes(fast)
+	defer s.rememberHashes(fast)
+	return s.hashAndRegroup(fast, s.contentRead(), emit)
+}
+
+// ManifestFiles обходит корни и считает полный хэш каждого файла, а не только кандидатов в дубликаты.
+// Файлы, которые не удалось прочитать, возвращаются с пустым хэшем и ошибкой в Err.
+func (s *Scanner) ManifestFiles(ctx context.Context) ([]FileInfo, error) {
+	defer s.startProgress()()
+	s.begin(ctx)
+
+	s.setPhase(PhaseWalking)
+	start := time.Now()
+	files, err := s.scanFileSystem()
+	if err != nil {
+		return nil, err
+	}
+	s.measure(&s.timings.walk, start)
+	s.hashEach(files, s.readHash(computeHash))
+	return files, s.interruptErr()
+}
+
+// hashAll считает хэш содержимого каждого найденного файла (Config.HashAll).
+// Неизменившиеся файлы берутся из снимка DeltaRun, посчитанные - записываются в него.
+func (s *Scanner) hashAll(files []FileInfo) {
+	flat := [][]FileInfo{files}
+	if !s.config.ContentOnly {
+		s.reuseHashes(flat)
+		defer s.rememberHashes(flat)
+	}
+	s.hashEach(files, s.contentRead())
+}
+
+// hashEach конкурентно считает хэш каждого файла списка функцией read (фаза hashing).
+// Файлы с уже известным хэшем не читаются; остальные помечаются посчитанными, даже с ошибкой.
+func (s *Scanner) hashEach(files []FileInfo, read readFunc) {
+	defer s.measure(&s.timings.hash, time.Now())
+	var total int64
+	for _, f := range files {
+		if !f.hashKnown() {
+			total += f.Size
+		}
+	}
+	s.setPhase(PhaseHashing)
+	s.hashFiles(total, func(send func(hashJob) bool) {
+		for i, f := range files {
+			if f.hashKnown() {
+				continue
+			}
+			if !send(hashJob{path: f.Path, size: f.Size, index: i}) {
+				return
+			}
+		}
+	}, read, func(r hashResult) {
+		f := &files[r.index]
+		f.Hash, f.Err, f.hashedAll = r.hash, r.err, true
+	})
+}
+
+// finalize применяет фильтры к готовым группам, упорядочивает их и обновляет счетчик групп в статистике
+func (s *Scanner) finalize(groups [][]FileInfo) [][]FileInfo {
+	// Файлы сортируются до фильтров: redundantIn ставит эталонную копию первой, ее порядок трогать нельзя
+	for _, group := range groups {
+		sortFiles(group)
+	}
+	sortFiles(s.unique)
+	// Папки-копии ищутся по всем группам, до фильтров и MaxGroups
+	if s.config.DirDuplicates {
+		s.dirDups = findDirDuplicates(groups, s.dirFiles)
+	}
+	if s.config.DirTrees {
+		s.treeDups, groups = findTreeDuplicates(groups, s.treeFiles)
+	}
+
+	if s.config.RedundantIn != "" {
+		groups = redundantIn(groups, s.config.RedundantIn)
+	} else if s.config.CrossRoot {
+		groups = filterCrossRoot(groups)
+	}
+	groups = s.dropSmallGroups(filterByDirs(groups, s.config.DirFilter))
+	if s.config.AnnotateHardlinks {
+		groups = annotateHardlinks(groups)
+	}
+	sortGroups(groups, s.config.SortBy, s.config.SortReverse)
+
+	// В статистике - все найденные группы, даже если вернем только первые MaxGroups
+	atomic.StoreInt64(&s.stats.DuplicateGroups, int64(len(groups)))
+	if s.config.MaxGroups > 0 && len(groups) > s.config.MaxGroups {
+		groups = groups[:s.config.MaxGroups]
+	}
+	return groups
+}
+
+// dropSmallGroups убирает группы, которые после фильтров стали меньше minGroupSize
+func (s *Scanner) dropSmallGroups(groups [][]FileInfo) [][]FileInfo {
+	minSize := s.minGroupSize()
+	return slices.DeleteFunc(groups, func(group []FileInfo) bool { return len(group) < minSize })
+}
+
+// filterGroup применяет к одной группе фильтры результата (для Stream; Run фильтрует все сразу)
+func (s *Scanner) filterGroup(group []FileInfo) ([]FileInfo, bool) {
+	sortFiles(group)
+	groups := [][]FileInfo{group}
+	if s.config.RedundantIn != "" {
+		groups = redundantIn(groups, s.config.RedundantIn)
+	} else if s.config.CrossRoot {
+		groups = filterCrossRoot(groups)
+	}
+	groups = s.dropSmallGroups(filterByDirs(groups, s.config.DirFilter))
+	if s.config.AnnotateHardlinks {
+		groups = annotateHardlinks(groups)
+	}
+	if len(groups) == 0 {
+		return nil, false
+	}
+	return groups[0], true
+}
+
+// hashAndRegroup считает хэши файлов групп конкурентно и перегруппировывает их по хэшу
+//
+// Файлы с одинаковым итоговым ключом всегда лежат в одной группе кандидатов (ключ кандидата -
+// часть итогового: размер, имя), поэтому группу кандидатов можно перегруппировать отдельно,
+// как только хэши всех ее файлов посчитаны, и сразу отдать результат в emit.
+// При остановке группы, у которых обработаны не все файлы, не отдаются: копия могла
+// остаться непроверенной.
+func (s *Scanner) hashAndRegroup(groups [][]FileInfo, read readFunc, emit emitFunc) error {
+	// Считаем объем работы; задачи воркерам раздаются прямо из групп, без плоского списка файлов.
+	// Записи манифеста и файлы из снимка DeltaRun не читаются: их хэш уже есть.
+	left := make([]int, len(groups)) // Сколько файлов группы еще не обработано
+	var files, total int64
+	for i := range groups {
+		for _, f := range groups[i] {
+			if !f.hashKnown() {
+				left[i]++
+				files++
+				total += f.Size
+			}
+		}
+	}
+
+	// Результаты воркеров собираются в этой горутине, поэтому группы, emit и
+	// список уникальных файлов трогает только она
+	var regroupErr error
+	complete := func(i int) {
+		result, err := s.regroup(groups[i])
+		if err != nil {
+			regroupErr = err
+			return
+		}
+		for _, group := range result {
+			emit(group)
+		}
+	}
+	for i := range groups {
+		if left[i] == 0 { // Все хэши уже известны: читать нечего
+			complete(i)
+		}
+	}
+
+	s.logger.Info("хэширование", "files", files, "groups", len(groups))
+	start := time.Now()
+	produce := func(send func(hashJob) bool) {
+		for i := range groups {
+			for j, f := range groups[i] {
+				if f.hashKnown() {
+					continue
+				}
+				if !send(hashJob{path: f.Path, size: f.Size, group: i, index: j}) {
+					return
+				}
+			}
+		}
+	}
+	s.hashFiles(total, produce, read, func(r hashResult) {
+		f := &groups[r.group][r.index]
+		f.Hash, f.Err = r.hash, r.err
+		if left[r.group]--; left[r.group] == 0 {
+			complete(r.group)
+		}
+	})
+	s.logger.Info("хэширование завершено", "files", atomic.LoadInt64(&s.progress.filesHashed),
+		"duration", time.Since(start), "interrupted", s.Interrupted())
+	return regroupErr
+}
+
+// regroup делит группу кандидатов с посчитанными хэшами на итоговые группы
+func (s *Scanner) regroup(candidates []FileInfo) ([][]FileInfo, error) {
+	if s.config.Mode == ModeImage && s.config.Comparator == nil {
+		// Похожие картинки - близкие, а не равные хэши: группы ищутся кластеризацией
+		hashed := slices.DeleteFunc(slices.Clone(candidates), func(f FileInfo) bool { return f.Err != nil })
+		return s.splitSingletons(clusterImages(hashed, s.config.ImageDistance)), nil
+	}
+	finalGroups := make(map[string][]FileInfo)
+	for _, f := range candidates {
+		if f.Err != nil {
+			continue
+		}
+		if s.config.Comparator != nil { // Ключ Comparator записан на месте хэша
+			finalGroups[f.Hash] = append(finalGroups[f.Hash], f)
+			continue
+		}
+		var key string
+		switch s.config.Mode {
+		case ModeHash, ModeFastHash:
+			key = f.Hash
+		case ModeCombined:
+			key = fmt.Sprintf("%s|%s", s.nameKey(f.Name), f.Hash)
+		case ModeNameSizeHash:
+			key = fmt.Sprintf("%s|%s|%s", s.nameKey(f.Name), s.sizeKey(f), f.Hash)
+		case ModeNameHashDir:
+			key = fmt.Sprintf("%s|%s|%s", s.nameKey(f.Name), f.Hash, parentDirName(f))
+		default:
+			return nil, fmt.Errorf("режим %q не сравнивает хэши", s.config.Mode)
+		}
+		finalGroups[key] = append(finalGroups[key], f)
+	}
+	return s.splitSingletons(finalGroups), nil
+}
+
+// defaultJobsPerWorker - глубина очереди задач на воркера, если Config.JobBufferSize не задан
+const defaultJobsPerWorker = 4
+
+// jobBufferSize возвращает размер буфера канала задач хэширования
+func (s *Scanner) jobBufferSize() int {
+	if s.config.JobBufferSize > 0 {
+		return s.config.JobBufferSize
+	}
+	return defaultJobsPerWorker * s.config.Workers
+}
+
+// hashJob - задача воркеру: какой файл прочитать и где он лежит у вызывающего кода.
+// Воркер не получает указатель на FileInfo: результат он только отправляет обратно.
+type hashJob struct {
+	path  string
+	size  int64
+	group int // Индекс группы (или 0, если файлы в плоском списке)
+	index int // Индекс файла в группе (в списке)
+
+	progress *fileProgress // Прочитанная часть файла (задает воркер; nil - чтение не из hashFiles)
+}
+
+// readFunc - что воркер делает с файлом задачи: считает хэш содержимого или ключ Comparator
+type readFunc func(job hashJob) (string, error)
+
+// readHash - readFunc, считающая хэш файла функцией hashFn (см. hashFile)
+func (s *Scanner) readHash(hashFn hashFunc) readFunc {
+	return func(job hashJob) (string, error) {
+		return s.hashFile(job.path, job.size, countingHash(hashFn, job.progress))
+	}
+}
+
+// contentRead - readFunc полного хэша содержимого (contentHash). При Config.CheckpointDir
+// состояние хэша сохраняется, и прерванное чтение продолжается с того же места (см. ResumableHash).
+func (s *Scanner) contentRead() readFunc {
+	if s.config.CheckpointDir != "" && !s.config.ContentOnly {
+		return func(job hashJob) (string, error) {
+			return s.resumableHashFile(job.path, job.size, job.progress)
+		}
+	}
+	return s.readHash(s.contentHash())
+}
+
+// hashResult - итог задачи: хэш или ошибка (*ScanError)
+type hashResult struct {
+	hashJob
+	hash string
+	err  error
+}
+
+// hashFiles конкурентно считает хэши файлов (или то, что считает read).
+// Задачи выдает produce из отдельной горутины, пока воркеры уже считают:
+// send блокируется, когда очередь полна, и возвращает false после остановки.
+// total - общий объем файлов для прогресса.
+// Результаты воркеры отправляют в канал, а collect получает их по одному в горутине,
+// вызвавшей hashFiles, - общие данные вызывающего кода без блокировок меняет только она.
+// Файлы, не обработанные из-за остановки, результата не дают.
+func (s *Scanner) hashFiles(total int64, produce func(send func(hashJob) bool), read readFunc, collect func(hashResult)) {
+	s.startHashPhase(total)
+
+	// --- ПАТТЕРН WORKER POOL ---
+	//Создаем буферизированный канал
+	// небольшой буфер сглаживает раздачу, а память не растет с числом файлов
+	jobs := make(chan hashJob, s.jobBufferSize())
+	results := make(chan hashResult, s.jobBufferSize())
+	var wg sync.WaitGroup
+
+	// Отдельный семафор на чтение: на HDD воркеров может быть много,
+	// но с диска одновременно читает ограниченное число из них
+	var ioSem chan struct{}
+	// Корни внутри Config.FS - не пути ОС, тип диска по ним не определить
+	diskRoots := s.roots
+	if s.config.FS != nil {
+		diskRoots = nil
+	}
+	if limit := readLimit(s.config.DiskType, diskRoots); limit > 0 {
+		ioSem = make(chan struct{}, limit)
+	}
+
+	//Запускаем воркеров(портебителей)
+	for w := 0; w < s.config.Workers; w++ {
+		wg.Add(1)
+		go func(current *atomic.Value, reading *atomic.Pointer[fileProgress]) {
+			defer wg.Done() // Сработает, когда цикл for завершится
+
+			// ЦИКЛ ОБРАБОТКИ ЗАДАЧ:
+			// range по каналу работает до тех пор, пока канала не будет закрыт (Closed)
+			// ии в нем не закончатся данные
+			for job := range jobs {
+				s.waitIfPaused()
+				// После Interrupt оставшиеся задачи просто вычерпываются без чтения
+				if s.Interrupted() {
+					continue
+				}
+				if ioSem != nil {
+					ioSem <- struct{}{}
+				}
+				current.Store(job.path)
+				job.progress = &fileProgress{size: job.size}
+				reading.Store(job.progress)
+				hash, err := read(job)
+				reading.Store(nil) // До addBytes: иначе прочитанное на миг посчиталось бы дважды
+				current.Store("")
+				if ioSem != nil {
+					<-ioSem
+				}
+				if err != nil && s.ctx.Err() != nil && errors.Is(err, s.ctx.Err()) {
+					continue // Чтение оборвано отменой: файл не обработан, а не ошибочен
+				}
+				result := hashResult{hashJob: job, hash: hash}
+				if err != nil {
+					result.err = s.readFailed(job.path, err)
+				} else {
+					s.progress.addBytes(job.size)
+				}
+				atomic.AddInt64(&s.progress.filesHashed, 1)
+				results <- result
+			}
+			// Сюда мы попадаем ТОЛЬКО после того, как вызовется close(jobs)
+			// и воркер дочитает все, что осталось в канале.
+		}(&s.current[w], &s.reading[w])
+	}
+
+	// Отправляем задачи(производитель) из своей горутины: буфер меньше числа файлов,
+	// и отправка ждет, пока воркеры разберут очередь
+	go func() {
+		// ВАЖНО: Правильная остановка (Graceful Shutdown)
+		// Мы обязаны закрыть канал jobs, когда задачи закончились
+		// Это посылает сигнал всем воркерам: "Новых данных не будет, доделывайте текущие и выходите"
+		// Если забыть эту строчку, воркеры будут вечно ждать данных (deadlock)
+		defer close(jobs)
+		produce(func(job hashJob) bool {
+			if s.Interrupted() {
+				return false
+			}
+			jobs <- job
+			return true
+		})
+	}()
+
+	// Канал результатов закрывается, когда все воркеры закончили работу (wg.Done)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for result := range results {
+		collect(result)
+	}
+}
+
+// readFailed учитывает ошибку чтения файла (статистика, журнал, Config.OnError)
+// и возвращает ошибку для FileInfo.Err
+func (s *Scanner) readFailed(path string, err error) error {
+	if errors.Is(err, ErrFileChanged) {
+		// Не ошибка чтения: файл просто выпадает из поиска, а в итогах он отдельной строкой
+		atomic.AddInt64(&s.stats.ChangedFiles, 1)
+		s.logger.Warn("файл изменился после обхода, пропущен", "path", path)
+		return err
+	}
+	scanErr := &ScanError{Path: path, Op: OpRead, Err: err}
+	errors.As(err, &scanErr) // hashFile помечает ошибки открытия как OpOpen
+	if errors.Is(err, ErrReadTimeout) {
+		scanErr = &ScanError{Path: path, Op: OpTimeout, Err: ErrReadTimeout}
+		atomic.AddInt64(&s.stats.TimedOutFiles, 1)
+	}
+	s.recordError(path, scanErr.Op, scanErr.Err)
+	s.logger.Warn("не удалось прочитать файл", "path", path, "op", scanErr.Op, "err", scanErr.Err)
+	if s.config.OnError != nil {
+		s.config.OnError(path, scanErr.Err)
+	}
+	return scanErr
+}
+
+// hashFile открывает файл и считает его хэш функцией hashFn.
+// При временной ошибке (Config.ReadRetries) файл открывается заново и хэш считается с начала.
+// Если размер уже не тот, что при обходе (size), файл не читается: он попал не в ту
+// группу кандидатов, и возвращается ErrFileChanged. То же, если размер или время изменения
+// поменялись, пока файл читался (например, растущий журнал): хэш не соответствует ни старому
+// содержимому, ни новому. Открытие и чтение ограничены Config.FileTimeout (ErrReadTimeout,
+// без повторов).
+func (s *Scanner) hashFile(path string, size int64, hashFn hashFunc) (string, error) {
+	return s.withRetries(path, func() (string, error) {
+		return s.withFileTimeout(func(ctx context.Context) (string, error) {
+			file, err := s.openFile(path)
+			if err != nil {
+				return "", &ScanError{Path: path, Op: OpOpen, Err: err}
+			}
+			defer file.Close()
+			before, err := file.Stat()
+			if err == nil && before.Size() != size {
+				return "", &ScanError{Path: path, Op: OpChanged, Err: ErrFileChanged}
+			}
+			hash, err := hashFn(ctx, s.throttle(ctx, file))
+			if err != nil {
+				return "", err
+			}
+			if before != nil && changedSince(file, before) {
+				return "", &ScanError{Path: path, Op: OpChanged, Err: ErrFileChanged}
+			}
+			return hash, nil
+		})
+	})
+}
+
+// changedSince повторно узнает размер и время изменения открытого файла и сравнивает с before
+func changedSince(file fs.File, before fs.FileInfo) bool {
+	after, err := file.Stat()
+	return err == nil && (after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()))
+}
+
+// hashFunc считает хэш открытого файла; долгое чтение должно прерываться отменой ctx
+type hashFunc func(ctx context.Context, file fs.File) (string, error)
+
+// contentHash - хэш всего содержимого для группировки: SHA-256 или алгоритм из WithHasher,
+// а при Config.ContentOnly у медиафайлов - без метаданных
+func (s *Scanner) contentHash() hashFunc {
+	hashFn := computeHash
+	if s.newHash != nil {
+		hashFn = func(ctx context.Context, file fs.File) (string, error) {
+			return hashContent(ctx, file, s.newHash)
+		}
+	}
+	if s.config.ContentOnly {
+		return contentOnlyHash(hashFn)
+	}
+	return hashFn
+}
+
+// edgesHash - хэш размера и краев файла (fast_hash) тем же алгоритмом, что и contentHash
+func (s *Scanner) edgesHash() hashFunc {
+	if s.newHash == nil {
+		return computeFastHash
+	}
+	return func(ctx context.Context, file fs.File) (string, error) {
+		return hashEdges(ctx, file, s.newHash)
+	}
+}
+
+// ctxReader прерывает чтение, как только контекст отменен.
+// io.Copy читает буферами по 32 КБ, так что отмена срабатывает в пределах одного буфера.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// computeHash читает файл целиком и возвращает SHA-256 хэш
+// Удаленный файл (SSHFS) хэшируется на сервере, если там есть sha256sum.
+func computeHash(ctx context.Context, file fs.File) (string, error) {
+	if remote, ok := file.(remoteHasher); ok {
+		if hash, err := remote.RemoteSHA256(); !errors.Is(err, errNoRemoteHash) {
+			return hash, err
+		}
+	}
+	return hashContent(ctx, file, sha256.New)
+}
+
+// hashContent читает файл целиком и возвращает хэш алгоритмом newHash
+func hashContent(ctx context.Context, file fs.File, newHash func() hash.Hash) (string, error) {
+	hash := newHash()
+	if _, err := io.Copy(hash, ctxReader{ctx: ctx, r: file}); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fastHashBlock - сколько байт читается с начала и с конца файла в режиме fast_hash
+const fastHashBlock = 4096
+
+// computeFastHash хэширует размер файла, первые и последние 4 КБ.
+// Это быстрая эвристика: файлы, совпадающие по краям, но различные в середине,
+// попадут в одну группу (ложное срабатывание). Для точного ответа - Config.Verify.
+func computeFastHash(ctx context.Context, file fs.File) (string, error) {
+	return hashEdges(ctx, file, sha256.New)
+}
+
+// hashEdges - computeFastHash с алгоритмом newHash
+func hashEdges(ctx context.Context, file fs.File, newHash func() hash.Hash) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+
+	hash := newHash()
+	var sizeBuf [8]byte
+	binary.LittleEndian.PutUint64(sizeBuf[:], uint64(size))
+	hash.Write(sizeBuf[:])
+
+	if size <= 2*fastHashBlock {
+		// Маленький файл читаем целиком
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+	} else if ra, ok := file.(io.ReaderAt); ok {
+		if _, err := io.Copy(hash, io.NewSectionReader(ra, 0, fastHashBlock)); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(hash, io.NewSectionReader(ra, size-fastHashBlock, fastHashBlock)); err != nil {
+			return "", err
+		}
+	} else {
+		// Файлы некоторых fs.FS (например, сжатые записи zip) читаются только подряд:
+		// середину приходится прочитать и выбросить
+		if _, err := io.CopyN(hash, file, fastHashBlock); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(io.Discard, ctxReader{ctx: ctx, r: file}, size-2*fastHashBlock); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(hash, file, fastHashBlock); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
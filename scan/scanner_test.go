@@ -0,0 +1,1079 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// writeFiles создает в dir файлы с заданным содержимым (ключ - путь относительно dir)
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestScanner создает сканер с настройками по умолчанию для dir, измененными в configure
+func newTestScanner(t testing.TB, dir string, configure func(*Config)) *Scanner {
+	t.Helper()
+	cfg := DefaultConfig(dir)
+	if configure != nil {
+		configure(&cfg)
+	}
+	s, err := NewScanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRunCanceledReturnsResult(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "same", "b.txt": "same"})
+	s := newTestScanner(t, dir, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := s.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if result == nil {
+		t.Fatal("Run вернул nil вместо неполного результата")
+	}
+	if result.Stats.FilesPerRoot == nil {
+		t.Error("в неполном результате нет статистики")
+	}
+}
+
+func TestSizeCollisionNotDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":    "aaaa",
+		"b.txt":    "bbbb", // Тот же размер, другое содержимое
+		"c.txt":    "aaaa",
+		"long.txt": "длинный уникальный файл",
+	})
+
+	candidates, err := newTestScanner(t, dir, nil).Candidates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || len(candidates[0]) != 3 {
+		t.Fatalf("кандидаты %v, want одна группа из трех файлов по 4 байта", candidates)
+	}
+
+	result, err := newTestScanner(t, dir, nil).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 || len(result.Groups[0]) != 2 {
+		t.Fatalf("группы %v, want одна группа a.txt и c.txt", result.Groups)
+	}
+	for _, f := range result.Groups[0] {
+		if filepath.Base(f.Path) == "b.txt" {
+			t.Error("b.txt того же размера попал в группу с другим содержимым")
+		}
+	}
+}
+
+func TestFastHashMiddleDiffers(t *testing.T) {
+	dir := t.TempDir()
+	head, tail := strings.Repeat("h", fastHashBlock), strings.Repeat("t", fastHashBlock)
+	writeFiles(t, dir, map[string]string{
+		"a.bin": head + strings.Repeat("1", fastHashBlock) + tail,
+		"b.bin": head + strings.Repeat("2", fastHashBlock) + tail,
+	})
+
+	// fast_hash читает только края и заведомо считает файлы копиями
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.Mode = ModeFastHash }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 {
+		t.Fatalf("fast_hash: групп %d, want 1 (ложное совпадение по краям)", len(result.Groups))
+	}
+
+	// Перепроверка полным хэшем их разделяет
+	result, err = newTestScanner(t, dir, func(cfg *Config) {
+		cfg.Mode = ModeFastHash
+		cfg.Verify = true
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 0 {
+		t.Errorf("fast_hash с Verify: групп %d, want 0", len(result.Groups))
+	}
+}
+
+func TestConfigFSMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"photos/a.jpg":      {Data: []byte("одинаковое")},
+		"photos/copy/a.jpg": {Data: []byte("одинаковое")},
+		"photos/b.jpg":      {Data: []byte("другое")},
+	}
+	cfg := DefaultConfig("photos")
+	cfg.FS = fsys
+	s, err := NewScanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 {
+		t.Fatalf("групп %d, want 1", len(result.Groups))
+	}
+	var paths []string
+	for _, f := range result.Groups[0] {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"photos/a.jpg", "photos/copy/a.jpg"}; !slices.Equal(paths, want) {
+		t.Errorf("пути %q, want %q", paths, want)
+	}
+	if result.Stats.TotalFiles != 3 {
+		t.Errorf("TotalFiles = %d, want 3", result.Stats.TotalFiles)
+	}
+}
+
+func TestConsecutiveRunsIndependentStats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "same", "b.txt": "same", "c.txt": "unique"})
+	s := newTestScanner(t, dir, nil)
+
+	first, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"d.txt": "same"})
+	second, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Stats.TotalFiles != 3 || second.Stats.TotalFiles != 4 {
+		t.Errorf("TotalFiles: %d и %d, want 3 и 4", first.Stats.TotalFiles, second.Stats.TotalFiles)
+	}
+	if first.Stats.DuplicateGroups != 1 || second.Stats.DuplicateGroups != 1 {
+		t.Errorf("DuplicateGroups: %d и %d, want 1 и 1", first.Stats.DuplicateGroups, second.Stats.DuplicateGroups)
+	}
+	root := s.Roots()[0]
+	if got := second.Stats.FilesPerRoot[root]; got != 4 {
+		t.Errorf("FilesPerRoot второго запуска = %d, want 4", got)
+	}
+	// Статистика первого результата - копия: второй запуск ее не меняет
+	if first.Stats.TotalFiles != 3 || first.Stats.FilesPerRoot[root] != 3 {
+		t.Errorf("второй запуск изменил статистику первого: %+v", first.Stats)
+	}
+}
+
+// failingFS - fstest.MapFS, в которой файлы из fail не открываются
+type failingFS struct {
+	fstest.MapFS
+	fail map[string]bool
+}
+
+func (f failingFS) Open(name string) (fs.File, error) {
+	if f.fail[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.MapFS.Open(name)
+}
+
+func TestOnErrorOncePerFile(t *testing.T) {
+	fsys := failingFS{
+		MapFS: fstest.MapFS{
+			"a.txt": {Data: []byte("same")},
+			"b.txt": {Data: []byte("same")},
+			"c.txt": {Data: []byte("same")},
+			"d.txt": {Data: []byte("same")},
+		},
+		fail: map[string]bool{"b.txt": true, "d.txt": true},
+	}
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	cfg := DefaultConfig(".")
+	cfg.FS = fsys
+	cfg.Workers = 4
+	cfg.OnError = func(path string, err error) {
+		mu.Lock() // Вызывается из воркеров
+		defer mu.Unlock()
+		calls[path]++
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: ошибка %v, want ErrPermission", path, err)
+		}
+	}
+	s, err := NewScanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"b.txt": 1, "d.txt": 1}; !maps.Equal(calls, want) {
+		t.Errorf("вызовы OnError %v, want %v", calls, want)
+	}
+	if result.Stats.Errors != 2 || len(result.Groups) != 1 || len(result.Groups[0]) != 2 {
+		t.Errorf("ошибок %d, групп %v; want 2 ошибки и группу a.txt, c.txt", result.Stats.Errors, result.Groups)
+	}
+}
+
+func TestMaxGroups(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"big1": "самая большая группа", "big2": "самая большая группа",
+		"mid1": "средняя", "mid2": "средняя",
+		"small1": "м", "small2": "м",
+	})
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.MaxGroups = 2 }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 2 {
+		t.Fatalf("групп %d, want 2", len(result.Groups))
+	}
+	if result.Stats.DuplicateGroups != 3 {
+		t.Errorf("Stats.DuplicateGroups = %d, want 3 (все найденные)", result.Stats.DuplicateGroups)
+	}
+	// Ограничение - после сортировки: остаются группы, освобождающие больше места
+	if name := filepath.Base(result.Groups[1][0].Path); name != "mid1" {
+		t.Errorf("вторая группа начинается с %s, want mid1", name)
+	}
+}
+
+func TestMinGroupSize(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"pair1": "пара", "pair2": "пара",
+		"triple1": "тройка", "triple2": "тройка", "triple3": "тройка",
+	})
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.MinGroupSize = 3 }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 || len(result.Groups[0]) != 3 {
+		t.Fatalf("группы %v, want одна тройка", result.Groups)
+	}
+	if result.Stats.DuplicateGroups != 1 {
+		t.Errorf("Stats.DuplicateGroups = %d, want 1 (после фильтра)", result.Stats.DuplicateGroups)
+	}
+}
+
+func TestRunDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	// Группы с одинаковым размером и объемом лишних копий: порядок решают только пути
+	for i := range 20 {
+		content := fmt.Sprintf("группа %02d", i)
+		for _, sub := range []string{"x", "y", "z/deep"} {
+			files[fmt.Sprintf("%s/%02d.txt", sub, i)] = content
+		}
+	}
+	writeFiles(t, dir, files)
+
+	var outputs [][]byte
+	for range 3 {
+		result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.Workers = 8 }).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(result.Groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, data)
+	}
+	for i := 1; i < len(outputs); i++ {
+		if !bytes.Equal(outputs[0], outputs[i]) {
+			t.Fatalf("запуск %d дал другой результат:\n%s\n%s", i+1, outputs[0], outputs[i])
+		}
+	}
+}
+
+// Конкурентный путь Stream: воркеры, колбэк прогресса и опрос Status из другой горутины.
+// Ценен под go test -race.
+func TestStreamConcurrentProgress(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := range 200 {
+		files[fmt.Sprintf("d%d/%03d.txt", i%7, i)] = strings.Repeat(fmt.Sprint(i%40), 1000+i%40)
+	}
+	writeFiles(t, dir, files)
+
+	var progressCalls atomic.Int64
+	s, err := NewScannerE(dir, WithWorkers(8), WithProgress(func(p Progress) {
+		progressCalls.Add(1)
+		_ = p.BytesHashed
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = s.Status()
+				_ = s.Progress()
+			}
+		}
+	}()
+
+	groups, errc := s.Stream(context.Background())
+	var streamed [][]string
+	for group := range groups {
+		var paths []string
+		for _, f := range group {
+			paths = append(paths, f.Path)
+		}
+		streamed = append(streamed, paths)
+	}
+	close(stop)
+	<-polled
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	// Те же группы, что у Run, только в другом порядке
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.Workers = 8 }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want [][]string
+	for _, group := range result.Groups {
+		var paths []string
+		for _, f := range group {
+			paths = append(paths, f.Path)
+		}
+		want = append(want, paths)
+	}
+	slices.SortFunc(streamed, func(a, b []string) int { return slices.Compare(a, b) })
+	slices.SortFunc(want, func(a, b []string) int { return slices.Compare(a, b) })
+	if len(want) != 40 || !slices.EqualFunc(streamed, want, slices.Equal) {
+		t.Errorf("Stream дал %d групп, Run - %d (want 40)", len(streamed), len(want))
+	}
+	if progressCalls.Load() == 0 {
+		t.Error("колбэк прогресса не вызывался")
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"good.txt": "data", "copy.txt": "data", "bad.txt": "tampered"})
+	good, bad := filepath.Join(dir, "good.txt"), filepath.Join(dir, "bad.txt")
+	manifest := filepath.Join(t.TempDir(), "checksums.txt")
+	content := sha256Hex("data") + "  " + good + "\n" + sha256Hex("original") + "  " + bad + "\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestScanner(t, dir, nil)
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// good.txt и copy.txt - дубликаты с уже посчитанным хэшем, bad.txt читается заново;
+	// copy.txt в файле сумм нет, он не проверяется
+	files := slices.Concat(result.Groups...)
+	files = append(files, FileInfo{Path: bad, Size: int64(len("tampered"))})
+	mismatches, err := s.VerifyManifest(manifest, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChecksumMismatch{{Path: bad, Expected: sha256Hex("original"), Actual: sha256Hex("tampered")}}
+	if !slices.Equal(mismatches, want) {
+		t.Errorf("несовпадения %+v, want %+v", mismatches, want)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := range 20 {
+		files[fmt.Sprintf("a/%d.txt", i)] = fmt.Sprintf("содержимое %d", i)
+		files[fmt.Sprintf("b/%d.txt", i)] = fmt.Sprintf("содержимое %d", i)
+	}
+	writeFiles(t, dir, files)
+	s := newTestScanner(t, dir, nil)
+	s.Pause()
+
+	type runResult struct {
+		result *Result
+		err    error
+	}
+	done := make(chan runResult, 1)
+	go func() {
+		result, err := s.Run(context.Background())
+		done <- runResult{result, err}
+	}()
+
+	// На паузе сканирование стоит: файлы не обходятся и не хэшируются, Run не возвращается
+	select {
+	case <-done:
+		t.Fatal("Run завершился на паузе")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if p := s.Progress(); p.FilesSeen != 0 || p.FilesHashed != 0 || !s.Paused() {
+		t.Fatalf("на паузе прогресс %+v, Paused = %v", p, s.Paused())
+	}
+
+	s.Resume()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if len(r.result.Groups) != 20 {
+			t.Errorf("групп %d, want 20", len(r.result.Groups))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run не завершился после Resume")
+	}
+}
+
+func TestDirDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"orig/x.txt":     "x",
+		"orig/y.txt":     "y",
+		"orig/sub/z.txt": "z",
+		"copy/x.txt":     "x", // Копия всего дерева orig
+		"copy/y.txt":     "y",
+		"copy/sub/z.txt": "z",
+		"partial/x.txt":  "x",
+		"partial/u.txt":  "есть только здесь", // Поэтому partial - не копия
+		"subset/y.txt":   "y",                 // Все файлы есть в orig и copy, но не наоборот
+	})
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.DirDuplicates = true }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []DirDuplicate
+	for _, d := range result.DirDuplicates {
+		a, _ := filepath.Rel(dir, d.A)
+		b, _ := filepath.Rel(dir, d.B)
+		got = append(got, DirDuplicate{A: filepath.ToSlash(a), B: filepath.ToSlash(b), Files: d.Files, Mutual: d.Mutual})
+	}
+	want := []DirDuplicate{
+		{A: "copy", B: "orig", Files: 2, Mutual: true},
+		{A: "copy/sub", B: "orig/sub", Files: 1, Mutual: true},
+		{A: "subset", B: "copy", Files: 1},
+		{A: "subset", B: "orig", Files: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("папки-копии %+v, want %+v", got, want)
+	}
+}
+
+func TestLoggerEvents(t *testing.T) {
+	fsys := failingFS{
+		MapFS: fstest.MapFS{
+			"a/1.txt":   {Data: []byte("same")},
+			"a/2.txt":   {Data: []byte("same")},
+			"a/bad.txt": {Data: []byte("same")},
+		},
+		fail: map[string]bool{"a/bad.txt": true},
+	}
+	tests := []struct {
+		level slog.Level
+		want  map[string][]string // Сообщение -> ключи, которые в нем должны быть
+		never []string            // Сообщения, которых на этом уровне быть не должно
+	}{
+		{slog.LevelDebug, map[string][]string{
+			"обход корня":               {"root"},
+			"обход корня завершен":      {"root", "files"},
+			"кандидаты сгруппированы":   {"files", "groups", "candidates"},
+			"хэширование":               {"files", "groups"},
+			"хэширование завершено":     {"files", "duration", "interrupted"},
+			"не удалось прочитать файл": {"path", "op", "err"},
+		}, nil},
+		// Подробности обхода - только на уровне debug
+		{slog.LevelInfo, map[string][]string{
+			"хэширование завершено": {"files"},
+		}, []string{"обход корня", "обход корня завершен"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: tt.level}))
+			s, err := NewScanner(DefaultConfig("a"), WithFS(fsys), WithLogger(logger))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			events := make(map[string]map[string]any)
+			for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+				var event map[string]any
+				if err := json.Unmarshal(line, &event); err != nil {
+					t.Fatalf("%s: %v", line, err)
+				}
+				events[event["msg"].(string)] = event
+			}
+			for msg, keys := range tt.want {
+				event, ok := events[msg]
+				if !ok {
+					t.Errorf("нет события %q", msg)
+					continue
+				}
+				for _, key := range keys {
+					if _, ok := event[key]; !ok {
+						t.Errorf("%q: нет ключа %q в %v", msg, key, event)
+					}
+				}
+			}
+			for _, msg := range tt.never {
+				if _, ok := events[msg]; ok {
+					t.Errorf("событие %q на уровне %s", msg, tt.level)
+				}
+			}
+		})
+	}
+}
+
+func TestFileMetadataInGroups(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a": "same", "b": "same"})
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.IgnoreHardlinks = false }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 {
+		t.Fatalf("групп %d, want 1", len(result.Groups))
+	}
+	for _, f := range result.Groups[0] {
+		if !f.ModTime.Equal(mtime) || !f.Mode.IsRegular() {
+			t.Errorf("%s: mtime %v, mode %v", f.Path, f.ModTime, f.Mode)
+		}
+		if runtime.GOOS != "windows" && f.Mode.Perm() != 0o600 {
+			t.Errorf("%s: права %v, want 0600", f.Path, f.Mode.Perm())
+		}
+	}
+
+	// В JSON время изменения - в RFC 3339
+	data, err := json.Marshal(NewGroupRecord(1, result.Groups[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"mtime":"2021-03-04T05:06:07Z"`)) {
+		t.Errorf("нет mtime в RFC 3339: %s", data)
+	}
+}
+
+func TestOwnerFilter(t *testing.T) {
+	if !ownerSupported {
+		t.Skip("фильтр по владельцу не поддерживается на этой ОС")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"mine1": "same", "mine2": "same", "other": "same"})
+	uid := os.Getuid()
+	chowned := os.Lchown(filepath.Join(dir, "other"), uid+1, -1) == nil // Получится только у root
+	otherUID := uid + 1
+
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		wantFiles int   // Файлов в единственной группе (0 - групп нет)
+		wantOther int64 // Stats.OtherOwnerFiles
+	}{
+		{"чужой uid", func(cfg *Config) { cfg.OwnerUID = &otherUID }, 0, 2},
+		{"OnlyCurrentUser", func(cfg *Config) { cfg.OnlyCurrentUser = true }, 2, 1},
+	}
+	if !chowned {
+		// Без root все три файла свои
+		tests[0].wantOther, tests[1].wantFiles, tests[1].wantOther = 3, 3, 0
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newTestScanner(t, dir, tt.configure).Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := 0
+			if len(result.Groups) == 1 {
+				files = len(result.Groups[0])
+			}
+			if len(result.Groups) > 1 || files != tt.wantFiles {
+				t.Errorf("группы %v, want одна из %d файлов", result.Groups, tt.wantFiles)
+			}
+			if result.Stats.OtherOwnerFiles != tt.wantOther {
+				t.Errorf("OtherOwnerFiles = %d, want %d", result.Stats.OtherOwnerFiles, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestRunFilesMatchesRun(t *testing.T) {
+	files := map[string]string{
+		"a/1.txt": "one",
+		"b/1.txt": "one",
+		"a/2.txt": "two",
+		"b/2.txt": "twO", // Тот же размер, другое содержимое
+		"c/3.txt": "three",
+		"c/4.txt": "three",
+		"c/5.txt": "единственный",
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	result, err := newTestScanner(t, dir, nil).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := groupPaths(t, dir, result.Groups)
+	if len(want) != 2 {
+		t.Fatalf("Run: группы %q, want 2", want)
+	}
+
+	// Те же файлы в памяти: RunFiles читает их через Config.FS
+	fsys := fstest.MapFS{}
+	var list []FileInfo
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		list = append(list, FileInfo{Path: name, Name: filepath.Base(name), Size: int64(len(content))})
+	}
+	s, err := NewScanner(DefaultConfig("."), WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := s.RunFiles(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := groupPaths(t, ".", groups); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("RunFiles: группы %q, Run: %q", got, want)
+	}
+}
+
+func TestComparator(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.mp3": "HDR:0001музыка",
+		"b.mp3": "HDR:0002музыка", // Другой заголовок, то же содержимое
+		"c.mp3": "HDR:0001другое",
+		"d.mp3": "HDR:0003музыка и еще",
+	})
+	const headerSize = len("HDR:0001")
+	// Ключ - SHA-256 содержимого после заголовка фиксированной длины
+	stripHeader := ComparatorFunc(func(path string, _ FileInfo) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%x", sha256.Sum256(data[min(headerSize, len(data)):])), nil
+	})
+	result, err := newTestScanner(t, dir, func(cfg *Config) { cfg.Comparator = stripHeader }).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := groupPaths(t, dir, result.Groups), [][]string{{"a.mp3", "b.mp3"}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("группы %q, want %q", got, want)
+	}
+
+	// Встроенные режимы как Comparator дают те же группы, что и сами режимы
+	for _, mode := range []Mode{ModeNameOnly, ModeSizeOnly, ModeHash, ModeCombined} {
+		comparator, err := ModeComparator(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byMode, err := newTestScanner(t, dir, func(cfg *Config) { cfg.Mode = mode }).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		byComparator, err := newTestScanner(t, dir, func(cfg *Config) { cfg.Comparator = comparator }).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := groupPaths(t, dir, byComparator.Groups), groupPaths(t, dir, byMode.Groups); !slices.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("%s: Comparator дал %q, режим - %q", mode, got, want)
+		}
+	}
+}
+
+func TestProgressPercent(t *testing.T) {
+	tests := []struct {
+		name      string
+		p         Progress
+		noHashing bool
+		want      float64
+	}{
+		{"обход", Progress{Phase: PhaseWalking}, false, 0},
+		{"четверть", Progress{Phase: PhaseHashing, BytesHashed: 25, BytesTotal: 100}, false, 25},
+		{"треть", Progress{Phase: PhaseHashing, BytesHashed: 1, BytesTotal: 3}, false, 100.0 / 3},
+		{"перепроверка", Progress{Phase: PhaseVerifying, BytesHashed: 60, BytesTotal: 80}, false, 75},
+		{"файл вырос при чтении", Progress{Phase: PhaseHashing, BytesHashed: 120, BytesTotal: 100}, false, 100},
+		{"нечего читать", Progress{Phase: PhaseHashing}, false, 100},
+		{"режим без хэша", Progress{Phase: PhaseGrouping}, true, 100},
+		{"готово", Progress{Phase: PhaseDone, BytesHashed: 10, BytesTotal: 100}, false, 100},
+	}
+	for _, tt := range tests {
+		if got := percent(tt.p, tt.noHashing); got != tt.want {
+			t.Errorf("%s: percent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTotalCandidateBytes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a1": strings.Repeat("a", 10),
+		"a2": strings.Repeat("b", 10),
+		"b1": strings.Repeat("c", 20),
+		"b2": strings.Repeat("c", 20),
+		"u":  strings.Repeat("u", 33), // Размер уникален - не кандидат
+	})
+	tests := []struct {
+		mode Mode
+		want int64
+	}{
+		{ModeHash, 2*10 + 2*20},
+		{ModeNameSize, 0}, // Ничего не читается: сразу 100%
+	}
+	for _, tt := range tests {
+		s := newTestScanner(t, dir, func(cfg *Config) { cfg.Mode = tt.mode })
+		result, err := s.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Stats.TotalCandidateBytes != tt.want {
+			t.Errorf("%s: TotalCandidateBytes = %d, want %d", tt.mode, result.Stats.TotalCandidateBytes, tt.want)
+		}
+		if p := s.Progress(); p.Percent != 100 || p.BytesHashed != tt.want {
+			t.Errorf("%s: после Run прогресс %+v, want 100%% и %d байт", tt.mode, p, tt.want)
+		}
+	}
+}
+
+// readCountingFile считает прочитанные байты (общий счетчик на всю файловую систему)
+type readCountingFile struct {
+	fs.File
+	n *atomic.Int64
+}
+
+func (f readCountingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.n.Add(int64(n))
+	return n, err
+}
+
+// readCountingFS - fstest.MapFS, которая считает байты, прочитанные из всех файлов
+type readCountingFS struct {
+	fstest.MapFS
+	n *atomic.Int64
+}
+
+func (f readCountingFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readCountingFile{File: file, n: f.n}, nil
+}
+
+func TestCompareStopsAtFirstDifference(t *testing.T) {
+	const size = 3 * compareBlockSize
+	fsys := readCountingFS{MapFS: fstest.MapFS{}, n: new(atomic.Int64)}
+	for _, first := range []string{"a", "b", "c"} {
+		// Файлы одного размера различаются уже в первом блоке
+		data := []byte(first + strings.Repeat("x", size-1))
+		fsys.MapFS[first+".bin"] = &fstest.MapFile{Data: data}
+	}
+	cfg := DefaultConfig(".")
+	cfg.Mode = ModeCompare
+	s, err := NewScanner(cfg, WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 0 {
+		t.Errorf("группы %v, want ни одной", result.Groups)
+	}
+	if got := fsys.n.Load(); got != 3*compareBlockSize {
+		t.Errorf("прочитано %d байт, want %d (по первому блоку каждого файла)", got, 3*compareBlockSize)
+	}
+}
+
+func TestHashAll(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a/copy.txt":   "копия",
+		"b/copy.txt":   "копия",
+		"unique.txt":   "файл уникального размера",
+		"sub/deep.bin": "еще один",
+	}
+	writeFiles(t, dir, files)
+
+	tests := []struct {
+		name    string
+		hashAll bool
+	}{
+		{"HashAll", true},
+		{"без HashAll", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, dir, func(cfg *Config) { cfg.HashAll = tt.hashAll })
+			result, err := s.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Groups) != 1 {
+				t.Errorf("групп %d, want 1", len(result.Groups))
+			}
+			all := s.AllFiles()
+			if !tt.hashAll {
+				if len(all) != 0 {
+					t.Errorf("AllFiles без HashAll: %d файлов, want пусто", len(all))
+				}
+				return
+			}
+			if len(all) != len(files) {
+				t.Fatalf("AllFiles: %d файлов, want %d", len(all), len(files))
+			}
+			for _, f := range all {
+				rel, _ := filepath.Rel(dir, f.Path)
+				if want := sha256Hex(files[filepath.ToSlash(rel)]); f.Hash != want {
+					t.Errorf("%s: хэш %q, want %q", rel, f.Hash, want)
+				}
+			}
+		})
+	}
+}
+
+// growingFS - fstest.MapFS, где файл grow дописывается после обхода: при открытии
+// (during == false) или когда его дочитали до конца (during == true)
+type growingFS struct {
+	fstest.MapFS
+	grow   string
+	during bool
+}
+
+func (f growingFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if err != nil || name != f.grow {
+		return file, err
+	}
+	return &growingFile{File: file, grown: !f.during}, nil
+}
+
+// growingFile после роста сообщает размер на байт больше
+type growingFile struct {
+	fs.File
+	grown bool
+}
+
+func (f *growingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if err == io.EOF {
+		f.grown = true
+	}
+	return n, err
+}
+
+func (f *growingFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil || !f.grown {
+		return info, err
+	}
+	return grownInfo{info}, nil
+}
+
+type grownInfo struct{ fs.FileInfo }
+
+func (i grownInfo) Size() int64 { return i.FileInfo.Size() + 1 }
+
+func TestFileGrowsBeforeHashing(t *testing.T) {
+	tests := []struct {
+		name   string
+		during bool
+	}{
+		{"вырос до чтения", false},
+		{"вырос во время чтения", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := growingFS{MapFS: fstest.MapFS{
+				"a.log": {Data: []byte("строки журнала")},
+				"b.log": {Data: []byte("строки журнала")},
+				"c.log": {Data: []byte("строки журнала")},
+			}, grow: "c.log", during: tt.during}
+			s, err := NewScanner(DefaultConfig("."), WithFS(fsys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := s.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Groups) != 1 || len(result.Groups[0]) != 2 {
+				t.Fatalf("группы %v, want одна без c.log", result.Groups)
+			}
+			for _, f := range result.Groups[0] {
+				if f.Path == "c.log" {
+					t.Error("изменившийся c.log попал в группу")
+				}
+			}
+			if stats := s.GetStats(); stats.ChangedFiles != 1 || stats.Errors != 0 {
+				t.Errorf("ChangedFiles %d, Errors %d; want 1 и 0", stats.ChangedFiles, stats.Errors)
+			}
+		})
+	}
+}
+
+func TestFindPrefixes(t *testing.T) {
+	full := make([]byte, 100<<10)
+	for i := range full {
+		full[i] = byte(i * 7 % 251)
+	}
+	changed := slices.Clone(full[:50<<10])
+	changed[30<<10]++ // Совпадает с full в первом блоке, но не дальше
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		off   bool     // FindPrefixes выключен
+		want  []string // Пары "недокачанный -> целый"
+	}{
+		{"обрезанная копия", map[string][]byte{"full.iso": full, "full.iso.part": full[:50<<10]},
+			false, []string{"full.iso.part -> full.iso"}},
+		{"без FindPrefixes", map[string][]byte{"full.iso": full, "full.iso.part": full[:50<<10]},
+			true, nil},
+		{"расходится после первого блока", map[string][]byte{"full.iso": full, "other.bin": changed},
+			false, nil},
+		{"меньше prefixBlock", map[string][]byte{"full.iso": full, "tiny.part": full[:prefixBlock-1]},
+			false, nil},
+		// Каждый недокачанный файл - в паре с самым большим целым
+		{"несколько обрезков", map[string][]byte{"full.iso": full, "a.part": full[:20<<10], "b.part": full[:50<<10]},
+			false, []string{"a.part -> full.iso", "b.part -> full.iso"}},
+		// Файлы одного размера - обычные дубликаты, а не недокачанные
+		{"одинаковый размер", map[string][]byte{"full.iso": full, "copy.iso": full},
+			false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: data}
+			}
+			cfg := DefaultConfig(".")
+			cfg.FindPrefixes = !tt.off
+			s, err := NewScanner(cfg, WithFS(fsys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := s.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range result.PrefixMatches {
+				got = append(got, m.Partial.Path+" -> "+m.Complete.Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("PrefixMatches %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"file.txt": "не папка", "ok/a.txt": "копия", "ok/b.txt": "копия"})
+	fsys := fstest.MapFS{"file.txt": {Data: []byte("не папка")}}
+
+	tests := []struct {
+		name    string
+		root    string
+		fsys    fs.FS // nil - диск ОС
+		wantErr error
+	}{
+		{"нет пути", filepath.Join(dir, "missing"), nil, ErrRootNotFound},
+		{"файл вместо папки", filepath.Join(dir, "file.txt"), nil, ErrRootNotDir},
+		{"fs.FS: нет пути", "missing", fsys, ErrRootNotFound},
+		{"fs.FS: файл вместо папки", "file.txt", fsys, ErrRootNotDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.fsys != nil {
+				opts = append(opts, WithFS(tt.fsys))
+			}
+			s, err := NewScanner(DefaultConfig(tt.root), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.Run(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run: %v, want %v", err, tt.wantErr)
+			}
+			var rootErr *RootError
+			if !errors.As(err, &rootErr) || rootErr.Path != tt.root {
+				t.Errorf("Run: %#v, want *RootError с путем %s", err, tt.root)
+			}
+			other := ErrRootNotDir
+			if tt.wantErr == ErrRootNotDir {
+				other = ErrRootNotFound
+			}
+			if errors.Is(err, other) {
+				t.Errorf("Run: %v - одновременно и %v", err, other)
+			}
+		})
+	}
+
+	// SkipMissingRoots: плохой корень - запись в Errors, остальные корни сканируются
+	s := newTestScanner(t, "", func(cfg *Config) {
+		cfg.Roots = []string{filepath.Join(dir, "missing"), filepath.Join(dir, "ok")}
+		cfg.SkipMissingRoots = true
+	})
+	result, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 || len(result.Errors) != 1 || !errors.Is(result.Errors[0].Err, ErrRootNotFound) {
+		t.Errorf("групп %d, ошибки %v; want 1 группу и ErrRootNotFound", len(result.Groups), result.Errors)
+	}
+}
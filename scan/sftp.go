@@ -0,0 +1,459 @@
+// Удаленные директории по SSH: корни sftp://user@host/path рядом с локальными
+package scan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sftpScheme - приставка удаленного корня
+const sftpScheme = "sftp://"
+
+// DefaultSSHSessions - сколько команд одновременно выполняется на одном сервере
+// (sshd по умолчанию разрешает 10 сессий на соединение)
+const DefaultSSHSessions = 4
+
+// sshChunkSize - сколько байт удаленного файла передается одной командой.
+// Команда не держит сессию между чтениями, так что открытых файлов может быть сколько угодно.
+const sshChunkSize = 4 << 20
+
+// errNoRemoteHash - на сервере нет sha256sum, содержимое придется передать и хэшировать здесь
+var errNoRemoteHash = errors.New("на сервере нет sha256sum")
+
+// SSHConfig - как подключаться к серверам удаленных корней. Подключается системный клиент ssh:
+// ключи, агент, ~/.ssh/config и known_hosts работают как обычно, а пароль не спрашивается
+// (BatchMode) - нужен вход по ключу. На сервере нужны find (GNU), tail и head.
+type SSHConfig struct {
+	Command      string   // Клиент ssh (пусто - "ssh" из PATH)
+	Options      []string // Дополнительные аргументы клиента, например {"-i", "~/.ssh/backup_key"}
+	Sessions     int      // Команд одновременно на один сервер (0 - DefaultSSHSessions)
+	NoRemoteHash bool     // Не считать SHA-256 на сервере (sha256sum), а передавать содержимое
+}
+
+// remoteHasher - файл, SHA-256 которого считается там, где он лежит: содержимое не передается
+type remoteHasher interface {
+	RemoteSHA256() (string, error)
+}
+
+// SSHFS - файловая система сервера по SSH. Пути - абсолютные пути сервера без ведущего "/"
+// ("." - корень сервера). Дерево корня перечисляется одной командой find, файлы читаются
+// кусками по sshChunkSize, а в режимах с полным хэшем SHA-256 считается на сервере.
+// Команд одновременно - не больше SSHConfig.Sessions: медленный канал не забивается.
+type SSHFS struct {
+	cfg    SSHConfig
+	target string // user@host
+	port   string
+	sem    chan struct{}
+
+	mu       sync.Mutex
+	entries  map[string]sshEntry      // Путь -> сведения из find
+	children map[string][]fs.DirEntry // Директория -> содержимое
+	listed   map[string]bool          // Директории, поддерево которых уже перечислено
+
+	noRemoteHash atomic.Bool // sha256sum не нашелся: больше не пробуем
+}
+
+// sshEntry - файл или директория из вывода find
+type sshEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (e sshEntry) Name() string { return e.name }
+func (e sshEntry) Size() int64  { return e.size }
+func (e sshEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+func (e sshEntry) ModTime() time.Time { return e.modTime }
+func (e sshEntry) IsDir() bool        { return e.dir }
+func (e sshEntry) Sys() any           { return nil }
+
+// NewSSHFS создает файловую систему сервера target ("user@host" или "host") на порту port (пусто - 22)
+func NewSSHFS(cfg SSHConfig, target, port string) *SSHFS {
+	if cfg.Command == "" {
+		cfg.Command = "ssh"
+	}
+	if cfg.Sessions <= 0 {
+		cfg.Sessions = DefaultSSHSessions
+	}
+	return &SSHFS{
+		cfg:      cfg,
+		target:   target,
+		port:     port,
+		sem:      make(chan struct{}, cfg.Sessions),
+		entries:  make(map[string]sshEntry),
+		children: make(map[string][]fs.DirEntry),
+		listed:   make(map[string]bool),
+	}
+}
+
+// parseSFTPRoot разбирает корень "sftp://user@host:port/path": префикс путей
+// ("sftp://user@host:port"), сервер, порт и путь внутри SSHFS
+func parseSFTPRoot(root string) (prefix, target, port, dir string, ok bool) {
+	if !strings.HasPrefix(root, sftpScheme) {
+		return "", "", "", "", false
+	}
+	u, err := url.Parse(root)
+	if err != nil || u.Hostname() == "" {
+		return "", "", "", "", false
+	}
+	target = u.Hostname()
+	if u.User != nil {
+		target = u.User.Username() + "@" + target
+	}
+	dir = strings.Trim(path.Clean("/"+u.Path), "/")
+	if dir == "" {
+		dir = "."
+	}
+	return sftpScheme + strings.TrimSuffix(target, u.Hostname()) + u.Host, target, u.Port(), dir, true
+}
+
+// remoteFS ищет файловую систему сервера, на котором лежит path, и путь внутри нее
+func (s *Scanner) remoteFS(name string) (*SSHFS, string, bool) {
+	if s.remotes == nil || !strings.HasPrefix(name, sftpScheme) {
+		return nil, "", false
+	}
+	for prefix, fsys := range s.remotes {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && (rest == "" || rest[0] == '/') {
+			rest = strings.Trim(rest, "/")
+			if rest == "" {
+				rest = "."
+			}
+			return fsys, rest, true
+		}
+	}
+	return nil, "", false
+}
+
+// openRemotes готовит серверы удаленных корней (sftp://...); по одному SSHFS на сервер
+func (s *Scanner) openRemotes(roots []string) error {
+	for _, root := range roots {
+		if !strings.HasPrefix(root, sftpScheme) {
+			continue
+		}
+		prefix, target, port, _, ok := parseSFTPRoot(root)
+		if !ok {
+			return fmt.Errorf("некорректный удаленный корень %q (нужно sftp://user@host/path)", root)
+		}
+		if s.config.FS != nil {
+			return fmt.Errorf("удаленный корень %s: с Config.FS корни - пути внутри нее", root)
+		}
+		if s.remotes == nil {
+			s.remotes = make(map[string]*SSHFS)
+		}
+		if s.remotes[prefix] == nil {
+			cfg := SSHConfig{}
+			if s.config.SSH != nil {
+				cfg = *s.config.SSH
+			}
+			s.remotes[prefix] = NewSSHFS(cfg, target, port)
+		}
+	}
+	return nil
+}
+
+// walkRemote обходит удаленный корень; в fn пути приходят в виде sftp://...
+func (s *Scanner) walkRemote(fsys *SSHFS, root, dir string, fn fs.WalkDirFunc) error {
+	prefix, _, _, _, _ := parseSFTPRoot(root)
+	return fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if name == "." {
+			return fn(prefix+"/", d, err)
+		}
+		return fn(prefix+"/"+name, d, err)
+	})
+}
+
+// forget сбрасывает перечисленные деревья: следующий запуск увидит сервер заново
+func (fsys *SSHFS) forget() {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	clear(fsys.entries)
+	clear(fsys.children)
+	clear(fsys.listed)
+}
+
+// args - аргументы клиента ssh перед командой
+func (fsys *SSHFS) args() []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if runtime.GOOS != "windows" { // OpenSSH для Windows не умеет мультиплексирование
+		// Одно соединение на все команды: без него каждая платила бы за рукопожатие
+		args = append(args, "-o", "ControlMaster=auto", "-o", "ControlPersist=60",
+			"-o", "ControlPath="+filepath.Join(os.TempDir(), "duplifinder-ssh-%C"))
+	}
+	if fsys.port != "" {
+		args = append(args, "-p", fsys.port)
+	}
+	args = append(args, fsys.cfg.Options...)
+	return append(args, "--", fsys.target)
+}
+
+// sshError - неудачная команда на сервере
+type sshError struct {
+	command string
+	code    int
+	stderr  string
+}
+
+func (e *sshError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("ssh: %s: код %d", e.command, e.code)
+	}
+	return fmt.Sprintf("ssh: %s: %s", e.command, e.stderr)
+}
+
+// Is сводит ошибки сервера к ошибкам fs по тексту ошибки (он от GNU coreutils)
+func (e *sshError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return strings.Contains(e.stderr, "No such file")
+	case fs.ErrPermission:
+		return e.code != 255 && strings.Contains(e.stderr, "Permission denied")
+	}
+	return false
+}
+
+// Timeout: код 255 - ssh не смог соединиться; как и таймаут, это может пройти само (см. withRetries)
+func (e *sshError) Timeout() bool {
+	return e.code == 255
+}
+
+// run выполняет команду на сервере и возвращает ее вывод
+func (fsys *SSHFS) run(command string) ([]byte, error) {
+	fsys.sem <- struct{}{}
+	defer func() { <-fsys.sem }()
+	cmd := exec.Command(fsys.cfg.Command, append(fsys.args(), command)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err // Клиента ssh нет или он не запустился
+		}
+		return out, &sshError{command: command, code: exitErr.ExitCode(), stderr: strings.TrimSpace(stderr.String())}
+	}
+	return out, nil
+}
+
+// remotePath возвращает абсолютный путь на сервере
+func remotePath(name string) string {
+	if name == "." {
+		return "/"
+	}
+	return "/" + name
+}
+
+// shellQuote экранирует аргумент для shell на сервере
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// list перечисляет поддерево name одной командой find, если его еще не перечисляли
+func (fsys *SSHFS) list(name string) error {
+	fsys.mu.Lock()
+	for dir := name; ; dir = path.Dir(dir) {
+		if fsys.listed[dir] {
+			fsys.mu.Unlock()
+			return nil
+		}
+		if dir == "." {
+			break
+		}
+	}
+	fsys.mu.Unlock()
+
+	out, err := fsys.run("find " + shellQuote(remotePath(name)) + ` -printf '%y %s %T@ %P\0'`)
+	if len(out) == 0 && err != nil {
+		return err
+	}
+	// Ошибка find с выводом - обычно нечитаемые поддиректории: остальное дерево годится
+
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	for _, record := range bytes.Split(out, []byte{0}) {
+		fields := strings.SplitN(string(record), " ", 4)
+		if len(fields) < 4 || (fields[0] != "f" && fields[0] != "d") {
+			continue // Симлинки, устройства и прочее не файлы
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		seconds, _ := strconv.ParseFloat(fields[2], 64)
+		full := name
+		if fields[3] != "" {
+			full = path.Join(name, fields[3])
+		}
+		if !fs.ValidPath(full) {
+			continue
+		}
+		e := sshEntry{name: path.Base(full), size: size, modTime: time.Unix(0, int64(seconds*1e9)), dir: fields[0] == "d"}
+		fsys.entries[full] = e
+		if full != name {
+			parent := path.Dir(full)
+			fsys.children[parent] = append(fsys.children[parent], fs.FileInfoToDirEntry(e))
+		}
+	}
+	fsys.listed[name] = true
+	if _, ok := fsys.entries[name]; !ok {
+		return fs.ErrNotExist
+	}
+	return nil
+}
+
+// Stat возвращает сведения о файле или директории
+func (fsys *SSHFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := fsys.list(name); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	e, ok := fsys.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// ReadDir возвращает содержимое директории по порядку имен
+func (fsys *SSHFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("не директория")}
+	}
+	fsys.mu.Lock()
+	entries := slices.Clone(fsys.children[name]) // Сортировка не должна трогать общий кэш
+	fsys.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Open открывает файл для чтения (директории открываются только для Stat)
+func (fsys *SSHFS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+	return &sshFile{fsys: fsys, name: name, info: info.(sshEntry)}, nil
+}
+
+// sshFile - открытый удаленный файл: читается кусками, каждый - отдельной командой
+type sshFile struct {
+	fsys   *SSHFS
+	name   string
+	info   sshEntry
+	offset int64
+	buf    []byte // Прочитанный, но еще не отданный остаток куска
+}
+
+func (f *sshFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *sshFile) Close() error               { return nil }
+
+func (f *sshFile) Read(p []byte) (int, error) {
+	if f.info.dir {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.New("это директория")}
+	}
+	if len(f.buf) == 0 {
+		if f.offset >= f.info.size {
+			return 0, io.EOF
+		}
+		chunk, err := f.chunk(f.offset, min(sshChunkSize, f.info.size-f.offset))
+		if err != nil {
+			return 0, err
+		}
+		f.buf = chunk
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *sshFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.info.size {
+		return 0, io.EOF
+	}
+	n := min(int64(len(p)), f.info.size-off)
+	chunk, err := f.chunk(off, n)
+	if err != nil {
+		return 0, err
+	}
+	copy(p, chunk)
+	if n < int64(len(p)) {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+func (f *sshFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset, f.buf = offset, nil
+	return offset, nil
+}
+
+// chunk передает n байт файла начиная с off. Меньше байт - файл укоротился после обхода.
+func (f *sshFile) chunk(off, n int64) ([]byte, error) {
+	out, err := f.fsys.run(fmt.Sprintf("tail -c +%d -- %s | head -c %d", off+1, shellQuote(remotePath(f.name)), n))
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	if int64(len(out)) != n {
+		return nil, &ScanError{Path: f.name, Op: OpChanged, Err: ErrFileChanged}
+	}
+	return out, nil
+}
+
+// RemoteSHA256 считает SHA-256 файла на сервере, не передавая содержимое.
+// errNoRemoteHash - sha256sum на сервере нет (или он отключен SSHConfig.NoRemoteHash).
+func (f *sshFile) RemoteSHA256() (string, error) {
+	if f.fsys.cfg.NoRemoteHash || f.fsys.noRemoteHash.Load() {
+		return "", errNoRemoteHash
+	}
+	out, err := f.fsys.run("sha256sum -- " + shellQuote(remotePath(f.name)))
+	var sshErr *sshError
+	if errors.As(err, &sshErr) && sshErr.code == 127 { // command not found
+		f.fsys.noRemoteHash.Store(true)
+		return "", errNoRemoteHash
+	}
+	if err != nil {
+		return "", &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	hash, _, _ := strings.Cut(string(out), " ")
+	hash = strings.TrimPrefix(hash, `\`) // sha256sum помечает так пути с переводом строки
+	if len(hash) != 64 {
+		return "", fmt.Errorf("ssh: неожиданный вывод sha256sum: %q", out)
+	}
+	return hash, nil
+}
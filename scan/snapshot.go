@@ -0,0 +1,116 @@
+// Снимок прошлого запуска: инкрементальный поиск без повторного чтения неизменившихся файлов
+package scan
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// Snapshot - размеры, время изменения и полные хэши (SHA-256) файлов, прочитанных прошлыми запусками.
+// Файл, у которого размер и время изменения те же, при DeltaRun не читается: хэш берется из снимка.
+// На диске снимок хранится в формате манифеста, так что его можно передать и в Config.Manifests.
+type Snapshot struct {
+	Roots   []string                 // Корни запуска, снявшего снимок
+	Entries map[string]ManifestEntry // Записи по пути файла
+}
+
+// LoadSnapshot загружает снимок, сохраненный SaveSnapshot (или манифест из WriteManifest)
+func LoadSnapshot(path string) (*Snapshot, error) {
+	header, entries, err := readManifestEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Roots: header.Roots, Entries: make(map[string]ManifestEntry, len(entries))}
+	for _, e := range entries {
+		snap.Entries[e.Path] = e
+	}
+	return snap, nil
+}
+
+// SaveSnapshot сохраняет снимок в формате манифеста, записи упорядочены по пути
+func SaveSnapshot(path string, snap *Snapshot) error {
+	entries := make([]ManifestEntry, 0, len(snap.Entries))
+	for _, e := range snap.Entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	_, err := writeManifestEntries(path, snap.Roots, entries)
+	return err
+}
+
+// lookup возвращает хэш файла из снимка, если размер и время изменения не поменялись
+func (snap *Snapshot) lookup(f FileInfo) (ManifestEntry, bool) {
+	e, ok := snap.Entries[f.Path]
+	return e, ok && e.Size == f.Size && e.ModTime.Equal(f.ModTime)
+}
+
+// deltaState - снимки текущего DeltaRun: прошлый (откуда берутся хэши) и новый
+type deltaState struct {
+	prev, next *Snapshot
+	reused     int // Сколько хэшей взято из прошлого снимка
+}
+
+// DeltaRun - Run, который не читает файлы, не изменившиеся со снимка prev (nil - пустой снимок).
+// Результат - полный набор дубликатов, как у Run. Вместе с ним возвращается новый снимок:
+// записи prev для файлов, которые есть на диске и не изменились, и хэши, посчитанные сейчас.
+// Удаленные и изменившиеся файлы в новый снимок не попадают.
+//
+// Снимок хранит полный SHA-256: в режиме fast_hash он используется только при перепроверке
+// (Config.Verify), с ContentOnly не используется (хэши другие), а с WithHasher DeltaRun не работает.
+func (s *Scanner) DeltaRun(ctx context.Context, prev *Snapshot) (*Result, *Snapshot, error) {
+	if s.newHash != nil {
+		return nil, nil, errors.New("снимок хранит SHA-256: инкрементальный поиск несовместим с WithHasher")
+	}
+	if prev == nil {
+		prev = &Snapshot{}
+	}
+	s.delta = &deltaState{prev: prev, next: &Snapshot{Roots: s.roots, Entries: make(map[string]ManifestEntry)}}
+	defer func() { s.delta = nil }()
+
+	result, err := s.Run(ctx)
+	s.logger.Info("хэши из снимка", "files", s.delta.reused, "entries", len(s.delta.next.Entries))
+	return result, s.delta.next, err
+}
+
+// carrySnapshot переносит в новый снимок записи прошлого для найденных при обходе неизменившихся файлов
+func (s *Scanner) carrySnapshot(files []FileInfo) {
+	if s.delta == nil {
+		return
+	}
+	for _, f := range files {
+		if e, ok := s.delta.prev.lookup(f); ok && !f.fromManifest {
+			s.delta.next.Entries[f.Path] = e
+		}
+	}
+}
+
+// reuseHashes берет полные хэши неизменившихся файлов из снимка: такие файлы не читаются
+func (s *Scanner) reuseHashes(groups [][]FileInfo) {
+	if s.delta == nil {
+		return
+	}
+	for i := range groups {
+		for j := range groups[i] {
+			f := &groups[i][j]
+			if e, ok := s.delta.prev.lookup(*f); ok && !f.fromManifest {
+				f.Hash, f.fromSnapshot = e.Hash, true
+				s.delta.reused++
+			}
+		}
+	}
+}
+
+// rememberHashes записывает в новый снимок полные хэши, посчитанные этим запуском
+func (s *Scanner) rememberHashes(groups [][]FileInfo) {
+	if s.delta == nil {
+		return
+	}
+	for _, group := range groups {
+		for _, f := range group {
+			if f.Hash != "" && f.Err == nil && !f.fromManifest {
+				s.delta.next.Entries[f.Path] = ManifestEntry{Path: f.Path, Size: f.Size, ModTime: f.ModTime, Hash: f.Hash}
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// openCountingFS - fstest.MapFS, которая считает открытия каждого файла
+type openCountingFS struct {
+	fstest.MapFS
+	mu    *sync.Mutex
+	opens map[string]int
+}
+
+func (f openCountingFS) Open(name string) (fs.File, error) {
+	if file, ok := f.MapFS[name]; ok && !file.Mode.IsDir() {
+		f.mu.Lock()
+		f.opens[name]++
+		f.mu.Unlock()
+	}
+	return f.MapFS.Open(name)
+}
+
+func TestDeltaRunSkipsUnchanged(t *testing.T) {
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := openCountingFS{
+		MapFS: fstest.MapFS{
+			"data/a.txt": {Data: []byte("same"), ModTime: mtime},
+			"data/b.txt": {Data: []byte("same"), ModTime: mtime},
+			"data/c.txt": {Data: []byte("diff"), ModTime: mtime},
+		},
+		mu:    &sync.Mutex{},
+		opens: make(map[string]int),
+	}
+	run := func(prev *Snapshot) (*Result, *Snapshot) {
+		t.Helper()
+		cfg := DefaultConfig("data")
+		cfg.FS = fsys
+		s, err := NewScanner(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, snap, err := s.DeltaRun(context.Background(), prev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, snap
+	}
+
+	_, snap := run(nil)
+	if want := map[string]int{"data/a.txt": 1, "data/b.txt": 1, "data/c.txt": 1}; !maps.Equal(fsys.opens, want) {
+		t.Fatalf("первый запуск: открытия %v, want %v", fsys.opens, want)
+	}
+	// Снимок переживает сохранение на диск
+	path := filepath.Join(t.TempDir(), "snapshot")
+	if err := SaveSnapshot(path, snap); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// c.txt изменился (новое содержимое того же размера и новое время): читается только он
+	fsys.MapFS["data/c.txt"] = &fstest.MapFile{Data: []byte("same"), ModTime: mtime.Add(time.Hour)}
+	clear(fsys.opens)
+	result, next := run(snap)
+	if want := map[string]int{"data/c.txt": 1}; !maps.Equal(fsys.opens, want) {
+		t.Errorf("второй запуск: открытия %v, want только %v", fsys.opens, want)
+	}
+	if len(result.Groups) != 1 || len(result.Groups[0]) != 3 {
+		t.Errorf("группы %v, want одна из трех файлов", result.Groups)
+	}
+	if len(next.Entries) != 3 {
+		t.Errorf("в новом снимке %d записей, want 3", len(next.Entries))
+	}
+}
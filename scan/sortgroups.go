@@ -0,0 +1,82 @@
+// Детерминированный порядок результата: групп по важности и файлов внутри группы по пути
+package scan
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Поддерживаемые значения Config.SortBy
+const (
+	SortReclaimable = "reclaimable" // По освобождаемому месту: размер * (копий - 1), по убыванию
+	SortSize        = "size"        // По размеру файла, по убыванию
+	SortCount       = "count"       // По числу файлов в группе, по убыванию
+	SortPath        = "path"        // По пути первого файла, по алфавиту
+)
+
+// validateSortBy проверяет значение Config.SortBy (пустое равносильно reclaimable)
+func validateSortBy(by string) error {
+	switch by {
+	case "", SortReclaimable, SortSize, SortCount, SortPath:
+		return nil
+	}
+	return fmt.Errorf("неизвестная сортировка %q (допустимо: %s, %s, %s, %s)",
+		by, SortReclaimable, SortSize, SortCount, SortPath)
+}
+
+// sortFiles упорядочивает файлы группы по пути, чтобы результаты разных запусков можно было сравнивать.
+// Одинаковый путь бывает у файла и записи манифеста - тогда решает корень.
+func sortFiles(group []FileInfo) {
+	sort.Slice(group, func(i, j int) bool {
+		if group[i].Path != group[j].Path {
+			return group[i].Path < group[j].Path
+		}
+		return group[i].Root < group[j].Root
+	})
+}
+
+// sortCanonical упорядочивает файлы в группах и сами группы по пути первого файла.
+// Так промежуточные группы (кандидаты, группы по хэшу) не зависят от обхода map
+// и от того, какой воркер закончил раньше.
+func sortCanonical(groups [][]FileInfo) {
+	for _, group := range groups {
+		sortFiles(group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i][0], groups[j][0]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Root < b.Root
+	})
+}
+
+// sortGroups упорядочивает группы по критерию by; reverse меняет направление на обратное.
+// При равенстве группы идут по пути первого файла - порядок не зависит от обхода map.
+func sortGroups(groups [][]FileInfo, by string, reverse bool) {
+	less := func(a, b []FileInfo) bool {
+		switch by {
+		case SortSize:
+			return a[0].Size > b[0].Size
+		case SortCount:
+			return len(a) > len(b)
+		case SortPath:
+			return false // Все решает сравнение путей ниже
+		default:
+			return Reclaimable(a) > Reclaimable(b)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if reverse {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a[0].Path < b[0].Path
+	})
+}
@@ -0,0 +1,30 @@
+// Подробный снимок состояния сканера во время работы
+package scan
+
+// Status - подробный снимок: прогресс и файлы, которые сейчас читают воркеры
+type Status struct {
+	Progress
+	Current []string // Файл каждого воркера (пусто - воркер свободен)
+
+	// Сколько байт файла Current уже прочитано и его размер: прогресс внутри большого файла.
+	// 0 и 0 - воркер свободен или читает без счета (например, побайтовое сравнение compare).
+	CurrentRead []int64
+	CurrentSize []int64
+}
+
+// Status возвращает текущее состояние (безопасно для конкурентного чтения)
+func (s *Scanner) Status() Status {
+	st := Status{
+		Progress:    s.Progress(),
+		Current:     make([]string, len(s.current)),
+		CurrentRead: make([]int64, len(s.current)),
+		CurrentSize: make([]int64, len(s.current)),
+	}
+	for i := range s.current {
+		st.Current[i], _ = s.current[i].Load().(string)
+		if p := s.reading[i].Load(); p != nil {
+			st.CurrentRead[i], st.CurrentSize[i] = min(p.read.Load(), p.size), p.size
+		}
+	}
+	return st
+}
@@ -0,0 +1,96 @@
+// Сводки по группам дубликатов: короткие отчеты вместо полного списка
+package scan
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxSamplePaths - сколько путей группы показывать в сводке
+const maxSamplePaths = 3
+
+// GroupSummary - краткое описание группы дубликатов
+type GroupSummary struct {
+	Hash    string   // Хэш содержимого (пусто в режимах без хэширования: name_only, name_size, size_only, name_count)
+	Size    int64    // Размер одного файла
+	Count   int      // Сколько файлов в группе
+	Wasted  int64    // Сколько места освободится, если оставить одну копию
+	Samples []string // Первые пути группы (не больше maxSamplePaths)
+}
+
+// ExtStats - дубликаты с одним расширением (SummaryByExtension)
+type ExtStats struct {
+	Groups int   // Групп, в которых есть файлы с этим расширением
+	Files  int   // Файлов с этим расширением во всех группах
+	Wasted int64 // Лишнее место, занятое копиями с этим расширением
+}
+
+// Reclaimable возвращает объем, который освободится, если оставить в группе один файл.
+// Жесткие ссылки на один inode занимают место один раз и считаются одним файлом.
+func Reclaimable(group []FileInfo) int64 {
+	return group[0].Size * int64(distinctFiles(group)-1)
+}
+
+// summarizeGroup собирает сводку по одной группе
+func summarizeGroup(group []FileInfo) GroupSummary {
+	summary := GroupSummary{
+		Hash:   group[0].Hash,
+		Size:   group[0].Size,
+		Count:  len(group),
+		Wasted: Reclaimable(group),
+	}
+	for _, f := range group[:min(len(group), maxSamplePaths)] {
+		summary.Samples = append(summary.Samples, f.Path)
+	}
+	return summary
+}
+
+// TopWasters возвращает n групп, занимающих больше всего лишнего места, по убыванию.
+// При равном объеме сохраняется исходный порядок групп.
+func TopWasters(groups [][]FileInfo, n int) []GroupSummary {
+	summaries := make([]GroupSummary, 0, len(groups))
+	for _, group := range groups {
+		summaries = append(summaries, summarizeGroup(group))
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Wasted > summaries[j].Wasted
+	})
+	if n < len(summaries) {
+		summaries = summaries[:max(n, 0)]
+	}
+	return summaries
+}
+
+// SummaryByExtension разбивает лишнее место по расширениям файлов: ключ - расширение с точкой
+// в нижнем регистре (".jpg"), "" - файлы без расширения. Остающейся копией считается первый
+// файл группы, так что в группе с разными расширениями (photo.jpg и photo.JPEG) лишними
+// считаются остальные, а сумма Wasted по всем расширениям равна сумме Reclaimable групп.
+func SummaryByExtension(groups [][]FileInfo) map[string]ExtStats {
+	result := make(map[string]ExtStats)
+	for _, group := range groups {
+		seen := make(map[fileKey]bool, len(group))
+		inGroup := make(map[string]bool)
+		for i, f := range group {
+			ext := strings.ToLower(filepath.Ext(f.Name))
+			stats := result[ext]
+			stats.Files++
+			if !inGroup[ext] {
+				inGroup[ext] = true
+				stats.Groups++
+			}
+			// Жесткая ссылка на уже учтенный файл места не занимает
+			key, ok := f.identity()
+			if !ok || !seen[key] {
+				if ok {
+					seen[key] = true
+				}
+				if i > 0 {
+					stats.Wasted += group[0].Size
+				}
+			}
+			result[ext] = stats
+		}
+	}
+	return result
+}
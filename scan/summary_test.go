@@ -0,0 +1,139 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSummaryByExtension(t *testing.T) {
+	file := func(p string, size int64) FileInfo {
+		return FileInfo{Path: p, Name: path.Base(p), Size: size}
+	}
+	groups := [][]FileInfo{
+		{file("/a/photo.jpg", 100), file("/b/photo.JPG", 100), file("/c/photo.jpg", 100)},
+		{file("/a/notes.txt", 10), file("/b/notes.txt", 10)},
+		{file("/a/img.jpg", 50), file("/b/img.jpeg", 50)}, // Остается первый, лишний - .jpeg
+		{file("/a/Makefile", 7), file("/b/Makefile", 7)},
+	}
+	want := map[string]ExtStats{
+		".jpg":  {Groups: 2, Files: 4, Wasted: 200},
+		".jpeg": {Groups: 1, Files: 1, Wasted: 50},
+		".txt":  {Groups: 1, Files: 2, Wasted: 10},
+		"":      {Groups: 1, Files: 2, Wasted: 7},
+	}
+	got := SummaryByExtension(groups)
+	if !maps.Equal(got, want) {
+		t.Errorf("SummaryByExtension = %+v, want %+v", got, want)
+	}
+
+	// Сумма по расширениям - столько же, сколько освободят группы
+	var wasted, reclaimable int64
+	for _, stats := range got {
+		wasted += stats.Wasted
+	}
+	for _, group := range groups {
+		reclaimable += Reclaimable(group)
+	}
+	if wasted != reclaimable {
+		t.Errorf("сумма Wasted %d, а Reclaimable групп %d", wasted, reclaimable)
+	}
+}
+
+func TestEmptyFilesGroup(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/empty1": {Data: nil},
+		"b/empty2": {Data: nil},
+		"c/empty3": {Data: nil},
+		"a/same":   {Data: []byte("копия")},
+		"b/same":   {Data: []byte("копия")},
+	}
+	tests := []struct {
+		name        string
+		ignoreEmpty bool
+		wantGroups  int
+		wantEmpty   int // Сколько файлов в группе пустых; 0 - такой группы нет
+	}{
+		{"пустые - отдельная группа", false, 2, 3},
+		{"IgnoreEmptyFiles", true, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig(".")
+			cfg.IgnoreEmptyFiles = tt.ignoreEmpty
+			s, err := NewScanner(cfg, WithFS(fsys))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := s.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Groups) != tt.wantGroups {
+				t.Fatalf("групп %d, want %d", len(result.Groups), tt.wantGroups)
+			}
+			empty := 0
+			for _, group := range result.Groups {
+				if !IsEmptyGroup(group) {
+					continue
+				}
+				empty = len(group)
+				// Пустые файлы места не занимают: в отчете по месту группа ничего не добавляет
+				if wasted := Reclaimable(group); wasted != 0 {
+					t.Errorf("Reclaimable группы пустых файлов = %d, want 0", wasted)
+				}
+			}
+			if empty != tt.wantEmpty {
+				t.Errorf("в группе пустых файлов %d, want %d", empty, tt.wantEmpty)
+			}
+		})
+	}
+}
+
+func TestTopWasters(t *testing.T) {
+	group := func(name string, size int64, count int) []FileInfo {
+		files := make([]FileInfo, count)
+		for i := range files {
+			files[i] = FileInfo{Path: fmt.Sprintf("/%d/%s", i, name), Size: size, Hash: name}
+		}
+		return files
+	}
+	groups := [][]FileInfo{
+		group("small", 10, 2),  // 10
+		group("many", 10, 6),   // 50
+		group("big", 100, 2),   // 100
+		group("medium", 25, 3), // 50, как many: порядок групп сохраняется
+		group("empty", 0, 4),   // 0
+	}
+	tests := []struct {
+		n    int
+		want []string // Хэши групп по порядку
+	}{
+		{0, nil},
+		{1, []string{"big"}},
+		{3, []string{"big", "many", "medium"}},
+		{10, []string{"big", "many", "medium", "small", "empty"}},
+		{-1, nil},
+	}
+	for _, tt := range tests {
+		got := TopWasters(groups, tt.n)
+		var hashes []string
+		for _, s := range got {
+			hashes = append(hashes, s.Hash)
+		}
+		if !slices.Equal(hashes, tt.want) {
+			t.Errorf("TopWasters(%d) = %q, want %q", tt.n, hashes, tt.want)
+		}
+	}
+
+	// Сводка группы: размер, число файлов, лишнее место и не больше maxSamplePaths путей
+	top := TopWasters(groups, 2)[1]
+	want := GroupSummary{Hash: "many", Size: 10, Count: 6, Wasted: 50, Samples: []string{"/0/many", "/1/many", "/2/many"}}
+	if top.Size != want.Size || top.Count != want.Count || top.Wasted != want.Wasted || !slices.Equal(top.Samples, want.Samples) {
+		t.Errorf("сводка %+v, want %+v", top, want)
+	}
+}
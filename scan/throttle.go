@@ -0,0 +1,80 @@
+// Ограничение скорости чтения, чтобы сканирование не забирало весь канал общего хранилища
+package scan
+
+import (
+	"context"
+	"io"
+	"io/fs"
+
+	"golang.org/x/time/rate"
+)
+
+// maxThrottleBurst - наибольшая порция чтения при ограничении скорости.
+// Меньше порция - ровнее поток, но больше обращений к лимитеру.
+const maxThrottleBurst = 1 << 20
+
+// newReadLimiter создает общий на всех воркеров лимитер (token bucket) на bytesPerSecond.
+// 0 - без ограничения (nil).
+func newReadLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(min(bytesPerSecond, maxThrottleBurst)))
+}
+
+// throttle оборачивает открытый файл так, чтобы его чтение шло через лимитер сканера.
+// Файл, хэш которого считает сервер (remoteHasher), не оборачивается: его содержимое по сети
+// не идет, а обертка спрятала бы от computeHash эту возможность.
+func (s *Scanner) throttle(ctx context.Context, file fs.File) fs.File {
+	if s.limiter == nil {
+		return file
+	}
+	if _, remote := file.(remoteHasher); remote {
+		return file
+	}
+	t := throttledFile{File: file, ctx: ctx, limiter: s.limiter}
+	// fast_hash читает края файла через ReaderAt: эту возможность надо сохранить
+	if ra, ok := file.(io.ReaderAt); ok {
+		return throttledReaderAtFile{throttledFile: t, ra: ra}
+	}
+	return t
+}
+
+// throttledFile - файл, чтение которого ждет токенов лимитера (по токену на байт).
+// Токены берутся за прочитанные байты, а не за размер буфера: короткое чтение
+// в конце файла и пустое чтение до EOF не тратят лишнего.
+type throttledFile struct {
+	fs.File
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (f throttledFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p[:min(len(p), f.limiter.Burst())])
+	return n, f.wait(n, err)
+}
+
+// wait ждет токенов за n прочитанных байт (порциями не больше Burst - больше WaitN не даст);
+// ошибку чтения err возвращает, если ожидание удалось
+func (f throttledFile) wait(n int, err error) error {
+	for n > 0 {
+		chunk := min(n, f.limiter.Burst())
+		if waitErr := f.limiter.WaitN(f.ctx, chunk); waitErr != nil {
+			return waitErr
+		}
+		n -= chunk
+	}
+	return err
+}
+
+// throttledReaderAtFile - то же для файлов с произвольным доступом
+type throttledReaderAtFile struct {
+	throttledFile
+	ra io.ReaderAt
+}
+
+// ReadAt читает p целиком, как требует io.ReaderAt, и только потом ждет токенов
+func (f throttledReaderAtFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.ra.ReadAt(p, off)
+	return n, f.wait(n, err)
+}
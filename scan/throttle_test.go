@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeRemoteFile - файл, хэш которого "считает сервер"
+type fakeRemoteFile struct {
+	fs.File
+}
+
+func (fakeRemoteFile) RemoteSHA256() (string, error) { return "remote", nil }
+
+func TestThrottleKeepsRemoteHasher(t *testing.T) {
+	s := newTestScanner(t, t.TempDir(), func(cfg *Config) { cfg.MaxBytesPerSecond = 1 << 20 })
+	local, err := fstest.MapFS{"f": {Data: []byte("content")}}.Open("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	file := s.throttle(context.Background(), fakeRemoteFile{local})
+	hash, err := computeHash(context.Background(), countRead(file, &fileProgress{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "remote" {
+		t.Errorf("хэш посчитан локально (%s), а не на сервере", hash)
+	}
+}
+
+func TestThrottleElapsed(t *testing.T) {
+	const fileSize = 100 << 10
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for _, name := range []string{"a1", "a2", "b1", "b2"} {
+		files[name] = strings.Repeat(name[:1], fileSize) // Две пары копий: все четыре файла читаются целиком
+	}
+	writeFiles(t, dir, files)
+	const volume = 4 * fileSize
+
+	tests := []struct {
+		limit int64
+	}{
+		{200 << 10}, // Около секунды
+		{160 << 10}, // Около полутора секунд
+	}
+	for _, tt := range tests {
+		s := newTestScanner(t, dir, func(cfg *Config) { cfg.MaxBytesPerSecond = tt.limit })
+		start := time.Now()
+		result, err := s.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsed := time.Since(start)
+		if len(result.Groups) != 2 {
+			t.Fatalf("групп %d, want 2", len(result.Groups))
+		}
+		// Первая секунда лимита (burst) доступна сразу, остальное - со скоростью limit
+		want := time.Duration(float64(volume-tt.limit) / float64(tt.limit) * float64(time.Second))
+		if elapsed < want*9/10 || elapsed > want*3/2+500*time.Millisecond {
+			t.Errorf("лимит %d байт/с: %d байт прочитаны за %v, want около %v", tt.limit, volume, elapsed, want)
+		}
+	}
+}
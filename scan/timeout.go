@@ -0,0 +1,82 @@
+// Предел времени на чтение одного файла (Config.FileTimeout): зависший сетевой диск не держит воркер
+package scan
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrReadTimeout - файл не прочитан за Config.FileTimeout; такой файл пропускается (FileInfo.Err),
+// попадает в Errors с OpTimeout и считается в Stats.TimedOutFiles
+var ErrReadTimeout = errors.New("файл не прочитан за отведенное время")
+
+// maxStuckReads - сколько брошенных чтений может висеть одновременно. Прервать заблокированный
+// системный вызов read переносимо нельзя: воркер бросает его и идет дальше, а горутина с
+// открытым файлом остается ждать, пока диск ответит. Когда таких горутин maxStuckReads,
+// новые не заводятся: воркер сам ждет чтения (предел времени еще действует между блоками,
+// но не внутри зависшего read) - иначе мертвая точка монтирования съела бы память и дескрипторы.
+const maxStuckReads = 16
+
+// Состояния чтения с пределом времени: кто первым поменял readPending, тот и решил,
+// чем кончилось чтение
+const (
+	readPending int32 = iota
+	readDone
+	readAbandoned
+)
+
+// withFileTimeout вызывает read (открытие и чтение одного файла) с пределом Config.FileTimeout.
+// ctx для read отменяется по истечении предела: чтение, которое идет, но медленно, само
+// остановится на следующем блоке (ctxReader). Чтение, зависшее в системном вызове, бросается
+// (см. maxStuckReads). В обоих случаях возвращается ErrReadTimeout.
+func (s *Scanner) withFileTimeout(read func(ctx context.Context) (string, error)) (string, error) {
+	if s.config.FileTimeout <= 0 {
+		return read(s.ctx)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.config.FileTimeout)
+	defer cancel()
+	if atomic.LoadInt64(&s.stuckReads) >= maxStuckReads {
+		hash, err := read(ctx)
+		return hash, s.timeoutError(ctx, err)
+	}
+
+	type outcome struct {
+		hash string
+		err  error
+	}
+	var state atomic.Int32
+	done := make(chan outcome, 1)
+	go func() {
+		hash, err := read(ctx)
+		if !state.CompareAndSwap(readPending, readDone) {
+			atomic.AddInt64(&s.stuckReads, -1) // Воркер уже ушел: результат никому не нужен
+			return
+		}
+		done <- outcome{hash, err}
+	}()
+	select {
+	case o := <-done:
+		return o.hash, s.timeoutError(ctx, o.err)
+	case <-ctx.Done():
+	}
+	if !state.CompareAndSwap(readPending, readAbandoned) {
+		o := <-done // Чтение закончилось одновременно с пределом
+		return o.hash, s.timeoutError(ctx, o.err)
+	}
+	stuck := atomic.AddInt64(&s.stuckReads, 1)
+	s.logger.Debug("чтение брошено по пределу времени", "stuck", stuck)
+	if err := s.ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrReadTimeout
+}
+
+// timeoutError заменяет ошибку чтения, оборванного пределом времени ctx, на ErrReadTimeout.
+// Отмена всего запуска остается как есть: файл не обработан, а не ошибочен.
+func (s *Scanner) timeoutError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil && s.ctx.Err() == nil {
+		return ErrReadTimeout
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+// Длительность этапов сканирования: обход, группировка, хэширование
+package scan
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timings - сколько занял каждый этап последнего запуска. По ним видно, во что упирается
+// поиск: в обход (файловая система, число файлов) или в хэширование (диск и CPU).
+type Timings struct {
+	WalkDuration  time.Duration // Обход корней и чтение манифестов
+	GroupDuration time.Duration // Группировка кандидатов
+	HashDuration  time.Duration // Хэширование, вместе с перепроверкой (Config.Verify); 0 в режимах без хэша
+}
+
+// stageTimes - длительности этапов в наносекундах (атомики: Timings можно читать во время работы)
+type stageTimes struct {
+	walk, group, hash atomic.Int64
+}
+
+// Timings возвращает длительности этапов последнего запуска (Run, Stream, Candidates,
+// ManifestFiles). Этап, который еще не закончился, равен нулю.
+func (s *Scanner) Timings() Timings {
+	return Timings{
+		WalkDuration:  time.Duration(s.timings.walk.Load()),
+		GroupDuration: time.Duration(s.timings.group.Load()),
+		HashDuration:  time.Duration(s.timings.hash.Load()),
+	}
+}
+
+// measure записывает в stage время, прошедшее с start: defer s.measure(&s.timings.walk, time.Now())
+func (s *Scanner) measure(stage *atomic.Int64, start time.Time) {
+	stage.Store(int64(time.Since(start)))
+}
+
+// resetTimings обнуляет длительности перед новым запуском
+func (s *Scanner) resetTimings() {
+	s.timings.walk.Store(0)
+	s.timings.group.Store(0)
+	s.timings.hash.Store(0)
+}
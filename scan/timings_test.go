@@ -0,0 +1,32 @@
+package scan
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTimings(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "same", "b.txt": "same", "c.txt": "other"})
+
+	s := newTestScanner(t, dir, nil)
+	if got := s.Timings(); got != (Timings{}) {
+		t.Errorf("до запуска Timings = %+v, want нули", got)
+	}
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := s.Timings()
+	if got.WalkDuration <= 0 || got.GroupDuration <= 0 || got.HashDuration <= 0 {
+		t.Errorf("после запуска Timings = %+v, want все этапы больше нуля", got)
+	}
+
+	// В режиме без хэша этапа хэширования нет
+	s = newTestScanner(t, dir, func(cfg *Config) { cfg.Mode = ModeSizeOnly })
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Timings(); got.WalkDuration <= 0 || got.HashDuration != 0 {
+		t.Errorf("size_only: Timings = %+v, want обход больше нуля и хэширование 0", got)
+	}
+}
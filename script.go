@@ -0,0 +1,133 @@
+// Генерация скриптов с запланированными операциями для ручной проверки администратором
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scriptDialect описывает синтаксис конкретной оболочки
+type scriptDialect struct {
+	prologue []string            // Строки в самом начале файла (shebang и т.п.)
+	setup    []string            // Команды после заголовка с параметрами
+	quote    func(string) string // Экранирование пути
+	commands map[string]string   // Операция плана -> формат команды (%[1]s - путь, %[2]s - оставляемый файл)
+}
+
+// POSIX sh: rm для DELETE, gio trash (GLib) для TRASH, ln -f для HARDLINK
+var posixDialect = scriptDialect{
+	prologue: []string{"#!/bin/sh"},
+	quote:    shellQuote,
+	commands: map[string]string{
+		"DELETE":   "rm -f -- %[1]s",
+		"TRASH":    "gio trash -- %[1]s",
+		"HARDLINK": "ln -f -- %[2]s %[1]s",
+	},
+}
+
+// PowerShell: Remove-Item без подстановки шаблонов (-LiteralPath), корзина - через Microsoft.VisualBasic
+var powerShellDialect = scriptDialect{
+	setup: []string{"Add-Type -AssemblyName Microsoft.VisualBasic"},
+	quote: psQuote,
+	commands: map[string]string{
+		"DELETE":   "Remove-Item -LiteralPath %[1]s -Force",
+		"TRASH":    "[Microsoft.VisualBasic.FileIO.FileSystem]::DeleteFile(%[1]s, 'OnlyErrorDialogs', 'SendToRecycleBin')",
+		"HARDLINK": "Remove-Item -LiteralPath %[1]s -Force; New-Item -ItemType HardLink -Path %[1]s -Target %[2]s | Out-Null",
+	},
+}
+
+// shellQuote заключает строку в одинарные кавычки для POSIX sh.
+// Внутри '...' ничего не интерпретируется (ни $, ни перевод строки, ни юникод),
+// а сама одинарная кавычка записывается как '\”.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// psQuote экранирует строку для PowerShell: в '...' кавычка удваивается.
+// PowerShell считает кавычками и типографские ‘ ’ ‚ ‛, поэтому удваиваем и их.
+func psQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// commentText делает путь безопасным для однострочного комментария:
+// управляющие символы (перевод строки!) экранируются, иначе остаток стал бы командой
+func commentText(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) < 0 {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// scriptComment оформляет строку комментария (в sh и PowerShell синтаксис одинаковый)
+func scriptComment(s string) string {
+	return "# " + s
+}
+
+// WriteShellScript пишет POSIX-скрипт с командами плана
+func WriteShellScript(w io.Writer, cfg Config, plan []PlannedOp) error {
+	return writeScript(w, posixDialect, cfg, plan)
+}
+
+// WritePowerShellScript пишет PowerShell-скрипт с командами плана
+func WritePowerShellScript(w io.Writer, cfg Config, plan []PlannedOp) error {
+	return writeScript(w, powerShellDialect, cfg, plan)
+}
+
+// writeScript формирует скрипт: заголовок с параметрами запуска, затем блоки операций.
+// Подряд идущие операции с одним оставляемым файлом объединяются в блок с комментарием.
+func writeScript(w io.Writer, d scriptDialect, cfg Config, plan []PlannedOp) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range d.prologue {
+		fmt.Fprintln(bw, line)
+	}
+	fmt.Fprintln(bw, scriptComment("Сгенерировано duplifinder "+time.Now().Format(time.RFC3339)))
+	fmt.Fprintln(bw, scriptComment(fmt.Sprintf("Параметры: path=%s mode=%s action=%s keep=%s",
+		commentText(strings.Join(cfg.RootList(), ", ")), cfg.Mode, cfg.Action, cfg.Keep)))
+	fmt.Fprintln(bw, scriptComment(fmt.Sprintf("Операций: %d", len(plan))))
+	for _, line := range d.setup {
+		fmt.Fprintln(bw, line)
+	}
+
+	lastKept := ""
+	for i, op := range plan {
+		format, ok := d.commands[op.Op]
+		if !ok {
+			return fmt.Errorf("операция %q не поддерживается в скрипте", op.Op)
+		}
+		if i == 0 || op.Kept != lastKept {
+			fmt.Fprintln(bw)
+			fmt.Fprintln(bw, scriptComment("Оставляем: "+commentText(op.Kept)))
+			lastKept = op.Kept
+		}
+		fmt.Fprintf(bw, format+"\n", d.quote(op.Path), d.quote(op.Kept))
+	}
+	return bw.Flush()
+}
+
+// writeScriptFile сохраняет скрипт в файл (POSIX-скрипт сразу делается исполняемым)
+func writeScriptFile(path string, write func(io.Writer, Config, []PlannedOp) error, cfg Config, plan []PlannedOp, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := write(f, cfg, plan); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestShellScriptRunsPlan(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("нужен POSIX sh")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("нет sh")
+	}
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "оригинал.txt")
+	// Имена, которые ломают наивное экранирование: кавычка, перевод строки, $, *, ведущий дефис
+	awkward := []string{"it's.txt", "две\nстроки.txt", "$HOME `id`.txt", "*.txt", "-rf"}
+	untouched := filepath.Join(dir, "не трогать.txt")
+	link := filepath.Join(dir, "ссылка 'в' $(кавычках).txt")
+	for _, name := range append(awkward, "оригинал.txt", "не трогать.txt", filepath.Base(link)) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var plan []PlannedOp
+	for _, name := range awkward {
+		plan = append(plan, PlannedOp{Op: "DELETE", Path: filepath.Join(dir, name), Kept: kept})
+	}
+	plan = append(plan, PlannedOp{Op: "HARDLINK", Path: link, Kept: kept})
+
+	var script bytes.Buffer
+	if err := WriteShellScript(&script, Config{}, plan); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(sh)
+	cmd.Stdin = &script
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("скрипт: %v\n%s", err, out)
+	}
+
+	for _, op := range plan[:len(awkward)] {
+		if _, err := os.Lstat(op.Path); !os.IsNotExist(err) {
+			t.Errorf("%q не удален скриптом (%v)", op.Path, err)
+		}
+	}
+	for _, path := range []string{kept, untouched} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%q не в плане, но пропал: %v", path, err)
+		}
+	}
+	a, errA := os.Stat(kept)
+	b, errB := os.Stat(link)
+	if errA != nil || errB != nil || !os.SameFile(a, b) {
+		t.Errorf("%q не стал ссылкой на %q (%v, %v)", link, kept, errA, errB)
+	}
+}
@@ -0,0 +1,448 @@
+// Режим -serve: JSON HTTP API, через который сканирования запускают другие программы
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+// Состояния сканирования в API
+const (
+	scanRunning  = "running"
+	scanDone     = "done"
+	scanFailed   = "failed"
+	scanCanceled = "canceled"
+)
+
+// Страница групп по умолчанию и наибольшая (GET /scans/{id}/groups?offset=&limit=)
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// ServeConfig - настройки режима -serve
+type ServeConfig struct {
+	Addr     string        // Адрес HTTP-сервера, например ":8080"
+	Token    string        // Bearer-токен для всех запросов (пусто - без проверки)
+	MaxScans int           // Сколько сканирований идет одновременно; остальные запросы получают 429
+	TTL      time.Duration // Сколько хранится итог завершенного сканирования
+}
+
+// scanJob - сканирование, запущенное через API
+type scanJob struct {
+	id      string
+	target  string // Режим и корни: метрики отдаются по последнему сканированию каждой цели
+	scanner *scan.Scanner
+	cancel  context.CancelFunc
+	started time.Time
+
+	mu       sync.Mutex
+	state    string
+	finished time.Time
+	result   *scan.Result // При остановке - неполный итог, как у Run
+	err      error
+}
+
+// apiServer хранит сканирования в памяти; завершенные удаляются через ServeConfig.TTL
+type apiServer struct {
+	cfg   ServeConfig
+	slots chan struct{} // Занятые места для одновременных сканирований
+
+	mu    sync.Mutex
+	scans map[string]*scanJob
+}
+
+// scanStatus - ответ GET /scans/{id}
+type scanStatus struct {
+	ID       string        `json:"id"`
+	State    string        `json:"state"` // running, done, failed, canceled
+	Phase    scan.Phase    `json:"phase"`
+	Progress scan.Progress `json:"progress"`
+	Stats    scan.Stats    `json:"stats"`
+	Groups   int           `json:"groups"` // Групп дубликатов (когда сканирование закончено)
+	Error    string        `json:"error,omitempty"`
+	Started  time.Time     `json:"started"`
+	Finished *time.Time    `json:"finished,omitempty"`
+}
+
+// groupsPage - ответ GET /scans/{id}/groups
+type groupsPage struct {
+	Total  int                `json:"total"`
+	Offset int                `json:"offset"`
+	Limit  int                `json:"limit"`
+	Groups []scan.GroupRecord `json:"groups"`
+}
+
+// newAPIServer создает сервер API
+func newAPIServer(cfg ServeConfig) *apiServer {
+	return &apiServer{
+		cfg:   cfg,
+		slots: make(chan struct{}, max(cfg.MaxScans, 1)),
+		scans: make(map[string]*scanJob),
+	}
+}
+
+// handler возвращает маршруты API; все они требуют токен, если он задан
+func (a *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /scans", a.startScan)
+	mux.HandleFunc("GET /scans/{id}", a.scanStatus)
+	mux.HandleFunc("GET /scans/{id}/groups", a.scanGroups)
+	mux.HandleFunc("DELETE /scans/{id}", a.deleteScan)
+	mux.HandleFunc("GET /metrics", a.metrics)
+	return a.authorize(mux)
+}
+
+// authorize пропускает только запросы с заголовком "Authorization: Bearer <токен>"
+func (a *apiServer) authorize(next http.Handler) http.Handler {
+	if a.cfg.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + a.cfg.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, errors.New("нужен токен: Authorization: Bearer <токен>"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scanRequest - тело POST /scans: корни, режим и фильтры, и ничего больше. Остальные поля
+// scan.Config (журнал, CheckpointDir, S3, SSH, манифесты, колбэки) через сеть не задаются:
+// они пишут на диск сервера, ходят в сеть от его имени или вовсе не переживают JSON.
+// Config собирает сам сервер (см. config).
+type scanRequest struct {
+	Roots            []string  `json:"roots"`
+	Mode             scan.Mode `json:"mode"` // Пусто - hash
+	IgnoreEmptyFiles bool      `json:"ignore_empty_files"`
+	CrossRoot        bool      `json:"cross_root"`
+	RedundantIn      string    `json:"redundant_in"`
+	DirFilter        string    `json:"dir_filter"`
+	MinGroupSize     int       `json:"min_group_size"`
+	MaxGroups        int       `json:"max_groups"`
+	SortBy           string    `json:"sort_by"`
+	SortReverse      bool      `json:"sort_reverse"`
+	Exclude          []string  `json:"exclude"`         // Шаблоны имен, как у -exclude
+	ExcludePresets   []string  `json:"exclude_presets"` // Наборы, как у -exclude-preset
+	MinSize          int64     `json:"min_size"`
+	MaxSize          int64     `json:"max_size"`
+}
+
+// config собирает настройки сканирования: DefaultConfig и поля запроса
+func (req scanRequest) config() (scan.Config, error) {
+	if len(req.Roots) == 0 {
+		return scan.Config{}, errors.New("не заданы корни сканирования (roots)")
+	}
+	if slices.Contains(req.Roots, scan.FilesFromStdin) {
+		return scan.Config{}, errors.New("список файлов из stdin через API не задается: stdin у сервера свой")
+	}
+	cfg := scan.DefaultConfig("")
+	cfg.Roots = req.Roots
+	if req.Mode != "" {
+		cfg.Mode = req.Mode
+	}
+	cfg.IgnoreEmptyFiles = req.IgnoreEmptyFiles
+	cfg.CrossRoot = req.CrossRoot
+	cfg.RedundantIn = req.RedundantIn
+	cfg.DirFilter = req.DirFilter
+	cfg.MinGroupSize = req.MinGroupSize
+	cfg.MaxGroups = req.MaxGroups
+	if req.SortBy != "" {
+		cfg.SortBy = req.SortBy
+	}
+	cfg.SortReverse = req.SortReverse
+	filter, err := fileFilter(req.Roots, req.Exclude, req.ExcludePresets, req.MinSize, req.MaxSize)
+	if err != nil {
+		return scan.Config{}, err
+	}
+	cfg.FileFilter = filter
+	return cfg, nil
+}
+
+// startScan - POST /scans: тело - scanRequest в JSON, например {"roots": ["/srv"], "mode": "hash"}.
+// Сканирование идет в фоне, в ответе - его id.
+func (a *apiServer) startScan(w http.ResponseWriter, r *http.Request) {
+	var req scanRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("запрос сканирования: %w", err))
+		return
+	}
+	cfg, err := req.config()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	scanner, err := scan.NewScanner(cfg)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	select {
+	case a.slots <- struct{}{}:
+	default:
+		writeAPIError(w, http.StatusTooManyRequests, fmt.Errorf("уже идет сканирований: %d, повторите позже", cap(a.slots)))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &scanJob{
+		id:      newScanID(),
+		target:  string(cfg.Mode) + "|" + strings.Join(scanner.Roots(), ","),
+		scanner: scanner,
+		cancel:  cancel,
+		started: time.Now(),
+		state:   scanRunning,
+	}
+	a.mu.Lock()
+	a.expire(time.Now())
+	a.scans[job.id] = job
+	a.mu.Unlock()
+	go a.run(ctx, job)
+
+	w.Header().Set("Location", "/scans/"+job.id)
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": job.id})
+}
+
+// run выполняет сканирование и освобождает место для следующего
+func (a *apiServer) run(ctx context.Context, job *scanJob) {
+	defer func() { <-a.slots }()
+	defer job.cancel()
+	result, err := job.scanner.Run(ctx)
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.finished = time.Now()
+	job.result, job.err = result, err
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, scan.ErrInterrupted):
+		job.state = scanCanceled
+	case err != nil:
+		job.state = scanFailed
+	default:
+		job.state = scanDone
+	}
+}
+
+// scanStatus - GET /scans/{id}: состояние, фаза и статистика на текущий момент
+func (a *apiServer) scanStatus(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.lookup(w, r)
+	if !ok {
+		return
+	}
+	progress := job.scanner.Progress()
+	status := scanStatus{
+		ID:       job.id,
+		Phase:    progress.Phase,
+		Progress: progress,
+		Stats:    job.scanner.GetStats(),
+		Started:  job.started,
+	}
+	job.mu.Lock()
+	status.State = job.state
+	if job.state != scanRunning {
+		status.Finished = &job.finished
+	}
+	if job.result != nil {
+		status.Groups = len(job.result.Groups)
+	}
+	if job.err != nil {
+		status.Error = job.err.Error()
+	}
+	job.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// scanGroups - GET /scans/{id}/groups?offset=0&limit=100: группы итога постранично.
+// Пока сканирование идет, групп еще нет (409); у остановленного - уже проверенные группы.
+func (a *apiServer) scanGroups(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.lookup(w, r)
+	if !ok {
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit = min(max(limit, 1), maxPageSize)
+
+	job.mu.Lock()
+	state, result := job.state, job.result
+	job.mu.Unlock()
+	if state == scanRunning {
+		writeAPIError(w, http.StatusConflict, errors.New("сканирование еще идет"))
+		return
+	}
+	page := groupsPage{Offset: offset, Limit: limit, Groups: []scan.GroupRecord{}}
+	if result != nil {
+		groups := result.Groups
+		page.Total = len(groups)
+		for i := offset; i < len(groups) && i < offset+limit; i++ {
+			page.Groups = append(page.Groups, scan.NewGroupRecord(i+1, groups[i]))
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// deleteScan - DELETE /scans/{id}: идущее сканирование отменяется (его итог остается до TTL),
+// законченное удаляется
+func (a *apiServer) deleteScan(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.lookup(w, r)
+	if !ok {
+		return
+	}
+	job.mu.Lock()
+	running := job.state == scanRunning
+	job.mu.Unlock()
+	if running {
+		job.cancel()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	a.mu.Lock()
+	delete(a.scans, job.id)
+	a.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// metrics - GET /metrics: метрики Prometheus по последнему сканированию каждой цели (режим и корни)
+func (a *apiServer) metrics(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	a.expire(time.Now())
+	latest := make(map[string]*scanJob)
+	for _, job := range a.scans {
+		if prev, ok := latest[job.target]; !ok || job.started.After(prev.started) {
+			latest[job.target] = job
+		}
+	}
+	a.mu.Unlock()
+	targets := make([]string, 0, len(latest))
+	for target := range latest {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	scanners := make([]*scan.Scanner, len(targets))
+	for i, target := range targets {
+		scanners[i] = latest[target].scanner
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	scan.WriteMetrics(w, scanners...)
+}
+
+// lookup находит сканирование по id из пути; если его нет, отвечает 404 сам
+func (a *apiServer) lookup(w http.ResponseWriter, r *http.Request) (*scanJob, bool) {
+	a.mu.Lock()
+	a.expire(time.Now())
+	job, ok := a.scans[r.PathValue("id")]
+	a.mu.Unlock()
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, errors.New("сканирование не найдено (или его итог устарел)"))
+	}
+	return job, ok
+}
+
+// expire удаляет сканирования, закончившиеся раньше TTL назад. Вызывается под a.mu.
+func (a *apiServer) expire(now time.Time) {
+	for id, job := range a.scans {
+		job.mu.Lock()
+		old := job.state != scanRunning && now.Sub(job.finished) > a.cfg.TTL
+		job.mu.Unlock()
+		if old {
+			delete(a.scans, id)
+		}
+	}
+}
+
+// cancelAll отменяет все идущие сканирования (при остановке сервера)
+func (a *apiServer) cancelAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, job := range a.scans {
+		job.cancel()
+	}
+}
+
+// newScanID возвращает случайный id сканирования
+func newScanID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// queryInt читает неотрицательное число из параметра запроса (def - если его нет)
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("параметр %s: нужно неотрицательное число, а не %q", name, value)
+	}
+	return n, nil
+}
+
+// writeJSON отвечает объектом v в JSON
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError отвечает ошибкой {"error": "..."}
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// runServe запускает HTTP API и работает до Ctrl-C (или SIGTERM): тогда идущие сканирования
+// отменяются, а сервер дожидается ответов на начатые запросы
+func runServe(cfg ServeConfig) {
+	api := newAPIServer(cfg)
+	srv := &http.Server{Addr: cfg.Addr, Handler: api.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("\n⏹  Остановка сервера")
+		api.cancelAll()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	if cfg.Token == "" {
+		fmt.Fprintln(os.Stderr, "⚠ Токен не задан (-serve-token или DUPLIFINDER_TOKEN): API доступен без проверки")
+	}
+	fmt.Printf("🌐 API на %s: POST /scans, GET /scans/{id}, GET /scans/{id}/groups, DELETE /scans/{id}, GET /metrics\n", cfg.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(exitFatal)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiCall выполняет запрос к API и разбирает ответ JSON в out (если out не nil)
+func apiCall(t *testing.T, h http.Handler, method, path, token, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil && rec.Code/100 == 2 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+// startAndWait запускает сканирование dir через API и ждет его конца
+func startAndWait(t *testing.T, h http.Handler, token, dir string) string {
+	t.Helper()
+	body, _ := json.Marshal(scanRequest{Roots: []string{dir}})
+	var started map[string]string
+	if code := apiCall(t, h, "POST", "/scans", token, string(body), &started); code != http.StatusAccepted {
+		t.Fatalf("POST /scans: %d", code)
+	}
+	id := started["id"]
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		var status scanStatus
+		apiCall(t, h, "GET", "/scans/"+id, token, "", &status)
+		if status.State != scanRunning {
+			if status.State != scanDone {
+				t.Fatalf("сканирование: %s, %s", status.State, status.Error)
+			}
+			return id
+		}
+	}
+	t.Fatal("сканирование не закончилось")
+	return ""
+}
+
+// writeDuplicates создает в dir n групп по два одинаковых файла
+func writeDuplicates(t *testing.T, dir string, n int) {
+	t.Helper()
+	for i := range n {
+		for _, name := range []string{"a", "b"} {
+			path := filepath.Join(dir, fmt.Sprintf("%s%d.txt", name, i))
+			if err := os.WriteFile(path, []byte(fmt.Sprintf("копия %d", i)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestServeAuth(t *testing.T) {
+	h := newAPIServer(ServeConfig{Token: "secret", TTL: time.Hour}).handler()
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"без заголовка", "", http.StatusUnauthorized},
+		{"чужой токен", "Bearer wrong", http.StatusUnauthorized},
+		{"токен без Bearer", "secret", http.StatusUnauthorized},
+		{"верный токен", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("код %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("нет заголовка WWW-Authenticate")
+			}
+		})
+	}
+	// Без токена не запускается и сканирование
+	body, _ := json.Marshal(scanRequest{Roots: []string{t.TempDir()}})
+	if code := apiCall(t, h, "POST", "/scans", "", string(body), nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /scans без токена: %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestServeTTL(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	h := newAPIServer(ServeConfig{TTL: ttl}).handler()
+	id := startAndWait(t, h, "", t.TempDir())
+	if code := apiCall(t, h, "GET", "/scans/"+id+"/groups", "", "", nil); code != http.StatusOK {
+		t.Fatalf("итог сразу после конца: %d, want %d", code, http.StatusOK)
+	}
+	time.Sleep(2 * ttl)
+	for _, path := range []string{"/scans/" + id, "/scans/" + id + "/groups"} {
+		if code := apiCall(t, h, "GET", path, "", "", nil); code != http.StatusNotFound {
+			t.Errorf("GET %s после TTL: %d, want %d", path, code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestServeGroupsPagination(t *testing.T) {
+	dir := t.TempDir()
+	writeDuplicates(t, dir, 5)
+	h := newAPIServer(ServeConfig{TTL: time.Hour}).handler()
+	id := startAndWait(t, h, "", dir)
+
+	tests := []struct {
+		query      string
+		wantCode   int
+		wantLimit  int
+		wantGroups int
+		wantFirst  int // Номер первой группы на странице
+	}{
+		{"", http.StatusOK, defaultPageSize, 5, 1},
+		{"?offset=0&limit=2", http.StatusOK, 2, 2, 1},
+		{"?offset=4&limit=2", http.StatusOK, 2, 1, 5},
+		{"?offset=5", http.StatusOK, defaultPageSize, 0, 0},
+		{"?offset=100", http.StatusOK, defaultPageSize, 0, 0},
+		{"?limit=0", http.StatusOK, 1, 1, 1},
+		{"?limit=1000000", http.StatusOK, maxPageSize, 5, 1},
+		{"?offset=-1", http.StatusBadRequest, 0, 0, 0},
+		{"?limit=-1", http.StatusBadRequest, 0, 0, 0},
+		{"?limit=abc", http.StatusBadRequest, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var page groupsPage
+			code := apiCall(t, h, "GET", "/scans/"+id+"/groups"+tt.query, "", "", &page)
+			if code != tt.wantCode {
+				t.Fatalf("код %d, want %d", code, tt.wantCode)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if page.Total != 5 || page.Limit != tt.wantLimit || len(page.Groups) != tt.wantGroups {
+				t.Errorf("total %d, limit %d, групп %d; want 5, %d, %d", page.Total, page.Limit, len(page.Groups), tt.wantLimit, tt.wantGroups)
+			}
+			if len(page.Groups) > 0 && page.Groups[0].Group != tt.wantFirst {
+				t.Errorf("первая группа %d, want %d", page.Groups[0].Group, tt.wantFirst)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Экспорт результатов в базу SQLite для анализа SQL-запросами и загрузка их обратно
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BatrazG/duplifinder/scan"
+	_ "modernc.org/sqlite" // Драйвер "sqlite" на чистом Go, без cgo
+)
+
+// sqliteSchema - таблицы групп и файлов. Пример запроса (лишние копии по расширениям):
+//
+//	SELECT ext, COUNT(*) - COUNT(DISTINCT group_id), SUM(size) FROM files GROUP BY ext;
+var sqliteSchema = []string{
+	`DROP TABLE IF EXISTS files`,
+	`DROP TABLE IF EXISTS groups`,
+	`DROP TABLE IF EXISTS scan`,
+	`CREATE TABLE scan (
+		mode TEXT NOT NULL -- Режим, которым найдены группы: по нему clean и -tui-from решают, можно ли удалять
+	)`,
+	`CREATE TABLE groups (
+		id           INTEGER PRIMARY KEY,
+		hash         TEXT,
+		size         INTEGER NOT NULL,
+		file_count   INTEGER NOT NULL,
+		wasted_bytes INTEGER NOT NULL,
+		is_empty     INTEGER NOT NULL -- 1 для группы пустых файлов
+	)`,
+	`CREATE TABLE files (
+		id        INTEGER PRIMARY KEY,
+		group_id  INTEGER NOT NULL REFERENCES groups(id),
+		path      TEXT NOT NULL,
+		root      TEXT, -- Корень сканирования, в котором найден файл
+		name      TEXT NOT NULL,
+		ext       TEXT NOT NULL,
+		size      INTEGER NOT NULL,
+		hash      TEXT,
+		mod_time  TEXT,
+		read_only INTEGER NOT NULL -- 1 для записи архива, объекта S3 или удаленного файла
+	)`,
+	`CREATE INDEX idx_files_hash ON files(hash)`,
+	`CREATE INDEX idx_files_size ON files(size)`,
+	`CREATE INDEX idx_files_group ON files(group_id)`,
+}
+
+// WriteSQLite сохраняет группы дубликатов, найденные в режиме mode, в базу SQLite
+// (существующие таблицы пересоздаются). Все вставки идут в одной транзакции - так на порядки быстрее.
+func WriteSQLite(path string, mode scan.Mode, groups [][]scan.FileInfo) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // После Commit ничего не делает
+
+	for _, stmt := range sqliteSchema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO scan (mode) VALUES (?)`, string(mode)); err != nil {
+		return err
+	}
+	insertGroup, err := tx.Prepare(`INSERT INTO groups (id, hash, size, file_count, wasted_bytes, is_empty) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertGroup.Close()
+	insertFile, err := tx.Prepare(`INSERT INTO files (group_id, path, root, name, ext, size, hash, mod_time, read_only) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertFile.Close()
+
+	for i, group := range groups {
+		groupID := i + 1
+		if _, err := insertGroup.Exec(groupID, group[0].Hash, group[0].Size, len(group), scan.Reclaimable(group), scan.IsEmptyGroup(group)); err != nil {
+			return err
+		}
+		for _, f := range group {
+			ext := strings.ToLower(filepath.Ext(f.Name))
+			if _, err := insertFile.Exec(groupID, f.Path, f.Root, f.Name, ext, f.Size, f.Hash, f.ModTime.Format(time.RFC3339), f.ReadOnly); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// ReadSQLite загружает группы и режим, которым они найдены, из базы, сохраненной WriteSQLite, -
+// например, чтобы просмотреть на своей машине результаты сканирования с сервера.
+func ReadSQLite(path string) ([][]scan.FileInfo, scan.Mode, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, "", err
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRow(`SELECT mode FROM scan`).Scan(&mode); err != nil {
+		return nil, "", fmt.Errorf("режим сканирования: %w", err)
+	}
+	rows, err := db.Query(`SELECT group_id, path, root, name, size, hash, mod_time, read_only FROM files ORDER BY group_id, id`)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var groups [][]scan.FileInfo
+	lastGroup := int64(-1)
+	for rows.Next() {
+		var groupID int64
+		var f scan.FileInfo
+		var root, hash, modTime sql.NullString
+		if err := rows.Scan(&groupID, &f.Path, &root, &f.Name, &f.Size, &hash, &modTime, &f.ReadOnly); err != nil {
+			return nil, "", err
+		}
+		f.Root, f.Hash = root.String, hash.String
+		f.ModTime, _ = time.Parse(time.RFC3339, modTime.String) // Без времени файл все равно можно показать
+		if groupID != lastGroup {
+			groups = append(groups, nil)
+			lastGroup = groupID
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], f)
+	}
+	return groups, scan.Mode(mode), rows.Err()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BatrazG/duplifinder/scan"
+)
+
+func TestWriteSQLiteRowCounts(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	file := func(path string, size int64, hash string) scan.FileInfo {
+		return scan.FileInfo{Path: path, Name: filepath.Base(path), Size: size, Hash: hash, ModTime: mtime}
+	}
+	groups := [][]scan.FileInfo{
+		{
+			file("/data/a.jpg", 10, "h1"),
+			file("/data/copy/a.jpg", 10, "h1"),
+			file("/backup/a.jpg", 10, "h1"),
+		},
+		{
+			file("/data/b.txt", 3, "h2"),
+			file("/backup/b.txt", 3, "h2"),
+		},
+		{
+			file("/data/empty", 0, "h0"),
+			file("/backup/empty", 0, "h0"),
+		},
+	}
+	path := filepath.Join(t.TempDir(), "result.db")
+	if err := WriteSQLite(path, scan.ModeHash, groups); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, tt := range []struct {
+		query string
+		want  int64
+	}{
+		{`SELECT COUNT(*) FROM groups`, 3},
+		{`SELECT COUNT(*) FROM files`, 7},
+		{`SELECT COUNT(*) FROM files WHERE ext = '.jpg'`, 3},
+		{`SELECT SUM(wasted_bytes) FROM groups`, 2*10 + 3},
+		{`SELECT id FROM groups WHERE is_empty = 1`, 3}, // Группа пустых файлов помечена
+	} {
+		var got int64
+		if err := db.QueryRow(tt.query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+
+	// Чтение обратно дает те же группы
+	back, mode, err := ReadSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != scan.ModeHash {
+		t.Errorf("ReadSQLite: режим %q, want %q", mode, scan.ModeHash)
+	}
+	if len(back) != 3 || len(back[0])+len(back[1])+len(back[2]) != 7 {
+		t.Errorf("ReadSQLite: %d групп, want 3 с семью файлами", len(back))
+	}
+}
+
+func TestReadSQLiteRoundTrip(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	group := []scan.FileInfo{
+		{Path: "/data/a.jpg", Name: "a.jpg", Size: 10, Hash: "h1", ModTime: mtime, Root: "/data"},
+		{Path: "/backup/photos.zip!a.jpg", Name: "a.jpg", Size: 10, Hash: "h1", ModTime: mtime, Root: "/backup", ReadOnly: true},
+	}
+	for _, mode := range []scan.Mode{scan.ModeHash, scan.ModeSizeOnly} {
+		path := filepath.Join(t.TempDir(), "result.db")
+		if err := WriteSQLite(path, mode, [][]scan.FileInfo{group}); err != nil {
+			t.Fatal(err)
+		}
+		back, gotMode, err := ReadSQLite(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotMode != mode {
+			t.Errorf("режим %q, want %q", gotMode, mode)
+		}
+		if len(back) != 1 || len(back[0]) != len(group) {
+			t.Fatalf("группы %v, want одну из %d файлов", back, len(group))
+		}
+		for i, f := range back[0] {
+			want := group[i]
+			if f.Path != want.Path || f.Root != want.Root || f.ReadOnly != want.ReadOnly || !f.ModTime.Equal(want.ModTime) {
+				t.Errorf("файл %d: %+v, want %+v", i, f, want)
+			}
+		}
+		// По режиму из базы -tui-from решает, можно ли выполнять действия
+		if err := checkActionMode(gotMode); (err == nil) != (mode == scan.ModeHash) {
+			t.Errorf("checkActionMode(%q) = %v", gotMode, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Снимок состояния по запросу пользователя (SIGUSR1 или Enter) во время долгого сканирования
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/BatrazG/duplifinder/scan"
+	"golang.org/x/term"
+)
+
+// writeStatus печатает снимок состояния
+func writeStatus(w io.Writer, st scan.Status) {
+	fmt.Fprintf(w, "\n📍 Фаза: %s | Найдено файлов: %d | Хэшировано: %d (%d из %d bytes, %.0f%%) | Ошибок: %d\n",
+		st.Phase, st.FilesSeen, st.FilesHashed, st.BytesHashed, st.BytesTotal, st.Percent, st.Errors)
+	for i, path := range st.Current {
+		if path == "" {
+			path = "—"
+		}
+		if size := st.CurrentSize[i]; size > 0 {
+			fmt.Fprintf(w, "  👷 %d: %s (%d из %d bytes, %.0f%%)\n", i+1, path, st.CurrentRead[i], size, float64(st.CurrentRead[i])*100/float64(size))
+			continue
+		}
+		fmt.Fprintf(w, "  👷 %d: %s\n", i+1, path)
+	}
+}
+
+// startStatusDump печатает состояние в stderr по SIGUSR1 (Unix) и по Enter в терминале.
+// Enter не слушается, если stdin нужен для интерактивного режима.
+// Возвращает функцию остановки.
+func startStatusDump(scanner *scan.Scanner, readEnter bool) (stop func()) {
+	requests := make(chan struct{}, 1)
+	signals := make(chan os.Signal, 1)
+	notifyStatusSignal(signals)
+
+	if readEnter && term.IsTerminal(int(os.Stdin.Fd())) {
+		go func() {
+			// Горутина может остаться ждать ввода после остановки: stdin больше никому не нужен
+			in := bufio.NewScanner(os.Stdin)
+			for in.Scan() {
+				select {
+				case requests <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+			case <-requests:
+			case <-done:
+				return
+			}
+			writeStatus(os.Stderr, scanner.Status())
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+// Без SIGUSR1: состояние доступно только по Enter
+package main
+
+import "os"
+
+// notifyStatusSignal ничего не делает: на этих платформах нет SIGUSR1
+func notifyStatusSignal(c chan<- os.Signal) {}
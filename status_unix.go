@@ -0,0 +1,15 @@
+//go:build unix
+
+// Запрос состояния сигналом SIGUSR1 (kill -USR1 <pid>)
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyStatusSignal подписывает канал на SIGUSR1
+func notifyStatusSignal(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
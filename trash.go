@@ -0,0 +1,25 @@
+// Общие части реализации корзины для разных ОС
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxTrashNameAttempts ограничивает перебор свободных имен в корзине
+const maxTrashNameAttempts = 10000
+
+// trashName возвращает i-й вариант имени файла в корзине:
+// "report.pdf", "report.2.pdf", "report.3.pdf" ...
+func trashName(base string, i int) string {
+	if i == 0 {
+		return base
+	}
+	ext := filepath.Ext(base)
+	// Для "скрытых" файлов вида ".bashrc" расширения нет
+	if ext == base {
+		ext = ""
+	}
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), i+1, ext)
+}
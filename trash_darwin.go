@@ -0,0 +1,55 @@
+// Корзина macOS: перемещение в ~/.Trash
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// moveToTrash переносит файл в ~/.Trash под свободным именем.
+// Файлы с других томов Finder кладет в /Volumes/X/.Trashes, мы их не копируем,
+// а сообщаем об ошибке - безвозвратного удаления вместо корзины не бывает.
+// Возвращает путь, под которым файл лежит в корзине.
+func moveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("не найдена домашняя директория: %w", err)
+	}
+	trashDir := filepath.Join(home, ".Trash")
+	if err := os.MkdirAll(trashDir, 0o700); err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(abs)
+	for i := 0; i < maxTrashNameAttempts; i++ {
+		target := filepath.Join(trashDir, trashName(base, i))
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+		err := os.Rename(abs, target)
+		if errors.Is(err, syscall.EXDEV) {
+			return "", fmt.Errorf("файл находится на другом томе, перемещение в ~/.Trash невозможно")
+		}
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return target, nil
+	}
+	return "", fmt.Errorf("не найдено свободное имя в корзине %s", trashDir)
+}
+
+// restoreFromTrash возвращает файл из ~/.Trash на прежнее место
+func restoreFromTrash(target, original string) error {
+	return os.Rename(target, original)
+}
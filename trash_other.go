@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+// Заглушка для платформ без корзины (plan9, wasm)
+package main
+
+import "errors"
+
+// moveToTrash на этих платформах недоступна; файл остается на месте
+func moveToTrash(path string) (string, error) {
+	return "", errors.New("корзина не поддерживается на этой платформе")
+}
+
+// restoreFromTrash недоступна вместе с корзиной
+func restoreFromTrash(target, original string) error {
+	return errors.New("корзина не поддерживается на этой платформе")
+}
@@ -0,0 +1,62 @@
+// Корзина Windows: SHFileOperationW с флагом FOF_ALLOWUNDO
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var procSHFileOperationW = syscall.NewLazyDLL("shell32.dll").NewProc("SHFileOperationW")
+
+const (
+	foDelete           = 0x0003
+	fofSilent          = 0x0004
+	fofNoConfirmation  = 0x0010
+	fofAllowUndo       = 0x0040
+	fofNoErrorUI       = 0x0400
+	fofNoConfirmMkdir  = 0x0200
+	fofWantNukeWarning = 0x4000
+	// Без fofWantNukeWarning оболочка молча удаляет насовсем то, что Корзина не примет
+	// (диск без Корзины, слишком большой файл, сетевая папка); с ним - спрашивает,
+	// и отказ пользователя возвращается ошибкой, а файл остается на месте
+	trashOperationFlag = fofAllowUndo | fofNoConfirmation | fofNoErrorUI | fofSilent | fofNoConfirmMkdir | fofWantNukeWarning
+)
+
+// moveToTrash отправляет файл в Корзину Windows.
+// Имя файла внутри Корзины оболочка не сообщает, поэтому путь в корзине пустой.
+// SHFileOperation не принимает пути \\?\ (scan.LongPath), так что файлы с путем
+// длиннее 260 символов в Корзину не отправить - для них остается DELETE.
+func moveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	from, err := syscall.UTF16FromString(abs)
+	if err != nil {
+		return "", err
+	}
+	// pFrom - список строк, завершающийся двойным нулем
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: trashOperationFlag,
+	}
+	ret, _, _ := procSHFileOperationW.Call(uintptr(unsafe.Pointer(&op)))
+	if ret != 0 {
+		return "", fmt.Errorf("SHFileOperation вернула код %#x", ret)
+	}
+	if op.aborted() {
+		return "", fmt.Errorf("перемещение в корзину отменено")
+	}
+	return "", nil
+}
+
+// restoreFromTrash недоступна: файл восстанавливается из Корзины средствами Windows
+func restoreFromTrash(target, original string) error {
+	return errors.New("восстановите файл из Корзины Windows вручную")
+}
@@ -0,0 +1,23 @@
+//go:build windows && (386 || arm)
+
+// SHFILEOPSTRUCTW 32-битной Windows: shellapi.h упаковывает его по 1 байту
+package main
+
+// shFileOpStruct повторяет SHFILEOPSTRUCTW. После fFlags поля идут без выравнивания
+// (fAnyOperationsAborted - со смещения 18), поэтому они объявлены массивами байт:
+// Go их не выравнивает, и смещения совпадают с упакованной структурой
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted [4]byte // BOOL
+	hNameMappings         [4]byte // LPVOID
+	lpszProgressTitle     [4]byte // PCWSTR
+}
+
+// aborted сообщает, отменена ли операция (fAnyOperationsAborted)
+func (op *shFileOpStruct) aborted() bool {
+	return op.fAnyOperationsAborted != [4]byte{}
+}
@@ -0,0 +1,21 @@
+//go:build windows && !(386 || arm)
+
+// SHFILEOPSTRUCTW 64-битной Windows: обычное выравнивание полей
+package main
+
+// shFileOpStruct повторяет SHFILEOPSTRUCTW
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+// aborted сообщает, отменена ли операция (fAnyOperationsAborted)
+func (op *shFileOpStruct) aborted() bool {
+	return op.fAnyOperationsAborted != 0
+}
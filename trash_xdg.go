@@ -0,0 +1,209 @@
+//go:build unix && !darwin
+
+// Корзина по спецификации freedesktop.org (XDG Trash) для Linux и BSD
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// moveToTrash перемещает файл в корзину.
+// Файлы с того же тома, что и домашняя корзина, попадают в $XDG_DATA_HOME/Trash,
+// файлы с других томов - в корзину тома ($topdir/.Trash/$uid или $topdir/.Trash-$uid).
+// Копирования между томами нет: если переименование невозможно, возвращается ошибка.
+// Возвращает путь, под которым файл лежит в корзине.
+func moveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return "", err
+	}
+	fileDev, ok := deviceOf(info)
+	if !ok {
+		return "", fmt.Errorf("не удалось определить устройство файла")
+	}
+
+	homeTrash, err := homeTrashDir()
+	if err != nil {
+		return "", err
+	}
+	if homeDev, ok := nearestDevice(homeTrash); ok && homeDev == fileDev {
+		if err := ensureTrashDir(homeTrash); err != nil {
+			return "", err
+		}
+		// В домашней корзине путь в .trashinfo всегда абсолютный
+		return trashInto(homeTrash, abs, abs)
+	}
+
+	topdir := mountPoint(abs, fileDev)
+	trashDir, err := topdirTrash(topdir)
+	if err != nil {
+		return "", err
+	}
+	// В корзине тома путь хранится относительно его корня
+	rel, err := filepath.Rel(topdir, abs)
+	if err != nil {
+		return "", err
+	}
+	return trashInto(trashDir, abs, rel)
+}
+
+// restoreFromTrash возвращает файл из корзины на прежнее место и удаляет его .trashinfo
+func restoreFromTrash(target, original string) error {
+	if err := os.Rename(target, original); err != nil {
+		return err
+	}
+	// target = <корзина>/files/<имя>, описание лежит в <корзина>/info/<имя>.trashinfo
+	trashDir := filepath.Dir(filepath.Dir(target))
+	os.Remove(filepath.Join(trashDir, "info", filepath.Base(target)+".trashinfo"))
+	return nil
+}
+
+// homeTrashDir возвращает путь к домашней корзине: $XDG_DATA_HOME/Trash или ~/.local/share/Trash
+func homeTrashDir() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("не найдена домашняя директория: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// topdirTrash выбирает корзину тома по правилам спецификации:
+// сначала $topdir/.Trash/$uid (если .Trash - настоящая директория со sticky-битом),
+// иначе $topdir/.Trash-$uid
+func topdirTrash(topdir string) (string, error) {
+	uid := strconv.Itoa(os.Getuid())
+
+	shared := filepath.Join(topdir, ".Trash")
+	// Lstat: симлинк вместо .Trash не считается директорией
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&fs.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := ensureTrashDir(dir); err == nil {
+			return dir, nil
+		}
+	}
+
+	dir := filepath.Join(topdir, ".Trash-"+uid)
+	if err := ensureTrashDir(dir); err != nil {
+		return "", fmt.Errorf("корзина тома %s недоступна: %w", topdir, err)
+	}
+	return dir, nil
+}
+
+// ensureTrashDir создает корзину (files/ и info/) и проверяет, что она принадлежит текущему пользователю
+func ensureTrashDir(dir string) error {
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return err
+		}
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s не является директорией", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s принадлежит другому пользователю", dir)
+	}
+	return nil
+}
+
+// trashInto резервирует имя через .trashinfo (O_EXCL) и только затем переносит файл в files/.
+// Если перенос не удался, .trashinfo удаляется, а файл остается на месте.
+func trashInto(trashDir, abs, infoPath string) (string, error) {
+	base := filepath.Base(abs)
+	for i := 0; i < maxTrashNameAttempts; i++ {
+		name := trashName(base, i)
+		infoFile := filepath.Join(trashDir, "info", name+".trashinfo")
+		target := filepath.Join(trashDir, "files", name)
+
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		// Имя может быть занято "осиротевшим" файлом без .trashinfo
+		if _, err := os.Lstat(target); err == nil {
+			f.Close()
+			os.Remove(infoFile)
+			continue
+		}
+
+		content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: infoPath}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(abs, target)
+		}
+		if err != nil {
+			os.Remove(infoFile)
+			return "", err
+		}
+		return target, nil
+	}
+	return "", fmt.Errorf("не найдено свободное имя в корзине %s", trashDir)
+}
+
+// mountPoint поднимается по родительским директориям, пока они на том же устройстве
+func mountPoint(path string, dev uint64) string {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		info, err := os.Stat(parent)
+		if err != nil {
+			return dir
+		}
+		if parentDev, ok := deviceOf(info); !ok || parentDev != dev {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// nearestDevice возвращает устройство ближайшего существующего предка пути
+// (домашней корзины может еще не быть)
+func nearestDevice(path string) (uint64, bool) {
+	for {
+		if info, err := os.Stat(path); err == nil {
+			return deviceOf(info)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, false
+		}
+		path = parent
+	}
+}
+
+// deviceOf достает номер устройства из системной информации о файле
+func deviceOf(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,161 @@
+//go:build unix && !darwin
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMoveToTrashHome(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	path := filepath.Join(t.TempDir(), "my report.txt")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	homeDev, _ := nearestDevice(dataHome)
+	fileDev, _ := nearestDevice(path)
+	if homeDev != fileDev {
+		t.Skip("временные папки на разных томах")
+	}
+
+	before := time.Now().Truncate(time.Second)
+	target, err := moveToTrash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash := filepath.Join(dataHome, "Trash")
+	if want := filepath.Join(trash, "files", "my report.txt"); target != want {
+		t.Errorf("файл в корзине %s, want %s", target, want)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("файл остался на месте: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(trash, "info", "my report.txt.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "[Trash Info]" {
+		t.Fatalf(".trashinfo:\n%s", data)
+	}
+	// В домашней корзине путь абсолютный и экранирован как URL
+	if want := "Path=" + strings.ReplaceAll(path, " ", "%20"); lines[1] != want {
+		t.Errorf("%s, want %s", lines[1], want)
+	}
+	date, err := time.ParseInLocation("2006-01-02T15:04:05", strings.TrimPrefix(lines[2], "DeletionDate="), time.Local)
+	if err != nil || date.Before(before) || date.After(time.Now()) {
+		t.Errorf("%s: %v, want время перемещения", lines[2], err)
+	}
+}
+
+func TestTrashIntoNameCollisions(t *testing.T) {
+	trash := filepath.Join(t.TempDir(), "Trash")
+	if err := ensureTrashDir(trash); err != nil {
+		t.Fatal(err)
+	}
+	// "a.txt" занято описанием, "a.2.txt" - файлом без описания: свободно только "a.3.txt"
+	for _, name := range []string{"info/a.txt.trashinfo", "files/a.2.txt"} {
+		if err := os.WriteFile(filepath.Join(trash, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := trashInto(trash, path, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(trash, "files", "a.3.txt"); target != want {
+		t.Errorf("файл в корзине %s, want %s", target, want)
+	}
+	// Описание, зарезервированное под занятый файлом "a.2.txt", не остается
+	if _, err := os.Lstat(filepath.Join(trash, "info", "a.2.txt.trashinfo")); !os.IsNotExist(err) {
+		t.Errorf("a.2.txt.trashinfo: %v, want удалено", err)
+	}
+	if _, err := os.Lstat(filepath.Join(trash, "info", "a.3.txt.trashinfo")); err != nil {
+		t.Errorf("a.3.txt.trashinfo: %v", err)
+	}
+}
+
+func TestTopdirTrash(t *testing.T) {
+	uid := strconv.Itoa(os.Getuid())
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, topdir string)
+		want  string // Относительно topdir
+	}{
+		{"нет .Trash", func(t *testing.T, topdir string) {}, ".Trash-" + uid},
+		{".Trash со sticky-битом", func(t *testing.T, topdir string) {
+			mkdirMode(t, filepath.Join(topdir, ".Trash"), 0o777|os.ModeSticky)
+		}, filepath.Join(".Trash", uid)},
+		{".Trash без sticky-бита", func(t *testing.T, topdir string) {
+			mkdirMode(t, filepath.Join(topdir, ".Trash"), 0o777)
+		}, ".Trash-" + uid},
+		{".Trash - симлинк", func(t *testing.T, topdir string) {
+			target := filepath.Join(topdir, "shared")
+			mkdirMode(t, target, 0o777|os.ModeSticky)
+			if err := os.Symlink(target, filepath.Join(topdir, ".Trash")); err != nil {
+				t.Fatal(err)
+			}
+		}, ".Trash-" + uid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topdir := t.TempDir()
+			tt.setup(t, topdir)
+			got, err := topdirTrash(topdir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(topdir, tt.want); got != want {
+				t.Errorf("корзина %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// mkdirMode создает директорию с правами mode (включая sticky-бит), не урезанными umask
+func mkdirMode(t *testing.T, dir string, mode os.FileMode) {
+	t.Helper()
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreFromTrash(t *testing.T) {
+	trash := filepath.Join(t.TempDir(), "Trash")
+	if err := ensureTrashDir(trash); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	target, err := trashInto(trash, path, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreFromTrash(target, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("восстановленный файл: %q, %v", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(trash, "info", "a.txt.trashinfo")); !os.IsNotExist(err) {
+		t.Errorf(".trashinfo: %v, want удалено", err)
+	}
+}
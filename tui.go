@@ -0,0 +1,336 @@
+// Полноэкранный просмотр результатов в терминале: группы, отметки файлов и подтверждение
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BatrazG/duplifinder/scan"
+	"golang.org/x/term"
+)
+
+// ErrNoTerminal - полноэкранному режиму нужен терминал и на вводе, и на выводе
+var ErrNoTerminal = errors.New("режим -tui требует терминал на стандартном вводе и выводе")
+
+// Размер экрана, если терминал его не сообщил
+const (
+	tuiDefaultWidth  = 80
+	tuiDefaultHeight = 24
+)
+
+const tuiHelp = "↑↓ jk - выбор  Enter - раскрыть  x - отметить  a - все кроме первого  u - снять  g - выполнить  q - выход"
+
+// Управляющие последовательности терминала
+const (
+	ansiAltScreenOn  = "\x1b[?1049h\x1b[?25l" // Отдельный экран и скрытый курсор
+	ansiAltScreenOff = "\x1b[?25h\x1b[?1049l"
+	ansiHome         = "\x1b[H\x1b[2J"
+	ansiReverse      = "\x1b[7m"
+	ansiReset        = "\x1b[0m"
+)
+
+// tuiRow - строка списка: заголовок группы (file == -1) или файл группы
+type tuiRow struct {
+	group, file int
+}
+
+// tuiModel - состояние просмотра. Отмеченные файлы будут обработаны действием Config.Action.
+type tuiModel struct {
+	groups   [][]scan.FileInfo
+	marked   []map[int]bool
+	expanded []bool
+	cursor   int    // Индекс выбранной строки в rows()
+	offset   int    // Первая видимая строка
+	status   string // Сообщение в нижней строке вместо подсказки
+}
+
+func newTUIModel(groups [][]scan.FileInfo) *tuiModel {
+	ordered := make([][]scan.FileInfo, len(groups))
+	copy(ordered, groups)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return scan.Reclaimable(ordered[i]) > scan.Reclaimable(ordered[j])
+	})
+	m := &tuiModel{groups: ordered, marked: make([]map[int]bool, len(ordered)), expanded: make([]bool, len(ordered))}
+	for i := range m.marked {
+		m.marked[i] = make(map[int]bool)
+	}
+	return m
+}
+
+// rows возвращает видимые строки: заголовки групп и файлы раскрытых групп
+func (m *tuiModel) rows() []tuiRow {
+	var rows []tuiRow
+	for g, group := range m.groups {
+		rows = append(rows, tuiRow{group: g, file: -1})
+		if m.expanded[g] {
+			for f := range group {
+				rows = append(rows, tuiRow{group: g, file: f})
+			}
+		}
+	}
+	return rows
+}
+
+// toggle отмечает файл или снимает отметку. Последний неотмеченный файл группы
+// отметить нельзя: хотя бы одна копия должна остаться.
+func (m *tuiModel) toggle(r tuiRow) {
+	if r.file < 0 {
+		m.expanded[r.group] = true
+		m.status = "Раскройте группу и отметьте файлы"
+		return
+	}
+	marked := m.marked[r.group]
+	if marked[r.file] {
+		delete(marked, r.file)
+		return
+	}
+	if len(marked) == len(m.groups[r.group])-1 {
+		m.status = "Нельзя отметить все файлы группы: одна копия должна остаться"
+		return
+	}
+	marked[r.file] = true
+}
+
+// markAllButFirst отмечает в группе все файлы, кроме первого
+func (m *tuiModel) markAllButFirst(g int) {
+	m.marked[g] = make(map[int]bool)
+	for f := 1; f < len(m.groups[g]); f++ {
+		m.marked[g][f] = true
+	}
+}
+
+// plan превращает отметки в операции; оставленной копией считается первый неотмеченный файл
+func (m *tuiModel) plan(op string) []PlannedOp {
+	var plan []PlannedOp
+	for g, group := range m.groups {
+		if len(m.marked[g]) == 0 {
+			continue
+		}
+		var kept scan.FileInfo
+		for f, file := range group {
+			if !m.marked[g][f] {
+				kept = file
+				break
+			}
+		}
+		for f, file := range group {
+			if m.marked[g][f] && !file.ReadOnly { // Запись архива удалить нельзя
+				plan = append(plan, newPlannedOp(op, file, kept))
+			}
+		}
+	}
+	return plan
+}
+
+// totals возвращает число отмеченных файлов и их суммарный размер
+func (m *tuiModel) totals() (int, int64) {
+	count, size := 0, int64(0)
+	for g, marked := range m.marked {
+		for f := range marked {
+			count++
+			size += m.groups[g][f].Size
+		}
+	}
+	return count, size
+}
+
+// handleKey обрабатывает клавишу списка (выход и подтверждение - в RunTUI)
+func (m *tuiModel) handleKey(key string) {
+	rows := m.rows()
+	if len(rows) == 0 {
+		return
+	}
+	r := rows[m.cursor]
+	switch key {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, len(rows)-1)
+	case "enter", "right":
+		if r.file < 0 {
+			m.expanded[r.group] = !m.expanded[r.group]
+		}
+	case "left":
+		m.expanded[r.group] = false
+		m.cursor = m.headerIndex(r.group)
+	case "x", " ":
+		m.toggle(r)
+	case "a":
+		m.markAllButFirst(r.group)
+	case "u":
+		m.marked[r.group] = make(map[int]bool)
+	}
+}
+
+// headerIndex возвращает индекс строки-заголовка группы g
+func (m *tuiModel) headerIndex(g int) int {
+	for i, r := range m.rows() {
+		if r.group == g && r.file < 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// render рисует список, уместив его в width x height
+func (m *tuiModel) render(w io.Writer, width, height int) {
+	rows := m.rows()
+	visible := max(height-2, 1) // Без заголовка и нижней строки
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+visible {
+		m.offset = m.cursor - visible + 1
+	}
+
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	count, size := m.totals()
+	b.WriteString(fitLine(fmt.Sprintf("DupliFinder: групп %d, отмечено файлов %d (%d bytes)", len(m.groups), count, size), width))
+	b.WriteString("\r\n")
+	for i := m.offset; i < min(m.offset+visible, len(rows)); i++ {
+		line := fitLine(m.rowText(rows[i], width), width)
+		if i == m.cursor {
+			line = ansiReverse + line + ansiReset
+		}
+		b.WriteString(line + "\r\n")
+	}
+	for i := len(rows) - m.offset; i < visible; i++ {
+		b.WriteString("\r\n")
+	}
+	footer := tuiHelp
+	if m.status != "" {
+		footer = m.status
+	}
+	b.WriteString(fitLine(footer, width))
+	io.WriteString(w, b.String())
+}
+
+// rowText возвращает текст строки списка
+func (m *tuiModel) rowText(r tuiRow, width int) string {
+	group := m.groups[r.group]
+	if r.file < 0 {
+		arrow := "▸"
+		if m.expanded[r.group] {
+			arrow = "▾"
+		}
+		text := fmt.Sprintf("%s #%d  %d × %d bytes, освободится %d", arrow, r.group+1, len(group), group[0].Size, scan.Reclaimable(group))
+		if n := len(m.marked[r.group]); n > 0 {
+			text += fmt.Sprintf("  [отмечено %d]", n)
+		}
+		return text
+	}
+	f := group[r.file]
+	mark := "[ ]"
+	if m.marked[r.group][r.file] {
+		mark = "[x]"
+	}
+	prefix := fmt.Sprintf("    %s %12d  %s  ", mark, f.Size, f.ModTime.Format("2006-01-02 15:04"))
+	return prefix + truncateLeft(f.Path, width-len([]rune(prefix)))
+}
+
+// fitLine обрезает строку по ширине экрана
+func fitLine(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:max(width, 0)])
+	}
+	return s
+}
+
+// truncateLeft укорачивает путь слева: конец пути (имя файла) важнее начала
+func truncateLeft(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width <= 1 {
+		return "…"
+	}
+	return "…" + string(r[len(r)-width+1:])
+}
+
+// readKey читает одну клавишу в raw-режиме и возвращает ее имя
+func readKey(in io.Reader) (string, error) {
+	buf := make([]byte, 8)
+	n, err := in.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	switch s := string(buf[:n]); s {
+	case "\x1b[A", "\x1bOA":
+		return "up", nil
+	case "\x1b[B", "\x1bOB":
+		return "down", nil
+	case "\x1b[C", "\x1bOC":
+		return "right", nil
+	case "\x1b[D", "\x1bOD":
+		return "left", nil
+	case "\r", "\n":
+		return "enter", nil
+	case "\x03": // Ctrl-C в raw-режиме приходит байтом, а не сигналом
+		return "q", nil
+	default:
+		return strings.ToLower(s), nil
+	}
+}
+
+// RunTUI показывает группы на весь экран и возвращает план по отмеченным файлам.
+// Действие выполняется только после подтверждения; q - выход без действий (пустой план).
+func RunTUI(cfg Config, groups [][]scan.FileInfo, in, out *os.File) ([]PlannedOp, error) {
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, ErrNoTerminal
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	io.WriteString(out, ansiAltScreenOn)
+	defer io.WriteString(out, ansiAltScreenOff)
+
+	m := newTUIModel(groups)
+	op := opName(cfg.Action)
+	confirming := false
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = tuiDefaultWidth, tuiDefaultHeight
+		}
+		if confirming {
+			count, size := m.totals()
+			io.WriteString(out, ansiHome+fitLine(fmt.Sprintf("%s: файлов %d, %d bytes. Выполнить? (y - да, любая клавиша - назад)", op, count, size), width))
+		} else {
+			m.render(out, width, height)
+		}
+
+		key, err := readKey(in)
+		if err != nil {
+			return nil, err
+		}
+		if confirming {
+			if key == "y" {
+				return m.plan(op), nil
+			}
+			confirming = false
+			continue
+		}
+		m.status = ""
+		switch key {
+		case "q":
+			return nil, nil
+		case "g":
+			if count, _ := m.totals(); count == 0 {
+				m.status = "Ничего не отмечено"
+				continue
+			}
+			confirming = true
+		default:
+			m.handleKey(key)
+		}
+	}
+}